@@ -1,20 +1,32 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/ekas-7/CRUD-Ecommerce/internal/config"
 	"github.com/ekas-7/CRUD-Ecommerce/internal/database"
 	"github.com/ekas-7/CRUD-Ecommerce/internal/handler"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/mailer"
 	"github.com/ekas-7/CRUD-Ecommerce/internal/middleware"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
 	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
 	"github.com/ekas-7/CRUD-Ecommerce/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
+// shutdownTimeout bounds how long in-flight requests and imports get to
+// finish once a shutdown signal arrives.
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
@@ -42,54 +54,144 @@ func main() {
 	// Initialize services
 	services := initServices(repos, cfg)
 
+	// Stop on a shutdown signal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start background jobs
+	services.Price.StartRefresher(ctx, cfg.Prices.RefreshInterval)
+	services.Recommendation.StartScheduler(ctx, cfg.Recommendations.Interval)
+	services.Wishlist.StartPriceWatch(ctx, cfg.Wishlist.PriceCheckInterval)
+
 	// Initialize handlers
 	handlers := initHandlers(services)
 
 	// Setup router
-	router := setupRouter(handlers, cfg)
+	router := setupRouter(handlers, services.User, cfg)
 
 	// Start server
 	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
-	log.Printf("Server starting on %s", addr)
-	if err := router.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{Addr: addr, Handler: router}
+
+	go func() {
+		log.Printf("Server starting on %s", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Wait for a shutdown signal, then drain requests and background work
+	<-ctx.Done()
+
+	log.Printf("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
+	}
+	if err := services.Catalog.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to stop catalog imports: %v", err)
 	}
 }
 
 func initRepositories(db *sql.DB) *repository.Repositories {
 	return &repository.Repositories{
-		User:     repository.NewUserRepository(db),
-		Product:  repository.NewProductRepository(db),
-		Category: repository.NewCategoryRepository(db),
-		Order:    repository.NewOrderRepository(db),
+		User:         repository.NewUserRepository(db),
+		Product:      repository.NewProductRepository(db),
+		Category:     repository.NewCategoryRepository(db),
+		Order:        repository.NewOrderRepository(db),
+		Review:       repository.NewReviewRepository(db),
+		ImportJob:    repository.NewImportJobRepository(db),
+		Inventory:    repository.NewInventoryRepository(db),
+		Warehouse:    repository.NewWarehouseRepository(db),
+		StockAlert:   repository.NewStockAlertRepository(db),
+		Notification: repository.NewNotificationRepository(db),
+		Price:        repository.NewPriceRepository(db),
+		Attribute:    repository.NewAttributeRepository(db),
+
+		Recommendation: repository.NewRecommendationRepository(db),
+		Wishlist:       repository.NewWishlistRepository(db),
+		Bundle:         repository.NewBundleRepository(db),
+		Session:        repository.NewSessionRepository(db),
+		UserToken:      repository.NewUserTokenRepository(db),
+		MFA:            repository.NewMFARepository(db),
+		LoginAttempt:   repository.NewLoginAttemptRepository(db),
+		Role:           repository.NewRoleRepository(db),
+	}
+}
+
+// newMailer picks the mail transport: real SMTP, or a local outbox
+// directory for development.
+func newMailer(cfg config.MailConfig) mailer.Mailer {
+	if cfg.Driver == "smtp" {
+		return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+	}
+	return mailer.NewOutboxMailer(cfg.OutboxDir, cfg.From)
+}
+
+func loginLimits(cfg config.LoginConfig) service.LoginLimits {
+	return service.LoginLimits{
+		MaxFailures:     cfg.MaxFailures,
+		LockoutDuration: cfg.LockoutDuration,
+		IPMaxFailures:   cfg.IPMaxFailures,
+		IPWindow:        cfg.IPWindow,
+		FailureWindow:   cfg.FailureWindow,
 	}
 }
 
 func initServices(repos *repository.Repositories, cfg *config.Config) *service.Services {
+	orderService := service.NewOrderService(repos.Order, repos.User, repos.Product, repos.Bundle, repos.Warehouse, model.AllocationStrategy(cfg.Inventory.AllocationStrategy))
+
 	return &service.Services{
-		User:     service.NewUserService(repos.User, cfg.JWT.Secret, cfg.JWT.Expiry),
-		Product:  service.NewProductService(repos.Product),
-		Category: service.NewCategoryService(repos.Category),
-		Order:    service.NewOrderService(repos.Order, repos.Product),
+		User:         service.NewUserService(repos.User, repos.Session, repos.UserToken, repos.MFA, repos.LoginAttempt, repos.Role, newMailer(cfg.Mail), cfg.App.BaseURL, cfg.JWT.Secret, cfg.JWT.Expiry, cfg.JWT.RefreshExpiry, cfg.MFA.Issuer, cfg.MFA.RequireForAdmin, loginLimits(cfg.Login)),
+		Product:      service.NewProductService(repos.Product, repos.Category, repos.Attribute, repos.Bundle),
+		Category:     service.NewCategoryService(repos.Category),
+		Order:        orderService,
+		Review:       service.NewReviewService(repos.Review, repos.Product),
+		Catalog:      service.NewCatalogService(repos.ImportJob, repos.Product, repos.Category),
+		Inventory:    service.NewInventoryService(repos.Inventory, repos.Product, repos.Warehouse, repos.StockAlert),
+		Warehouse:    service.NewWarehouseService(repos.Warehouse),
+		Notification: service.NewNotificationService(repos.Notification, repos.Product),
+		Price:        service.NewPriceService(repos.Price, repos.Product),
+		Attribute:    service.NewAttributeService(repos.Attribute, repos.Category),
+
+		Recommendation: service.NewRecommendationService(repos.Recommendation, repos.Product),
+		Wishlist:       service.NewWishlistService(repos.Wishlist, repos.Product, orderService),
 	}
 }
 
 func initHandlers(services *service.Services) *handler.Handlers {
 	return &handler.Handlers{
-		User:     handler.NewUserHandler(services.User),
-		Product:  handler.NewProductHandler(services.Product),
-		Category: handler.NewCategoryHandler(services.Category),
-		Order:    handler.NewOrderHandler(services.Order),
+		User:         handler.NewUserHandler(services.User),
+		Product:      handler.NewProductHandler(services.Product),
+		Category:     handler.NewCategoryHandler(services.Category),
+		Order:        handler.NewOrderHandler(services.Order),
+		Review:       handler.NewReviewHandler(services.Review),
+		Catalog:      handler.NewCatalogHandler(services.Catalog),
+		Inventory:    handler.NewInventoryHandler(services.Inventory),
+		Warehouse:    handler.NewWarehouseHandler(services.Warehouse),
+		Notification: handler.NewNotificationHandler(services.Notification),
+		Price:        handler.NewPriceHandler(services.Price),
+		Attribute:    handler.NewAttributeHandler(services.Attribute),
+
+		Recommendation: handler.NewRecommendationHandler(services.Recommendation),
+		Wishlist:       handler.NewWishlistHandler(services.Wishlist),
 	}
 }
 
-func setupRouter(handlers *handler.Handlers, cfg *config.Config) *gin.Engine {
+func setupRouter(handlers *handler.Handlers, sessions middleware.SessionChecker, cfg *config.Config) *gin.Engine {
 	if cfg.App.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	router := gin.Default()
 
+	// Only believe forwarded client addresses from configured proxies
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+
 	// Apply global middleware
 	router.Use(middleware.CORS())
 	router.Use(middleware.Logger())
@@ -108,68 +210,187 @@ func setupRouter(handlers *handler.Handlers, cfg *config.Config) *gin.Engine {
 		{
 			auth.POST("/register", handlers.User.Register)
 			auth.POST("/login", handlers.User.Login)
+			auth.POST("/mfa", handlers.User.CompleteMFALogin)
+			auth.POST("/refresh", handlers.User.Refresh)
+			auth.POST("/logout", handlers.User.Logout)
+			auth.POST("/verify-email", handlers.User.VerifyEmail)
+			auth.POST("/resend-verification", handlers.User.ResendVerification)
+			auth.POST("/forgot-password", handlers.User.ForgotPassword)
+			auth.POST("/reset-password", handlers.User.ResetPassword)
 		}
 
 		// Categories (public read)
 		categories := v1.Group("/categories")
 		{
 			categories.GET("", handlers.Category.GetAll)
+			categories.GET("/tree", handlers.Category.GetTree)
+			categories.GET("/by-slug/:slug", handlers.Category.GetBySlug)
 			categories.GET("/:id", handlers.Category.GetByID)
+			categories.GET("/:id/attributes", handlers.Attribute.GetByCategory)
 		}
 
 		// Products (public read)
 		products := v1.Group("/products")
 		{
 			products.GET("", handlers.Product.GetAll)
+			products.GET("/search", handlers.Product.Search)
+			products.GET("/by-slug/:slug", handlers.Product.GetBySlug)
 			products.GET("/:id", handlers.Product.GetByID)
+			products.GET("/:id/reviews", handlers.Review.GetProductReviews)
+			products.GET("/:id/related", handlers.Recommendation.GetRelated)
 			products.GET("/category/:categoryId", handlers.Product.GetByCategory)
 		}
 
+		// Shared wishlists (public read)
+		v1.GET("/wishlists/shared/:token", handlers.Wishlist.GetShared)
+
 		// Protected routes
 		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(cfg.JWT.Secret))
+		protected.Use(middleware.AuthMiddleware(cfg.JWT.Secret, sessions, cfg.MFA.RequireForAdmin))
 		{
 			// User routes
 			users := protected.Group("/users")
 			{
 				users.GET("/me", handlers.User.GetProfile)
 				users.PUT("/me", handlers.User.UpdateProfile)
+				users.PATCH("/me", handlers.User.PatchProfile)
+				users.PUT("/me/password", handlers.User.ChangePassword)
+				users.POST("/me/mfa/setup", handlers.User.SetupMFA)
+				users.POST("/me/mfa/enable", handlers.User.EnableMFA)
+				users.POST("/me/mfa/disable", handlers.User.DisableMFA)
+				users.POST("/me/mfa/recovery-codes", handlers.User.RegenerateRecoveryCodes)
 				users.DELETE("/me", handlers.User.DeleteAccount)
+				users.GET("/me/logins", handlers.User.GetLoginHistory)
+				users.GET("/me/notifications", handlers.Notification.GetUserNotifications)
+				users.PUT("/me/notifications/:id/read", handlers.Notification.MarkRead)
+
+				// Wishlists
+				users.GET("/me/wishlists", handlers.Wishlist.GetUserWishlists)
+				users.POST("/me/wishlists", handlers.Wishlist.Create)
+				users.GET("/me/wishlists/:id", handlers.Wishlist.GetByID)
+				users.PUT("/me/wishlists/:id", handlers.Wishlist.Update)
+				users.DELETE("/me/wishlists/:id", handlers.Wishlist.Delete)
+				users.POST("/me/wishlists/:id/items", handlers.Wishlist.AddItem)
+				users.DELETE("/me/wishlists/:id/items/:productId", handlers.Wishlist.RemoveItem)
+				users.POST("/me/wishlists/:id/share", handlers.Wishlist.Share)
+				users.DELETE("/me/wishlists/:id/share", handlers.Wishlist.Unshare)
+				users.POST("/me/wishlists/:id/order", handlers.Wishlist.MoveToOrder)
+			}
+
+			// Product review routes
+			productReviews := protected.Group("/products")
+			{
+				productReviews.POST("/:id/reviews", handlers.Review.Create)
+			}
+
+			// Back-in-stock subscription routes
+			productSubscriptions := protected.Group("/products")
+			{
+				productSubscriptions.POST("/:id/stock-subscription", handlers.Notification.Subscribe)
+				productSubscriptions.DELETE("/:id/stock-subscription", handlers.Notification.Unsubscribe)
+			}
+
+			// Catalog management routes
+			catalog := protected.Group("/products")
+			catalog.Use(middleware.RequirePermission(model.PermissionCatalogWrite))
+			{
+				catalog.GET("/all", handlers.Product.GetAllAdmin)
+				catalog.GET("/all/:id", handlers.Product.GetByIDAdmin)
+				catalog.POST("", handlers.Product.Create)
+				catalog.PUT("/:id", handlers.Product.Update)
+				catalog.PATCH("/:id", handlers.Product.Patch)
+				catalog.DELETE("/:id", handlers.Product.Delete)
+				catalog.PUT("/:id/components", handlers.Product.SetComponents)
+
+				// Bulk catalog import/export
+				catalog.POST("/import", handlers.Catalog.Import)
+				catalog.GET("/import/:id", handlers.Catalog.GetImportJob)
+				catalog.GET("/import/:id/errors", handlers.Catalog.GetImportErrors)
+				catalog.GET("/export", handlers.Catalog.Export)
+
+				// Related product recommendations
+				catalog.POST("/related/recompute", handlers.Recommendation.Recompute)
+
+				// Price history and scheduled prices
+				catalog.GET("/:id/prices", handlers.Price.GetHistory)
+				catalog.POST("/:id/prices", handlers.Price.Schedule)
+				catalog.DELETE("/:id/prices/:priceId", handlers.Price.Cancel)
 			}
 
-			// Admin product routes
-			adminProducts := protected.Group("/products")
-			adminProducts.Use(middleware.AdminMiddleware())
+			// Inventory ledger routes
+			productInventory := protected.Group("/products")
+			productInventory.Use(middleware.RequirePermission(model.PermissionInventoryManage))
 			{
-				adminProducts.POST("", handlers.Product.Create)
-				adminProducts.PUT("/:id", handlers.Product.Update)
-				adminProducts.DELETE("/:id", handlers.Product.Delete)
+				productInventory.GET("/:id/inventory", handlers.Inventory.GetProductInventory)
+				productInventory.GET("/:id/inventory/movements", handlers.Inventory.GetMovements)
+				productInventory.POST("/:id/inventory/adjustments", handlers.Inventory.Adjust)
 			}
 
-			// Admin category routes
-			adminCategories := protected.Group("/categories")
-			adminCategories.Use(middleware.AdminMiddleware())
+			// Category management routes
+			catalogCategories := protected.Group("/categories")
+			catalogCategories.Use(middleware.RequirePermission(model.PermissionCatalogWrite))
 			{
-				adminCategories.POST("", handlers.Category.Create)
-				adminCategories.PUT("/:id", handlers.Category.Update)
-				adminCategories.DELETE("/:id", handlers.Category.Delete)
+				catalogCategories.POST("", handlers.Category.Create)
+				catalogCategories.PUT("/:id", handlers.Category.Update)
+				catalogCategories.PATCH("/:id", handlers.Category.Patch)
+				catalogCategories.DELETE("/:id", handlers.Category.Delete)
+				catalogCategories.POST("/:id/attributes", handlers.Attribute.Create)
+				catalogCategories.DELETE("/:id/attributes/:attributeId", handlers.Attribute.Delete)
 			}
 
-			// Order routes
+			// Order routes; staff with orders:read_all can also view other
+			// customers' orders, and with orders:update_status cancel them
 			orders := protected.Group("/orders")
 			{
 				orders.POST("", handlers.Order.Create)
 				orders.GET("", handlers.Order.GetUserOrders)
 				orders.GET("/:id", handlers.Order.GetByID)
-				orders.PUT("/:id/status", handlers.Order.UpdateStatus)
 				orders.DELETE("/:id", handlers.Order.Cancel)
+
+				// Order management
+				orders.GET("/all", middleware.RequirePermission(model.PermissionOrdersReadAll), handlers.Order.GetAllOrders)
+				orders.PUT("/:id/status", middleware.RequirePermission(model.PermissionOrdersUpdateStatus), handlers.Order.UpdateStatus)
+			}
+
+			// Low-stock alert routes
+			inventory := protected.Group("/inventory")
+			inventory.Use(middleware.RequirePermission(model.PermissionInventoryManage))
+			{
+				inventory.GET("/alerts", handlers.Inventory.GetAlerts)
+				inventory.PUT("/alerts/:id/acknowledge", handlers.Inventory.AcknowledgeAlert)
+			}
+
+			// Warehouse routes
+			warehouses := protected.Group("/warehouses")
+			warehouses.Use(middleware.RequirePermission(model.PermissionInventoryManage))
+			{
+				warehouses.GET("", handlers.Warehouse.GetAll)
+				warehouses.POST("", handlers.Warehouse.Create)
+				warehouses.PUT("/:id", handlers.Warehouse.Update)
+			}
+
+			// Account lockout routes
+			lockouts := protected.Group("/users")
+			lockouts.Use(middleware.RequirePermission(model.PermissionUsersManage))
+			{
+				lockouts.GET("/locked", handlers.User.GetLockedAccounts)
+				lockouts.POST("/:id/unlock", handlers.User.UnlockAccount)
+			}
+
+			// Role routes
+			roles := protected.Group("")
+			roles.Use(middleware.RequirePermission(model.PermissionRolesManage))
+			{
+				roles.GET("/roles", handlers.User.GetRoles)
+				roles.PUT("/users/:id/role", handlers.User.AssignRole)
 			}
 
-			// Admin order routes
-			adminOrders := protected.Group("/orders")
-			adminOrders.Use(middleware.AdminMiddleware())
+			// Review moderation routes
+			reviews := protected.Group("/reviews")
+			reviews.Use(middleware.RequirePermission(model.PermissionReviewsModerate))
 			{
-				adminOrders.GET("/all", handlers.Order.GetAllOrders)
+				reviews.GET("", handlers.Review.GetByStatus)
+				reviews.PUT("/:id/status", handlers.Review.Moderate)
 			}
 		}
 	}
@@ -2,19 +2,29 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	App      AppConfig
+	Server          ServerConfig
+	Database        DatabaseConfig
+	JWT             JWTConfig
+	App             AppConfig
+	Inventory       InventoryConfig
+	Prices          PricesConfig
+	Recommendations RecommendationsConfig
+	Wishlist        WishlistConfig
+	Mail            MailConfig
+	MFA             MFAConfig
+	Login           LoginConfig
 }
 
 type ServerConfig struct {
-	Port string
-	Host string
+	Port           string
+	Host           string
+	TrustedProxies []string // addresses or CIDRs whose X-Forwarded-For is believed; none by default
 }
 
 type DatabaseConfig struct {
@@ -27,19 +37,61 @@ type DatabaseConfig struct {
 }
 
 type JWTConfig struct {
-	Secret string
-	Expiry time.Duration
+	Secret        string
+	Expiry        time.Duration // access token lifetime
+	RefreshExpiry time.Duration // how long an unused refresh token stays valid
 }
 
 type AppConfig struct {
-	Env string
+	Env     string
+	BaseURL string // storefront URL that links in emails point to
+}
+
+type InventoryConfig struct {
+	AllocationStrategy string
+}
+
+type PricesConfig struct {
+	RefreshInterval time.Duration // how often listings pick up scheduled prices; 0 disables
+}
+
+type RecommendationsConfig struct {
+	Interval time.Duration // 0 disables the background job
+}
+
+type WishlistConfig struct {
+	PriceCheckInterval time.Duration // 0 disables price-drop notifications
+}
+
+type MailConfig struct {
+	Driver       string // "outbox" writes .eml files to OutboxDir; "smtp" sends them
+	From         string
+	OutboxDir    string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+type MFAConfig struct {
+	Issuer          string // account name shown in authenticator apps
+	RequireForAdmin bool   // staff routes (any that need a permission) reject tokens from logins without MFA
+}
+
+type LoginConfig struct {
+	MaxFailures     int           // failed logins in a row that lock an account for LockoutDuration
+	LockoutDuration time.Duration // also the longest of the shorter delays before that
+	IPMaxFailures   int           // failed logins from one address within IPWindow before it is throttled
+	IPWindow        time.Duration
+	FailureWindow   time.Duration // an account's count starts over once its last failure is this old
 }
 
 func Load() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", "localhost"),
+			Port:           getEnv("SERVER_PORT", "8080"),
+			Host:           getEnv("SERVER_HOST", "localhost"),
+			TrustedProxies: parseList(getEnv("TRUSTED_PROXIES", "")),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -50,11 +102,45 @@ func Load() *Config {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-secret-key"),
-			Expiry: parseDuration(getEnv("JWT_EXPIRY", "24h")),
+			Secret:        getEnv("JWT_SECRET", "your-secret-key"),
+			Expiry:        parseDuration(getEnv("JWT_EXPIRY", "15m")),
+			RefreshExpiry: parseDuration(getEnv("JWT_REFRESH_EXPIRY", "720h")),
 		},
 		App: AppConfig{
-			Env: getEnv("APP_ENV", "development"),
+			Env:     getEnv("APP_ENV", "development"),
+			BaseURL: getEnv("APP_BASE_URL", "http://localhost:3000"),
+		},
+		Inventory: InventoryConfig{
+			AllocationStrategy: getEnv("ALLOCATION_STRATEGY", "priority"),
+		},
+		Prices: PricesConfig{
+			RefreshInterval: parseDuration(getEnv("PRICE_REFRESH_INTERVAL", "1m")),
+		},
+		Recommendations: RecommendationsConfig{
+			Interval: parseDuration(getEnv("RECOMMENDATIONS_INTERVAL", "1h")),
+		},
+		Wishlist: WishlistConfig{
+			PriceCheckInterval: parseDuration(getEnv("PRICE_DROP_CHECK_INTERVAL", "15m")),
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "outbox"),
+			From:         getEnv("MAIL_FROM", "no-reply@example.com"),
+			OutboxDir:    getEnv("MAIL_OUTBOX_DIR", "./mail-outbox"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		},
+		MFA: MFAConfig{
+			Issuer:          getEnv("MFA_ISSUER", "CRUD-Ecommerce"),
+			RequireForAdmin: getEnv("MFA_REQUIRE_ADMIN", "false") == "true",
+		},
+		Login: LoginConfig{
+			MaxFailures:     parseInt(getEnv("LOGIN_MAX_FAILURES", "10"), 10),
+			LockoutDuration: parseDuration(getEnv("LOGIN_LOCKOUT_DURATION", "15m")),
+			IPMaxFailures:   parseInt(getEnv("LOGIN_IP_MAX_FAILURES", "50"), 50),
+			IPWindow:        parseDuration(getEnv("LOGIN_IP_WINDOW", "15m")),
+			FailureWindow:   parseDuration(getEnv("LOGIN_FAILURE_WINDOW", "1h")),
 		},
 	}
 }
@@ -66,6 +152,25 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func parseInt(s string, defaultValue int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return defaultValue
+	}
+	return n
+}
+
+// parseList splits a comma-separated value, dropping empty entries.
+func parseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func parseDuration(s string) time.Duration {
 	d, err := time.ParseDuration(s)
 	if err != nil {
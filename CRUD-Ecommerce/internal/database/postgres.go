@@ -86,6 +86,563 @@ func RunMigrations(db *sql.DB) error {
 		`CREATE INDEX IF NOT EXISTS idx_products_category_id ON products(category_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders(user_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id);`,
+
+		// Full-text product search
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm;`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;`,
+		`CREATE OR REPLACE FUNCTION products_search_vector_update() RETURNS trigger AS $$
+		BEGIN
+			NEW.search_vector :=
+				setweight(to_tsvector('english', COALESCE(NEW.name, '')), 'A') ||
+				setweight(to_tsvector('english', COALESCE(NEW.description, '')), 'B');
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS products_search_vector_trigger ON products;`,
+		`CREATE TRIGGER products_search_vector_trigger
+			BEFORE INSERT OR UPDATE OF name, description ON products
+			FOR EACH ROW EXECUTE FUNCTION products_search_vector_update();`,
+		`UPDATE products SET search_vector =
+			setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+			setweight(to_tsvector('english', COALESCE(description, '')), 'B')
+		WHERE search_vector IS NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN(search_vector);`,
+		`CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING GIN(name gin_trgm_ops);`,
+
+		// Product reviews and ratings
+		`CREATE TABLE IF NOT EXISTS reviews (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+			title VARCHAR(255) NOT NULL DEFAULT '',
+			body TEXT NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (product_id, user_id)
+		);`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS average_rating DECIMAL(3, 2) NOT NULL DEFAULT 0;`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS review_count INTEGER NOT NULL DEFAULT 0;`,
+		`CREATE INDEX IF NOT EXISTS idx_reviews_product_status ON reviews(product_id, status);`,
+		`CREATE INDEX IF NOT EXISTS idx_reviews_status ON reviews(status);`,
+		`CREATE INDEX IF NOT EXISTS idx_products_average_rating ON products(average_rating);`,
+
+		// Product lifecycle and order item snapshots
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(100) UNIQUE;`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';`,
+		`CREATE INDEX IF NOT EXISTS idx_products_status ON products(status);`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_name VARCHAR(255);`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_sku VARCHAR(100);`,
+		`UPDATE order_items oi SET product_name = p.name, product_sku = p.sku
+		FROM products p
+		WHERE oi.product_id = p.id AND oi.product_name IS NULL;`,
+		`DO $$
+		BEGIN
+			IF EXISTS (
+				SELECT 1 FROM information_schema.referential_constraints
+				WHERE constraint_name = 'order_items_product_id_fkey' AND delete_rule = 'CASCADE'
+			) THEN
+				ALTER TABLE order_items DROP CONSTRAINT order_items_product_id_fkey;
+				ALTER TABLE order_items ADD CONSTRAINT order_items_product_id_fkey
+					FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE RESTRICT;
+			END IF;
+		END
+		$$;`,
+
+		// Bulk catalog import jobs
+		`CREATE TABLE IF NOT EXISTS import_jobs (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			format VARCHAR(10) NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			total_rows INTEGER NOT NULL DEFAULT 0,
+			processed_rows INTEGER NOT NULL DEFAULT 0,
+			created_count INTEGER NOT NULL DEFAULT 0,
+			updated_count INTEGER NOT NULL DEFAULT 0,
+			failed_count INTEGER NOT NULL DEFAULT 0,
+			errors JSONB NOT NULL DEFAULT '[]',
+			message TEXT NOT NULL DEFAULT '',
+			created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			started_at TIMESTAMP,
+			finished_at TIMESTAMP
+		);`,
+
+		// Inventory movement ledger
+		`CREATE TABLE IF NOT EXISTS inventory_movements (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			quantity INTEGER NOT NULL,
+			reason VARCHAR(20) NOT NULL,
+			reference_id UUID,
+			actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			note TEXT NOT NULL DEFAULT '',
+			stock_after INTEGER NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_movements_product ON inventory_movements(product_id, created_at);`,
+		`INSERT INTO inventory_movements (product_id, quantity, reason, note, stock_after)
+		SELECT p.id, p.stock, 'initial', 'opening balance', p.stock
+		FROM products p
+		WHERE p.stock <> 0
+		  AND NOT EXISTS (SELECT 1 FROM inventory_movements m WHERE m.product_id = p.id);`,
+
+		// Warehouses and per-warehouse stock
+		`CREATE TABLE IF NOT EXISTS warehouses (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			code VARCHAR(50) UNIQUE NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			region VARCHAR(100) NOT NULL DEFAULT '',
+			priority INTEGER NOT NULL DEFAULT 0,
+			is_default BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_warehouses_default ON warehouses(is_default) WHERE is_default;`,
+		`INSERT INTO warehouses (code, name, is_default)
+		SELECT 'MAIN', 'Main warehouse', TRUE
+		WHERE NOT EXISTS (SELECT 1 FROM warehouses WHERE is_default);`,
+		`CREATE TABLE IF NOT EXISTS warehouse_stock (
+			warehouse_id UUID NOT NULL REFERENCES warehouses(id) ON DELETE RESTRICT,
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (warehouse_id, product_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_warehouse_stock_product ON warehouse_stock(product_id);`,
+		`INSERT INTO warehouse_stock (warehouse_id, product_id, quantity)
+		SELECT w.id, p.id, p.stock
+		FROM products p, warehouses w
+		WHERE w.is_default AND p.stock > 0
+		  AND NOT EXISTS (SELECT 1 FROM warehouse_stock ws WHERE ws.product_id = p.id);`,
+		`ALTER TABLE inventory_movements ADD COLUMN IF NOT EXISTS warehouse_id UUID REFERENCES warehouses(id) ON DELETE RESTRICT;`,
+		`UPDATE inventory_movements SET warehouse_id = (SELECT id FROM warehouses WHERE is_default)
+		WHERE warehouse_id IS NULL;`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_region VARCHAR(100) NOT NULL DEFAULT '';`,
+		`CREATE TABLE IF NOT EXISTS order_allocations (
+			order_item_id UUID NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+			warehouse_id UUID NOT NULL REFERENCES warehouses(id) ON DELETE RESTRICT,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			PRIMARY KEY (order_item_id, warehouse_id)
+		);`,
+
+		// Low-stock alerts and back-in-stock notifications
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS reorder_threshold INTEGER NOT NULL DEFAULT 0;`,
+		`CREATE TABLE IF NOT EXISTS stock_alerts (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			stock INTEGER NOT NULL,
+			threshold INTEGER NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'open',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			acknowledged_at TIMESTAMP
+		);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_alerts_open ON stock_alerts(product_id) WHERE status = 'open';`,
+		`CREATE TABLE IF NOT EXISTS stock_subscriptions (
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			notified_at TIMESTAMP,
+			PRIMARY KEY (product_id, user_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			type VARCHAR(50) NOT NULL,
+			product_id UUID REFERENCES products(id) ON DELETE CASCADE,
+			message TEXT NOT NULL,
+			read_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, created_at);`,
+
+		// Price history and scheduled prices
+		`CREATE TABLE IF NOT EXISTS product_prices (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			kind VARCHAR(20) NOT NULL,
+			price DECIMAL(10, 2) NOT NULL CHECK (price > 0),
+			compare_at_price DECIMAL(10, 2),
+			starts_at TIMESTAMPTZ NOT NULL,
+			ends_at TIMESTAMPTZ,
+			created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			CHECK (ends_at IS NULL OR ends_at >= starts_at)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_product_prices_product ON product_prices(product_id, kind, starts_at);`,
+		`CREATE OR REPLACE FUNCTION product_active_scheduled_price(p_product_id UUID)
+		RETURNS product_prices AS $$
+			SELECT * FROM product_prices
+			WHERE product_id = p_product_id AND kind = 'scheduled'
+			  AND starts_at <= NOW() AND (ends_at IS NULL OR ends_at > NOW())
+			ORDER BY starts_at DESC
+			LIMIT 1
+		$$ LANGUAGE sql STABLE;`,
+		`CREATE OR REPLACE FUNCTION product_effective_price(p_product_id UUID, p_base_price DECIMAL)
+		RETURNS DECIMAL AS $$
+			SELECT COALESCE((product_active_scheduled_price(p_product_id)).price, p_base_price)
+		$$ LANGUAGE sql STABLE;`,
+		`CREATE OR REPLACE FUNCTION product_compare_at_price(p_product_id UUID, p_base_price DECIMAL)
+		RETURNS DECIMAL AS $$
+			SELECT COALESCE(sp.compare_at_price, CASE WHEN sp.price < p_base_price THEN p_base_price END)
+			FROM product_active_scheduled_price(p_product_id) sp
+		$$ LANGUAGE sql STABLE;`,
+		`CREATE OR REPLACE FUNCTION products_price_history() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'INSERT' OR OLD.price IS DISTINCT FROM NEW.price THEN
+				UPDATE product_prices SET ends_at = NOW()
+				WHERE product_id = NEW.id AND ends_at IS NULL
+				  AND (kind = 'base' OR starts_at <= NOW());
+				INSERT INTO product_prices (product_id, kind, price, starts_at)
+				VALUES (NEW.id, 'base', NEW.price, NOW());
+			END IF;
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS products_price_history_trigger ON products;`,
+		`CREATE TRIGGER products_price_history_trigger
+			AFTER INSERT OR UPDATE OF price ON products
+			FOR EACH ROW EXECUTE FUNCTION products_price_history();`,
+		`INSERT INTO product_prices (product_id, kind, price, starts_at)
+		SELECT p.id, 'base', p.price, p.created_at
+		FROM products p
+		WHERE NOT EXISTS (SELECT 1 FROM product_prices pp WHERE pp.product_id = p.id);`,
+
+		// Typed product attributes
+		`CREATE TABLE IF NOT EXISTS attribute_definitions (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+			code VARCHAR(50) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			type VARCHAR(20) NOT NULL,
+			unit VARCHAR(20) NOT NULL DEFAULT '',
+			options TEXT[] NOT NULL DEFAULT '{}',
+			required BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (category_id, code)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_attribute_definitions_code ON attribute_definitions(code);`,
+		`CREATE TABLE IF NOT EXISTS product_attribute_values (
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			attribute_id UUID NOT NULL REFERENCES attribute_definitions(id) ON DELETE CASCADE,
+			value_text TEXT NOT NULL,
+			value_number NUMERIC,
+			value_bool BOOLEAN,
+			PRIMARY KEY (product_id, attribute_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_product_attribute_values_number ON product_attribute_values(attribute_id, value_number);`,
+		`CREATE INDEX IF NOT EXISTS idx_product_attribute_values_text ON product_attribute_values(attribute_id, lower(value_text));`,
+		`CREATE OR REPLACE FUNCTION product_attributes(p_product_id UUID)
+		RETURNS JSONB AS $$
+			SELECT COALESCE(jsonb_object_agg(d.code, CASE
+				WHEN d.type IN ('integer', 'decimal') THEN to_jsonb(v.value_number)
+				WHEN d.type = 'boolean' THEN to_jsonb(v.value_bool)
+				ELSE to_jsonb(v.value_text)
+			END), '{}')
+			FROM product_attribute_values v
+			JOIN attribute_definitions d ON d.id = v.attribute_id
+			WHERE v.product_id = p_product_id
+		$$ LANGUAGE sql STABLE;`,
+
+		// Related product recommendations
+		`CREATE TABLE IF NOT EXISTS product_recommendations (
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			related_product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			score INTEGER NOT NULL,
+			computed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (product_id, related_product_id)
+		);`,
+
+		// Wishlists
+		`CREATE TABLE IF NOT EXISTS wishlists (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			share_token VARCHAR(64) UNIQUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_wishlists_user ON wishlists(user_id);`,
+		`CREATE TABLE IF NOT EXISTS wishlist_items (
+			wishlist_id UUID NOT NULL REFERENCES wishlists(id) ON DELETE CASCADE,
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			tracked_price DECIMAL(10, 2) NOT NULL,
+			added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (wishlist_id, product_id)
+		);`,
+
+		// Product and category slugs
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS slug VARCHAR(255);`,
+		`ALTER TABLE categories ADD COLUMN IF NOT EXISTS slug VARCHAR(255);`,
+		`UPDATE products t SET slug = s.slug
+		FROM (
+			SELECT b.id,
+				CASE WHEN row_number() OVER (PARTITION BY b.base ORDER BY b.created_at, b.id) = 1
+					AND NOT EXISTS (SELECT 1 FROM products o WHERE o.slug = b.base)
+				THEN b.base
+				ELSE b.base || '-' || left(b.id::text, 8)
+				END AS slug
+			FROM (
+				SELECT id, created_at,
+					COALESCE(NULLIF(trim(both '-' FROM regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g')), ''), 'product') AS base
+				FROM products
+				WHERE slug IS NULL
+			) b
+		) s
+		WHERE t.id = s.id;`,
+		`UPDATE categories t SET slug = s.slug
+		FROM (
+			SELECT b.id,
+				CASE WHEN row_number() OVER (PARTITION BY b.base ORDER BY b.created_at, b.id) = 1
+					AND NOT EXISTS (SELECT 1 FROM categories o WHERE o.slug = b.base)
+				THEN b.base
+				ELSE b.base || '-' || left(b.id::text, 8)
+				END AS slug
+			FROM (
+				SELECT id, created_at,
+					COALESCE(NULLIF(trim(both '-' FROM regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g')), ''), 'category') AS base
+				FROM categories
+				WHERE slug IS NULL
+			) b
+		) s
+		WHERE t.id = s.id;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_products_slug ON products(slug);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories(slug);`,
+		`CREATE TABLE IF NOT EXISTS slug_redirects (
+			entity VARCHAR(20) NOT NULL,
+			slug VARCHAR(255) NOT NULL,
+			target_id UUID NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (entity, slug)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_slug_redirects_target ON slug_redirects(target_id);`,
+
+		// Record versions for optimistic concurrency
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;`,
+		`ALTER TABLE categories ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;`,
+
+		// Product bundles
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS type VARCHAR(20) NOT NULL DEFAULT 'simple';`,
+		`CREATE TABLE IF NOT EXISTS bundle_components (
+			bundle_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			component_id UUID NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			PRIMARY KEY (bundle_id, component_id),
+			CHECK (bundle_id <> component_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_bundle_components_component ON bundle_components(component_id);`,
+		`CREATE OR REPLACE FUNCTION product_available_stock(p_product_id UUID, p_type VARCHAR, p_stock INTEGER)
+		RETURNS INTEGER AS $$
+			SELECT CASE WHEN p_type <> 'bundle' THEN p_stock ELSE COALESCE((
+				SELECT MIN(CASE WHEN c.status = 'active' THEN GREATEST(c.stock, 0) / bc.quantity ELSE 0 END)
+				FROM bundle_components bc
+				JOIN products c ON c.id = bc.component_id
+				WHERE bc.bundle_id = p_product_id
+			), 0) END
+		$$ LANGUAGE sql STABLE;`,
+		`ALTER TABLE order_allocations ADD COLUMN IF NOT EXISTS product_id UUID REFERENCES products(id) ON DELETE RESTRICT;`,
+		`UPDATE order_allocations oa SET product_id = oi.product_id
+		FROM order_items oi
+		WHERE oi.id = oa.order_item_id AND oa.product_id IS NULL;`,
+		`ALTER TABLE order_allocations ALTER COLUMN product_id SET NOT NULL;`,
+		`ALTER TABLE order_allocations DROP CONSTRAINT IF EXISTS order_allocations_pkey;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_order_allocations_item ON order_allocations(order_item_id, product_id, warehouse_id);`,
+
+		// Category tree
+		`ALTER TABLE categories ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES categories(id) ON DELETE RESTRICT;`,
+		`CREATE INDEX IF NOT EXISTS idx_categories_parent ON categories(parent_id);`,
+
+		// Products keep their category; deleting one moves its products first
+		`DO $$
+		BEGIN
+			IF EXISTS (
+				SELECT 1 FROM information_schema.referential_constraints
+				WHERE constraint_name = 'products_category_id_fkey' AND delete_rule <> 'RESTRICT'
+			) THEN
+				ALTER TABLE products DROP CONSTRAINT products_category_id_fkey;
+				ALTER TABLE products ADD CONSTRAINT products_category_id_fkey
+					FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE RESTRICT;
+			END IF;
+		END
+		$$;`,
+
+		// Sessions and refresh tokens
+		`CREATE TABLE IF NOT EXISTS sessions (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			user_agent TEXT NOT NULL DEFAULT '',
+			ip_address VARCHAR(64) NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			revoked_at TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);`,
+		`CREATE TABLE IF NOT EXISTS refresh_tokens (
+			token_hash VARCHAR(64) PRIMARY KEY,
+			session_id UUID NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL,
+			used_at TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session ON refresh_tokens(session_id);`,
+
+		// Email verification
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;`,
+		`ALTER TABLE users ALTER COLUMN email_verified_at DROP DEFAULT;`,
+		`CREATE TABLE IF NOT EXISTS user_tokens (
+			token_hash VARCHAR(64) PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			purpose VARCHAR(30) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL,
+			used_at TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_user_tokens_user ON user_tokens(user_id, purpose);`,
+
+		// Two-factor authentication
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_secret VARCHAR(64);`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_enabled_at TIMESTAMP;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_last_step BIGINT;`,
+		`CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+			code_hash VARCHAR(64) PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			used_at TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user ON mfa_recovery_codes(user_id);`,
+		`ALTER TABLE user_tokens ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE sessions ADD COLUMN IF NOT EXISTS mfa BOOLEAN NOT NULL DEFAULT FALSE;`,
+
+		// Login throttling and history
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_logins INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;`,
+		`CREATE TABLE IF NOT EXISTS login_attempts (
+			id UUID PRIMARY KEY,
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			email VARCHAR(255) NOT NULL,
+			ip_address VARCHAR(45) NOT NULL,
+			user_agent TEXT,
+			result VARCHAR(30) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_login_attempts_user ON login_attempts(user_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(ip_address, created_at);`,
+
+		// Roles and permissions
+		`CREATE TABLE IF NOT EXISTS roles (
+			name VARCHAR(20) PRIMARY KEY,
+			description TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS permissions (
+			name VARCHAR(50) PRIMARY KEY,
+			description TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS role_permissions (
+			role VARCHAR(20) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+			permission VARCHAR(50) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+			PRIMARY KEY (role, permission)
+		);`,
+		`INSERT INTO roles (name, description) VALUES
+			('user', 'Customer'),
+			('admin', 'Full access'),
+			('support', 'Customer support: orders, reviews and account lockouts'),
+			('warehouse', 'Warehouse staff: stock and order fulfilment')
+		ON CONFLICT (name) DO NOTHING;`,
+		`INSERT INTO permissions (name, description) VALUES
+			('catalog:write', 'Manage products, categories, attributes, prices and imports'),
+			('inventory:manage', 'Adjust stock, handle low-stock alerts and manage warehouses'),
+			('orders:read_all', 'View any customer''s orders'),
+			('orders:update_status', 'Update the status of and cancel any order'),
+			('reviews:moderate', 'Moderate product reviews'),
+			('users:manage', 'View and unlock locked accounts'),
+			('roles:manage', 'Assign roles to users')
+		ON CONFLICT (name) DO NOTHING;`,
+		`INSERT INTO role_permissions (role, permission)
+		SELECT 'admin', name FROM permissions
+		ON CONFLICT DO NOTHING;`,
+		`INSERT INTO role_permissions (role, permission) VALUES
+			('support', 'orders:read_all'),
+			('support', 'orders:update_status'),
+			('support', 'reviews:moderate'),
+			('support', 'users:manage'),
+			('warehouse', 'orders:read_all'),
+			('warehouse', 'orders:update_status'),
+			('warehouse', 'inventory:manage')
+		ON CONFLICT DO NOTHING;`,
+		`UPDATE users SET role = 'user' WHERE role IS NULL OR role NOT IN (SELECT name FROM roles);`,
+		`ALTER TABLE users ALTER COLUMN role SET NOT NULL;`,
+		`DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.table_constraints
+				WHERE constraint_name = 'users_role_fkey'
+			) THEN
+				ALTER TABLE users ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles(name);
+			END IF;
+		END
+		$$;`,
+
+		// Import job heartbeat
+		`ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;`,
+
+		// Indexed effective price
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS effective_price DECIMAL(10, 2);`,
+		`UPDATE products SET effective_price = product_effective_price(id, price) WHERE effective_price IS NULL;`,
+		`ALTER TABLE products ALTER COLUMN effective_price SET NOT NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_products_effective_price ON products(effective_price, id);`,
+		`CREATE OR REPLACE FUNCTION products_effective_price() RETURNS trigger AS $$
+		BEGIN
+			NEW.effective_price := product_effective_price(NEW.id, NEW.price);
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS products_effective_price_trigger ON products;`,
+		`CREATE TRIGGER products_effective_price_trigger
+			BEFORE INSERT OR UPDATE OF price ON products
+			FOR EACH ROW EXECUTE FUNCTION products_effective_price();`,
+		`CREATE OR REPLACE FUNCTION product_prices_effective_price() RETURNS trigger AS $$
+		DECLARE
+			changed product_prices;
+		BEGIN
+			IF TG_OP = 'DELETE' THEN
+				changed := OLD;
+			ELSE
+				changed := NEW;
+			END IF;
+
+			IF changed.kind = 'scheduled' THEN
+				UPDATE products SET effective_price = product_effective_price(id, price)
+				WHERE id = changed.product_id
+				  AND effective_price IS DISTINCT FROM product_effective_price(id, price);
+			END IF;
+			RETURN NULL;
+		END
+		$$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS product_prices_effective_price_trigger ON product_prices;`,
+		`CREATE TRIGGER product_prices_effective_price_trigger
+			AFTER INSERT OR UPDATE OR DELETE ON product_prices
+			FOR EACH ROW EXECUTE FUNCTION product_prices_effective_price();`,
+		`ALTER TABLE product_prices ALTER COLUMN created_at TYPE TIMESTAMPTZ;`,
+
+		// Order allocations primary key
+		`DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM pg_constraint
+				WHERE conrelid = 'order_allocations'::regclass AND contype = 'p'
+			) THEN
+				ALTER TABLE order_allocations
+					ADD CONSTRAINT idx_order_allocations_item PRIMARY KEY USING INDEX idx_order_allocations_item;
+			END IF;
+		END
+		$$;`,
+		// MFA attempt limit
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_attempts INTEGER NOT NULL DEFAULT 0;`,
+
+		// Failed login window
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS last_failed_login_at TIMESTAMP;`,
 	}
 
 	for _, migration := range migrations {
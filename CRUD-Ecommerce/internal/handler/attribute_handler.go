@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AttributeHandler struct {
+	service service.AttributeService
+}
+
+func NewAttributeHandler(service service.AttributeService) *AttributeHandler {
+	return &AttributeHandler{service: service}
+}
+
+func (h *AttributeHandler) Create(c *gin.Context) {
+	categoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	var req model.AttributeDefinitionCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attribute, err := h.service.Create(categoryID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"attribute": attribute})
+}
+
+func (h *AttributeHandler) GetByCategory(c *gin.Context) {
+	categoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	attributes, err := h.service.GetByCategory(categoryID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attributes": attributes})
+}
+
+func (h *AttributeHandler) Delete(c *gin.Context) {
+	categoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("attributeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attribute ID"})
+		return
+	}
+
+	if err := h.service.Delete(categoryID, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "attribute deleted successfully"})
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxImportSize caps the size of an uploaded catalog file.
+const maxImportSize = 10 << 20
+
+type CatalogHandler struct {
+	service service.CatalogService
+}
+
+func NewCatalogHandler(service service.CatalogService) *CatalogHandler {
+	return &CatalogHandler{service: service}
+}
+
+// Import accepts a catalog file either as a multipart "file" field or as the
+// raw request body. The format comes from ?format= or the content type.
+func (h *CatalogHandler) Import(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var body io.Reader = c.Request.Body
+	contentType := c.ContentType()
+	if strings.HasPrefix(contentType, "multipart/") {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		defer file.Close()
+		body = file
+		contentType = header.Header.Get("Content-Type")
+		if strings.HasSuffix(strings.ToLower(header.Filename), ".csv") {
+			contentType = "text/csv"
+		}
+	}
+
+	format := catalogFormat(c.Query("format"), contentType)
+	if format == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or ndjson"})
+		return
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "import file is too large"})
+		return
+	}
+
+	job, err := h.service.StartImport(userID, format, bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"job": job, "error_report_url": errorReportURL(job.ID)})
+}
+
+func (h *CatalogHandler) GetImportJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid import job ID"})
+		return
+	}
+
+	job, err := h.service.GetImportJob(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job": job, "error_report_url": errorReportURL(job.ID)})
+}
+
+func (h *CatalogHandler) GetImportErrors(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid import job ID"})
+		return
+	}
+
+	job, err := h.service.GetImportJob(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="import-%s-errors.csv"`, job.ID))
+	c.Status(http.StatusOK)
+	if err := service.WriteImportErrors(c.Writer, job.Errors); err != nil {
+		c.Error(err)
+	}
+}
+
+func (h *CatalogHandler) Export(c *gin.Context) {
+	format := catalogFormat(c.DefaultQuery("format", "csv"), "")
+	if format == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or ndjson"})
+		return
+	}
+
+	rows, err := h.service.Export(model.ProductStatus(c.Query("status")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType := "text/csv"
+	if format == model.CatalogFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+	c.Status(http.StatusOK)
+	if err := service.WriteCatalog(c.Writer, format, rows); err != nil {
+		c.Error(err)
+	}
+}
+
+func catalogFormat(format, contentType string) model.CatalogFormat {
+	switch strings.ToLower(format) {
+	case "csv":
+		return model.CatalogFormatCSV
+	case "ndjson", "jsonl":
+		return model.CatalogFormatNDJSON
+	case "":
+	default:
+		return ""
+	}
+
+	switch contentType {
+	case "text/csv", "application/csv":
+		return model.CatalogFormatCSV
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return model.CatalogFormatNDJSON
+	}
+
+	return ""
+}
+
+func errorReportURL(jobID uuid.UUID) string {
+	return fmt.Sprintf("/api/v1/products/import/%s/errors", jobID)
+}
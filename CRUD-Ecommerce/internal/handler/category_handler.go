@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"path"
 
 	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
 	"github.com/ekas-7/CRUD-Ecommerce/internal/service"
@@ -30,6 +31,7 @@ func (h *CategoryHandler) Create(c *gin.Context) {
 		return
 	}
 
+	setETag(c, category.Version)
 	c.JSON(http.StatusCreated, gin.H{"category": category})
 }
 
@@ -46,6 +48,25 @@ func (h *CategoryHandler) GetByID(c *gin.Context) {
 		return
 	}
 
+	setETag(c, category.Version)
+	c.JSON(http.StatusOK, gin.H{"category": category})
+}
+
+// GetBySlug returns a category by slug, or redirects permanently to the
+// category's current slug when an old one is requested.
+func (h *CategoryHandler) GetBySlug(c *gin.Context) {
+	category, current, err := h.service.GetBySlug(c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if current != "" {
+		c.Redirect(http.StatusMovedPermanently, path.Join(path.Dir(c.Request.URL.Path), current))
+		return
+	}
+
+	setETag(c, category.Version)
 	c.JSON(http.StatusOK, gin.H{"category": category})
 }
 
@@ -59,6 +80,17 @@ func (h *CategoryHandler) GetAll(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"categories": categories})
 }
 
+// GetTree returns all categories nested under their parents.
+func (h *CategoryHandler) GetTree(c *gin.Context) {
+	tree, err := h.service.GetTree()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"categories": tree})
+}
+
 func (h *CategoryHandler) Update(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -66,18 +98,52 @@ func (h *CategoryHandler) Update(c *gin.Context) {
 		return
 	}
 
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
 	var req model.CategoryUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	category, err := h.service.Update(id, &req)
+	category, err := h.service.Update(id, version, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(updateErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c, category.Version)
+	c.JSON(http.StatusOK, gin.H{"category": category})
+}
+
+// Patch applies a JSON merge patch (RFC 7396) to a category.
+func (h *CategoryHandler) Patch(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	patch, ok := readMergePatch(c)
+	if !ok {
+		return
+	}
+
+	category, err := h.service.Patch(id, version, patch)
+	if err != nil {
+		c.JSON(updateErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c, category.Version)
 	c.JSON(http.StatusOK, gin.H{"category": category})
 }
 
@@ -88,10 +154,25 @@ func (h *CategoryHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Delete(id); err != nil {
+	// Products in the category are moved to the target before it is deleted
+	var targetID uuid.UUID
+	if target := c.Query("target_category_id"); target != "" {
+		if targetID, err = uuid.Parse(target); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target category ID"})
+			return
+		}
+	}
+
+	result, err := h.service.Delete(id, targetID)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "category deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":                "category deleted successfully",
+		"products_moved":         result.ProductsMoved,
+		"attribute_values_moved": result.AttributeValuesMoved,
+		"dropped_attributes":     result.DroppedAttributes,
+	})
 }
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// setETag exposes a record's version as a strong entity tag.
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", fmt.Sprintf(`"%d"`, version))
+}
+
+// ifMatchVersion returns the version an update is based on, taken from the
+// required If-Match header. "*" matches any version and is returned as 0.
+// When the request cannot go ahead it writes the response itself (428 for
+// a missing header, 412 for a tag that can never match) and reports false.
+func ifMatchVersion(c *gin.Context) (int, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+		return 0, false
+	}
+	if header == "*" {
+		return 0, true
+	}
+
+	// Weak tags never match under the strong comparison If-Match uses
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(header, `"`), `"`))
+	if err != nil || version <= 0 || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": model.ErrVersionMismatch.Error()})
+		return 0, false
+	}
+
+	return version, true
+}
+
+// updateErrorStatus maps a failed conditional update to 412 when the
+// stored version had moved on, and to fallback otherwise.
+func updateErrorStatus(err error, fallback int) int {
+	if errors.Is(err, model.ErrVersionMismatch) {
+		return http.StatusPreconditionFailed
+	}
+	return fallback
+}
@@ -2,16 +2,27 @@ package handler
 
 import (
 	"fmt"
+	"net/http"
 
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type Handlers struct {
-	User     *UserHandler
-	Product  *ProductHandler
-	Category *CategoryHandler
-	Order    *OrderHandler
+	User           *UserHandler
+	Product        *ProductHandler
+	Category       *CategoryHandler
+	Order          *OrderHandler
+	Review         *ReviewHandler
+	Catalog        *CatalogHandler
+	Inventory      *InventoryHandler
+	Warehouse      *WarehouseHandler
+	Notification   *NotificationHandler
+	Price          *PriceHandler
+	Attribute      *AttributeHandler
+	Recommendation *RecommendationHandler
+	Wishlist       *WishlistHandler
 }
 
 func getUserIDFromContext(c *gin.Context) (uuid.UUID, error) {
@@ -28,16 +39,68 @@ func getUserIDFromContext(c *gin.Context) (uuid.UUID, error) {
 	return userID, nil
 }
 
-func isAdminUser(c *gin.Context) bool {
-	role, exists := c.Get("role")
+func getSessionIDFromContext(c *gin.Context) (uuid.UUID, error) {
+	sessionIDValue, exists := c.Get("session_id")
+	if !exists {
+		return uuid.Nil, fmt.Errorf("session_id not found in context")
+	}
+
+	sessionID, ok := sessionIDValue.(uuid.UUID)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("invalid session_id type")
+	}
+
+	return sessionID, nil
+}
+
+// clientInfo describes the client making the request, for session and
+// login records.
+func clientInfo(c *gin.Context) model.ClientInfo {
+	return model.ClientInfo{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// hasPermission reports whether the request's token grants the
+// permission, for handlers that serve both customers and staff.
+func hasPermission(c *gin.Context, permission model.Permission) bool {
+	granted, exists := c.Get("permissions")
 	if !exists {
 		return false
 	}
 
-	roleStr, ok := role.(string)
+	permissions, ok := granted.([]model.Permission)
 	if !ok {
 		return false
 	}
 
-	return roleStr == "admin"
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readMergePatch returns the body of a PATCH request, which must be sent as
+// application/merge-patch+json (plain application/json is accepted too). It
+// writes the error response itself and reports false when the body is
+// unusable.
+func readMergePatch(c *gin.Context) ([]byte, bool) {
+	switch c.ContentType() {
+	case "application/merge-patch+json", "application/json":
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "PATCH requires Content-Type application/merge-patch+json"})
+		return nil, false
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return patch, true
 }
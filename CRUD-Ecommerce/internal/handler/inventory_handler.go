@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type InventoryHandler struct {
+	service service.InventoryService
+}
+
+func NewInventoryHandler(service service.InventoryService) *InventoryHandler {
+	return &InventoryHandler{service: service}
+}
+
+func (h *InventoryHandler) Adjust(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var req model.InventoryAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	movement, err := h.service.Adjust(productID, userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"movement": movement})
+}
+
+func (h *InventoryHandler) GetMovements(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	movements, err := h.service.GetMovements(productID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"movements": movements})
+}
+
+func (h *InventoryHandler) GetAlerts(c *gin.Context) {
+	alerts, err := h.service.GetAlerts(model.StockAlertStatus(c.Query("status")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
+
+func (h *InventoryHandler) AcknowledgeAlert(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert ID"})
+		return
+	}
+
+	alert, err := h.service.AcknowledgeAlert(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alert": alert})
+}
+
+func (h *InventoryHandler) GetProductInventory(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	inventory, err := h.service.GetProductInventory(productID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"inventory": inventory})
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
@@ -9,6 +10,14 @@ import (
 	"github.com/google/uuid"
 )
 
+// createOrderErrorStatus is the status for a failure to place an order.
+func createOrderErrorStatus(err error) int {
+	if errors.Is(err, model.ErrEmailNotVerified) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
 type OrderHandler struct {
 	service service.OrderService
 }
@@ -32,10 +41,11 @@ func (h *OrderHandler) Create(c *gin.Context) {
 
 	order, err := h.service.Create(userID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(createOrderErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c, order.Version)
 	c.JSON(http.StatusCreated, gin.H{"order": order})
 }
 
@@ -52,14 +62,15 @@ func (h *OrderHandler) GetByID(c *gin.Context) {
 		return
 	}
 
-	isAdmin := isAdminUser(c)
+	readAll := hasPermission(c, model.PermissionOrdersReadAll)
 
-	order, err := h.service.GetByID(orderID, userID, isAdmin)
+	order, err := h.service.GetByID(orderID, userID, readAll)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c, order.Version)
 	c.JSON(http.StatusOK, gin.H{"order": order})
 }
 
@@ -90,24 +101,36 @@ func (h *OrderHandler) GetAllOrders(c *gin.Context) {
 }
 
 func (h *OrderHandler) UpdateStatus(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
 	orderID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
 		return
 	}
 
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
 	var req model.OrderUpdateStatusRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	order, err := h.service.UpdateStatus(orderID, req.Status)
+	order, err := h.service.UpdateStatus(orderID, userID, version, req.Status)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(updateErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c, order.Version)
 	c.JSON(http.StatusOK, gin.H{"order": order})
 }
 
@@ -124,9 +147,9 @@ func (h *OrderHandler) Cancel(c *gin.Context) {
 		return
 	}
 
-	isAdmin := isAdminUser(c)
+	cancelAny := hasPermission(c, model.PermissionOrdersUpdateStatus)
 
-	if err := h.service.Cancel(orderID, userID, isAdmin); err != nil {
+	if err := h.service.Cancel(orderID, userID, cancelAny); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PriceHandler struct {
+	service service.PriceService
+}
+
+func NewPriceHandler(service service.PriceService) *PriceHandler {
+	return &PriceHandler{service: service}
+}
+
+func (h *PriceHandler) Schedule(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var req model.ProductPriceCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	price, err := h.service.Schedule(productID, userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"price": price})
+}
+
+func (h *PriceHandler) GetHistory(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	prices, err := h.service.GetHistory(productID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"prices": prices})
+}
+
+func (h *PriceHandler) Cancel(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	priceID, err := uuid.Parse(c.Param("priceId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid price ID"})
+		return
+	}
+
+	if err := h.service.Cancel(productID, priceID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "scheduled price cancelled"})
+}
@@ -1,7 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"path"
+	"regexp"
+	"sort"
 	"strconv"
 
 	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
@@ -19,38 +23,171 @@ func NewProductHandler(service service.ProductService) *ProductHandler {
 }
 
 func (h *ProductHandler) Create(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
 	var req model.ProductCreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	product, err := h.service.Create(&req)
+	product, err := h.service.Create(&req, userID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c, product.Version)
 	c.JSON(http.StatusCreated, gin.H{"product": product})
 }
 
 func (h *ProductHandler) GetByID(c *gin.Context) {
+	h.getByID(c, false)
+}
+
+// GetByIDAdmin returns a product in any lifecycle state, so that drafts can
+// be reviewed before they are published.
+func (h *ProductHandler) GetByIDAdmin(c *gin.Context) {
+	h.getByID(c, true)
+}
+
+func (h *ProductHandler) getByID(c *gin.Context, anyStatus bool) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
 		return
 	}
 
-	product, err := h.service.GetByID(id)
+	product, err := h.service.GetByID(id, anyStatus)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c, product.Version)
+	c.JSON(http.StatusOK, gin.H{"product": product})
+}
+
+// GetBySlug returns a product by slug, or redirects permanently to the
+// product's current slug when an old one is requested.
+func (h *ProductHandler) GetBySlug(c *gin.Context) {
+	product, current, err := h.service.GetBySlug(c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if current != "" {
+		c.Redirect(http.StatusMovedPermanently, path.Join(path.Dir(c.Request.URL.Path), current))
+		return
+	}
+
+	setETag(c, product.Version)
 	c.JSON(http.StatusOK, gin.H{"product": product})
 }
 
 func (h *ProductHandler) GetAll(c *gin.Context) {
+	params := parseProductQueryParams(c)
+	params.Status = model.ProductStatusActive
+
+	h.list(c, params)
+}
+
+// GetAllAdmin lists products in any lifecycle state, optionally filtered by
+// the status query parameter.
+func (h *ProductHandler) GetAllAdmin(c *gin.Context) {
+	params := parseProductQueryParams(c)
+
+	if status := c.Query("status"); status != "" {
+		params.Status = model.ProductStatus(status)
+		if !params.Status.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status: must be one of draft, active, archived"})
+			return
+		}
+	}
+
+	h.list(c, params)
+}
+
+func (h *ProductHandler) list(c *gin.Context, params model.ProductQueryParams) {
+	if c.Query("page") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page is not supported: follow next_cursor to paginate"})
+		return
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		params.Sort = model.ProductSort(sort)
+		if !params.Sort.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort: must be one of price, -price, name, newest, popularity, rating, relevance"})
+			return
+		}
+	}
+
+	if token := c.Query("cursor"); token != "" {
+		cursor, err := model.DecodeCursor(token)
+		if err != nil || !model.ProductSort(cursor.Sort).IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+
+		// A cursor carries its sort order; an explicit sort must agree with it
+		if params.Sort == "" {
+			params.Sort = model.ProductSort(cursor.Sort)
+		} else if string(params.Sort) != cursor.Sort {
+			c.JSON(http.StatusBadRequest, gin.H{"error": model.ErrCursorMismatch.Error()})
+			return
+		}
+		params.Cursor = cursor
+	}
+
+	page, err := h.service.GetAll(params)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, model.ErrCursorMismatch) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	page.Pagination.Next = pageLink(c, page.Pagination.NextCursor)
+	page.Pagination.Prev = pageLink(c, page.Pagination.PrevCursor)
+
+	c.JSON(http.StatusOK, page)
+}
+
+// pageLink rebuilds the current request URL with the given cursor.
+func pageLink(c *gin.Context, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+
+	query := c.Request.URL.Query()
+	query.Set("cursor", cursor)
+	query.Del("page")
+
+	return c.Request.URL.Path + "?" + query.Encode()
+}
+
+func (h *ProductHandler) Search(c *gin.Context) {
+	params := parseProductQueryParams(c)
+	params.Search = c.Query("q")
+	params.Status = model.ProductStatusActive
+
+	response, err := h.service.Search(params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func parseProductQueryParams(c *gin.Context) model.ProductQueryParams {
 	params := model.ProductQueryParams{}
 
 	if page := c.Query("page"); page != "" {
@@ -77,17 +214,75 @@ func (h *ProductHandler) GetAll(c *gin.Context) {
 		}
 	}
 
+	if minRating := c.Query("min_rating"); minRating != "" {
+		if mr, err := strconv.ParseFloat(minRating, 64); err == nil {
+			params.MinRating = mr
+		}
+	}
+
+	if categoryID := c.Query("category_id"); categoryID != "" {
+		if id, err := uuid.Parse(categoryID); err == nil {
+			params.CategoryID = id
+		}
+	}
+
+	if descendants := c.Query("include_descendants"); descendants != "" {
+		if b, err := strconv.ParseBool(descendants); err == nil {
+			params.IncludeDescendants = b
+		}
+	}
+
+	if inStock := c.Query("in_stock"); inStock != "" {
+		if b, err := strconv.ParseBool(inStock); err == nil {
+			params.InStock = &b
+		}
+	}
+
 	if search := c.Query("search"); search != "" {
 		params.Search = search
 	}
 
-	products, err := h.service.GetAll(params)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	params.Attributes = parseAttributeFilters(c)
+
+	return params
+}
+
+// attributeFilterPattern matches attr[code] and attr[code][op] query keys.
+var attributeFilterPattern = regexp.MustCompile(`^attr\[([a-z0-9_]+)\](?:\[([a-z]+)\])?$`)
+
+// parseAttributeFilters reads attribute filters such as attr[ram_gb][gte]=16
+// or attr[color][in]=red,blue. A missing operator means eq; unknown
+// operators are ignored.
+func parseAttributeFilters(c *gin.Context) []model.AttributeFilter {
+	var filters []model.AttributeFilter
+	for key, values := range c.Request.URL.Query() {
+		match := attributeFilterPattern.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+
+		op := model.AttributeFilterOp(match[2])
+		if op == "" {
+			op = model.AttributeFilterEq
+		}
+		if !op.IsValid() {
+			continue
+		}
+
+		for _, value := range values {
+			filters = append(filters, model.AttributeFilter{Code: match[1], Op: op, Value: value})
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"products": products})
+	// Keep the generated SQL stable for a given URL
+	sort.Slice(filters, func(i, j int) bool {
+		if filters[i].Code != filters[j].Code {
+			return filters[i].Code < filters[j].Code
+		}
+		return filters[i].Op < filters[j].Op
+	})
+
+	return filters
 }
 
 func (h *ProductHandler) GetByCategory(c *gin.Context) {
@@ -107,24 +302,99 @@ func (h *ProductHandler) GetByCategory(c *gin.Context) {
 }
 
 func (h *ProductHandler) Update(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
 		return
 	}
 
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
 	var req model.ProductUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	product, err := h.service.Update(id, &req)
+	product, err := h.service.Update(id, version, &req, userID)
 	if err != nil {
+		c.JSON(updateErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c, product.Version)
+	c.JSON(http.StatusOK, gin.H{"product": product})
+}
+
+// Patch applies a JSON merge patch (RFC 7396) to a product.
+func (h *ProductHandler) Patch(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	patch, ok := readMergePatch(c)
+	if !ok {
+		return
+	}
+
+	product, err := h.service.Patch(id, version, patch, userID)
+	if err != nil {
+		c.JSON(updateErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c, product.Version)
+	c.JSON(http.StatusOK, gin.H{"product": product})
+}
+
+// SetComponents replaces the component products of a bundle.
+func (h *ProductHandler) SetComponents(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	var req model.BundleComponentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	product, err := h.service.SetComponents(id, version, &req)
+	if err != nil {
+		c.JSON(updateErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c, product.Version)
 	c.JSON(http.StatusOK, gin.H{"product": product})
 }
 
@@ -140,5 +410,5 @@ func (h *ProductHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "product deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "product archived successfully"})
 }
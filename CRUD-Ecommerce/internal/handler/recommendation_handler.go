@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type RecommendationHandler struct {
+	service service.RecommendationService
+}
+
+func NewRecommendationHandler(service service.RecommendationService) *RecommendationHandler {
+	return &RecommendationHandler{service: service}
+}
+
+func (h *RecommendationHandler) GetRelated(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	related, err := h.service.GetRelated(productID, limit)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"related": related})
+}
+
+func (h *RecommendationHandler) Recompute(c *gin.Context) {
+	run, err := h.service.Recompute()
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"run": run})
+}
@@ -1,13 +1,29 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
 	"github.com/ekas-7/CRUD-Ecommerce/internal/service"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// loginError responds to a failed login step. Throttled logins get 429 and
+// a Retry-After header.
+func loginError(c *gin.Context, err error) {
+	var throttled *model.LoginThrottledError
+	if errors.As(err, &throttled) {
+		c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+}
+
 type UserHandler struct {
 	service service.UserService
 }
@@ -39,7 +55,220 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := h.service.Login(&req)
+	response, challenge, err := h.service.Login(&req, clientInfo(c))
+	if err != nil {
+		loginError(c, err)
+		return
+	}
+
+	// Users with MFA finish signing in at /auth/mfa
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CompleteMFALogin is the second step of a login for users with MFA.
+func (h *UserHandler) CompleteMFALogin(c *gin.Context) {
+	var req model.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.service.CompleteMFALogin(&req, clientInfo(c))
+	if err != nil {
+		loginError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SetupMFA returns a new TOTP secret and provisioning URI.
+func (h *UserHandler) SetupMFA(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	setup, err := h.service.SetupMFA(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// EnableMFA confirms enrolment with a code and returns recovery codes.
+func (h *UserHandler) EnableMFA(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionID, err := getSessionIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req model.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.service.EnableMFA(userID, sessionID, req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, codes)
+}
+
+// DisableMFA turns MFA off.
+func (h *UserHandler) DisableMFA(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req model.MFADisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.DisableMFA(userID, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes.
+func (h *UserHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req model.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, codes)
+}
+
+// VerifyEmail confirms an email address with the token from the
+// verification email.
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var req model.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service.VerifyEmail(req.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// ResendVerification emails a new verification link.
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	var req model.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.service.ResendVerification(req.Email)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the account exists and is not yet verified, a verification email has been sent"})
+}
+
+// ForgotPassword emails a password reset link.
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var req model.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.service.ForgotPassword(req.Email)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if an account exists for this email, a password reset link has been sent"})
+}
+
+// ResetPassword sets a new password using the token from a reset email.
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req model.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ResetPassword(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password has been reset; sign in with the new password"})
+}
+
+// ChangePassword changes the signed-in user's password.
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req model.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ChangePassword(userID, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password changed; sign in with the new password"})
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token.
+func (h *UserHandler) Refresh(c *gin.Context) {
+	var req model.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.service.Refresh(req.RefreshToken)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -48,6 +277,22 @@ func (h *UserHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// Logout revokes the session of the given refresh token.
+func (h *UserHandler) Logout(c *gin.Context) {
+	var req model.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Logout(req.RefreshToken); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
+}
+
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -86,6 +331,28 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"user": user})
 }
 
+// PatchProfile applies a JSON merge patch (RFC 7396) to the profile.
+func (h *UserHandler) PatchProfile(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	patch, ok := readMergePatch(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.PatchProfile(userID, patch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
 func (h *UserHandler) DeleteAccount(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -100,3 +367,89 @@ func (h *UserHandler) DeleteAccount(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "account deleted successfully"})
 }
+
+// GetLoginHistory lists the user's recent login attempts.
+func (h *UserHandler) GetLoginHistory(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	attempts, err := h.service.GetLoginHistory(userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"logins": attempts})
+}
+
+// GetLockedAccounts lists accounts locked out by failed logins.
+func (h *UserHandler) GetLockedAccounts(c *gin.Context) {
+	accounts, err := h.service.GetLockedAccounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+// UnlockAccount lifts a lockout before it expires.
+func (h *UserHandler) UnlockAccount(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	if err := h.service.UnlockAccount(userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "account unlocked"})
+}
+
+// GetRoles lists the roles and the permissions they grant.
+func (h *UserHandler) GetRoles(c *gin.Context) {
+	roles, err := h.service.GetRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// AssignRole gives a user a different role.
+func (h *UserHandler) AssignRole(c *gin.Context) {
+	actorID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req model.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service.AssignRole(actorID, userID, req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
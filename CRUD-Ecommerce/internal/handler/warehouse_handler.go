@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type WarehouseHandler struct {
+	service service.WarehouseService
+}
+
+func NewWarehouseHandler(service service.WarehouseService) *WarehouseHandler {
+	return &WarehouseHandler{service: service}
+}
+
+func (h *WarehouseHandler) Create(c *gin.Context) {
+	var req model.WarehouseCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warehouse, err := h.service.Create(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"warehouse": warehouse})
+}
+
+func (h *WarehouseHandler) GetAll(c *gin.Context) {
+	warehouses, err := h.service.GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"warehouses": warehouses})
+}
+
+func (h *WarehouseHandler) Update(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid warehouse ID"})
+		return
+	}
+
+	var req model.WarehouseUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warehouse, err := h.service.Update(id, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"warehouse": warehouse})
+}
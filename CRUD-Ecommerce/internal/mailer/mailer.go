@@ -0,0 +1,99 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // plain text
+}
+
+// Mailer delivers transactional email.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer sends mail through an SMTP server, authenticating with PLAIN
+// auth when a username is set.
+type SMTPMailer struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string
+}
+
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		Addr:     host + ":" + port,
+		Username: username,
+		Password: password,
+		From:     from,
+	}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host := strings.Split(m.Addr, ":")[0]
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	to := headerValue(msg.To)
+	if err := smtp.SendMail(m.Addr, auth, m.From, []string{to}, format(m.From, msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// OutboxMailer writes each message to a .eml file in Dir instead of sending
+// it, so mail can be inspected locally without an SMTP server.
+type OutboxMailer struct {
+	Dir  string
+	From string
+}
+
+func NewOutboxMailer(dir, from string) *OutboxMailer {
+	return &OutboxMailer{Dir: dir, From: from}
+}
+
+func (m *OutboxMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail outbox: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), uuid.NewString()[:8])
+	if err := os.WriteFile(filepath.Join(m.Dir, name), format(m.From, msg), 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+
+	return nil
+}
+
+// format renders msg as an RFC 5322 message.
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// headerValue strips line breaks so a value cannot add headers of its own.
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -4,12 +4,23 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// SessionChecker reports whether the session an access token was issued
+// for is still live.
+type SessionChecker interface {
+	IsSessionActive(sessionID uuid.UUID) (bool, error)
+}
+
+// AuthMiddleware authenticates requests by their bearer token and makes the
+// permissions it carries available to RequirePermission. When
+// requireAdminMFA is set, staff whose session was not confirmed with a
+// second factor get no permissions.
+func AuthMiddleware(jwtSecret string, sessions SessionChecker, requireAdminMFA bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -61,30 +72,89 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
+		// Tokens belong to a session, which logout or refresh token reuse
+		// revokes before the token expires
+		sessionIDStr, _ := claims["sid"].(string)
+		sessionID, err := uuid.Parse(sessionIDStr)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+			c.Abort()
+			return
+		}
+
+		active, err := sessions.IsSessionActive(sessionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session has been revoked"})
+			c.Abort()
+			return
+		}
+
 		role, _ := claims["role"].(string)
+		permissions := permissionsFromClaims(claims)
+		mfa, _ := claims["mfa"].(bool)
+		if requireAdminMFA && len(permissions) > 0 && !mfa {
+			permissions = nil
+			c.Set("mfa_required", true)
+		}
 
 		c.Set("user_id", userID)
+		c.Set("session_id", sessionID)
 		c.Set("role", role)
+		c.Set("permissions", permissions)
 		c.Next()
 	}
 }
 
-func AdminMiddleware() gin.HandlerFunc {
+// RequirePermission lets a request through only if its token grants every
+// one of the given permissions.
+func RequirePermission(required ...model.Permission) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{"error": "access denied: role not found"})
+		// Set by AuthMiddleware for staff who have not signed in with MFA
+		if c.GetBool("mfa_required") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied: staff access requires signing in with MFA"})
 			c.Abort()
 			return
 		}
 
-		roleStr, ok := role.(string)
-		if !ok || roleStr != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "access denied: admin privileges required"})
-			c.Abort()
-			return
+		granted, _ := c.Get("permissions")
+		permissions, _ := granted.([]model.Permission)
+
+		for _, permission := range required {
+			if !hasPermission(permissions, permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "access denied: " + string(permission) + " permission required"})
+				c.Abort()
+				return
+			}
 		}
 
 		c.Next()
 	}
 }
+
+// permissionsFromClaims reads the "perms" claim, a JSON array of strings.
+func permissionsFromClaims(claims jwt.MapClaims) []model.Permission {
+	values, _ := claims["perms"].([]interface{})
+
+	permissions := make([]model.Permission, 0, len(values))
+	for _, value := range values {
+		if name, ok := value.(string); ok {
+			permissions = append(permissions, model.Permission(name))
+		}
+	}
+
+	return permissions
+}
+
+func hasPermission(permissions []model.Permission, permission model.Permission) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
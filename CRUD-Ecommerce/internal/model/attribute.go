@@ -0,0 +1,94 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AttributeType string
+
+const (
+	AttributeTypeInteger AttributeType = "integer"
+	AttributeTypeDecimal AttributeType = "decimal"
+	AttributeTypeBoolean AttributeType = "boolean"
+	AttributeTypeText    AttributeType = "text"
+	AttributeTypeEnum    AttributeType = "enum"
+)
+
+func (t AttributeType) IsValid() bool {
+	switch t {
+	case AttributeTypeInteger, AttributeTypeDecimal, AttributeTypeBoolean, AttributeTypeText, AttributeTypeEnum:
+		return true
+	}
+	return false
+}
+
+// IsNumeric reports whether values of the type support range filters.
+func (t AttributeType) IsNumeric() bool {
+	return t == AttributeTypeInteger || t == AttributeTypeDecimal
+}
+
+// AttributeDefinition describes an attribute that products in a category
+// may carry, e.g. "ram_gb", an integer measured in GB.
+type AttributeDefinition struct {
+	ID         uuid.UUID     `json:"id"`
+	CategoryID uuid.UUID     `json:"category_id"`
+	Code       string        `json:"code"`
+	Name       string        `json:"name"`
+	Type       AttributeType `json:"type"`
+	Unit       string        `json:"unit,omitempty"`
+	Options    []string      `json:"options,omitempty"` // allowed values for enum attributes
+	Required   bool          `json:"required"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+type AttributeDefinitionCreateRequest struct {
+	Code     string        `json:"code" validate:"required"`
+	Name     string        `json:"name" validate:"required"`
+	Type     AttributeType `json:"type" validate:"required,oneof=integer decimal boolean text enum"`
+	Unit     string        `json:"unit"`
+	Options  []string      `json:"options"`
+	Required bool          `json:"required"`
+}
+
+// AttributeValue is a validated value of one attribute for one product. The
+// text form is always set so that equality filters work across types.
+type AttributeValue struct {
+	AttributeID uuid.UUID
+	Text        string
+	Number      *float64
+	Bool        *bool
+}
+
+type AttributeFilterOp string
+
+const (
+	AttributeFilterEq  AttributeFilterOp = "eq"
+	AttributeFilterIn  AttributeFilterOp = "in"
+	AttributeFilterGt  AttributeFilterOp = "gt"
+	AttributeFilterGte AttributeFilterOp = "gte"
+	AttributeFilterLt  AttributeFilterOp = "lt"
+	AttributeFilterLte AttributeFilterOp = "lte"
+)
+
+func (o AttributeFilterOp) IsValid() bool {
+	switch o {
+	case AttributeFilterEq, AttributeFilterIn, AttributeFilterGt, AttributeFilterGte, AttributeFilterLt, AttributeFilterLte:
+		return true
+	}
+	return false
+}
+
+// AttributeFilter is one attr[code][op]=value query parameter.
+type AttributeFilter struct {
+	Code  string
+	Op    AttributeFilterOp
+	Value string
+}
+
+type AttributeFacet struct {
+	Code   string       `json:"code"`
+	Name   string       `json:"name"`
+	Values []FacetCount `json:"values"`
+}
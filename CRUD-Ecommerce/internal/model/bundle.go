@@ -0,0 +1,19 @@
+package model
+
+import "github.com/google/uuid"
+
+// BundleComponent is one product, and how many of it, contained in a bundle.
+type BundleComponent struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	Product   *Product  `json:"product,omitempty"`
+}
+
+type BundleComponentInput struct {
+	ProductID uuid.UUID `json:"product_id" validate:"required"`
+	Quantity  int       `json:"quantity" validate:"required,gt=0"`
+}
+
+type BundleComponentsRequest struct {
+	Components []BundleComponentInput `json:"components" validate:"required,min=1"`
+}
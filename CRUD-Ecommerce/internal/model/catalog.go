@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CatalogFormat string
+
+const (
+	CatalogFormatCSV    CatalogFormat = "csv"
+	CatalogFormatNDJSON CatalogFormat = "ndjson"
+)
+
+type ImportJobStatus string
+
+const (
+	ImportJobStatusPending   ImportJobStatus = "pending"
+	ImportJobStatusRunning   ImportJobStatus = "running"
+	ImportJobStatusCompleted ImportJobStatus = "completed"
+	ImportJobStatusFailed    ImportJobStatus = "failed"
+
+	// ImportJobStatusInterrupted means the server stopped before the job
+	// finished; the rows already processed were saved.
+	ImportJobStatusInterrupted ImportJobStatus = "interrupted"
+)
+
+// CatalogRow is one product in an import or export file. Categories are
+// referenced by name so files can move between environments.
+type CatalogRow struct {
+	SKU         string  `json:"sku"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	Stock       int     `json:"stock"`
+	Category    string  `json:"category"`
+	ImageURL    string  `json:"image_url"`
+	Status      string  `json:"status"`
+}
+
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	SKU   string `json:"sku"`
+	Error string `json:"error"`
+}
+
+type ImportJob struct {
+	ID            uuid.UUID        `json:"id"`
+	Format        CatalogFormat    `json:"format"`
+	Status        ImportJobStatus  `json:"status"`
+	TotalRows     int              `json:"total_rows"`
+	ProcessedRows int              `json:"processed_rows"`
+	CreatedCount  int              `json:"created_count"`
+	UpdatedCount  int              `json:"updated_count"`
+	FailedCount   int              `json:"failed_count"`
+	Errors        []ImportRowError `json:"-"`
+	Message       string           `json:"message,omitempty"`
+	CreatedBy     uuid.UUID        `json:"created_by"`
+	CreatedAt     time.Time        `json:"created_at"`
+	StartedAt     *time.Time       `json:"started_at,omitempty"`
+	FinishedAt    *time.Time       `json:"finished_at,omitempty"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
@@ -6,20 +6,64 @@ import (
 	"github.com/google/uuid"
 )
 
+// MaxCategoryDepth is how many levels deep categories can be nested; top-level
+// categories are at depth 1.
+const MaxCategoryDepth = 5
+
 type Category struct {
-	ID          uuid.UUID `json:"id"`
-	Name        string    `json:"name" validate:"required"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uuid.UUID  `json:"id"`
+	ParentID    *uuid.UUID `json:"parent_id"` // nil for top-level categories
+	Name        string     `json:"name" validate:"required"`
+	Slug        string     `json:"slug"`
+	Description string     `json:"description"`
+	Version     int        `json:"version"` // bumped on every change, exposed as the ETag
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// CategoryDeleteResult reports what deleting a category did to its
+// products. Attribute values move to the target category's attribute with
+// the same code and type; the rest are dropped and counted by code.
+type CategoryDeleteResult struct {
+	ProductsMoved        int            `json:"products_moved"`
+	AttributeValuesMoved int            `json:"attribute_values_moved"`
+	DroppedAttributes    map[string]int `json:"dropped_attributes"`
 }
 
 type CategoryCreateRequest struct {
-	Name        string `json:"name" validate:"required"`
-	Description string `json:"description"`
+	ParentID    *uuid.UUID `json:"parent_id"`
+	Name        string     `json:"name" validate:"required"`
+	Slug        string     `json:"slug"` // generated from the name when empty
+	Description string     `json:"description"`
 }
 
 type CategoryUpdateRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	ParentID    *uuid.UUID `json:"parent_id"` // moves the category; use PATCH with null to move it to the top level
+	Name        string     `json:"name"`
+	Slug        string     `json:"slug"` // regenerated from a new name when empty
+	Description string     `json:"description"`
+}
+
+// CategoryPatchDocument is the editable view of a category that PATCH merge
+// patches are applied to.
+type CategoryPatchDocument struct {
+	ParentID    *uuid.UUID `json:"parent_id"`
+	Name        string     `json:"name"`
+	Slug        string     `json:"slug"`
+	Description string     `json:"description"`
+}
+
+// CategoryTreeNode is a category with its subcategories, as returned by the
+// category tree endpoint.
+type CategoryTreeNode struct {
+	Category
+	Children []CategoryTreeNode `json:"children"`
+}
+
+// Breadcrumb is one step on the path from a top-level category down to a
+// product's category.
+type Breadcrumb struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Slug string    `json:"slug"`
 }
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type InventoryReason string
+
+const (
+	InventoryReasonInitial      InventoryReason = "initial"
+	InventoryReasonSale         InventoryReason = "sale"
+	InventoryReasonCancellation InventoryReason = "cancellation"
+	InventoryReasonAdjustment   InventoryReason = "adjustment"
+	InventoryReasonReturn       InventoryReason = "return"
+	InventoryReasonImport       InventoryReason = "import"
+)
+
+// InventoryMovement is one signed change to a product's stock in one
+// warehouse. A product's stock always equals the sum of its movements.
+type InventoryMovement struct {
+	ID          uuid.UUID       `json:"id"`
+	ProductID   uuid.UUID       `json:"product_id"`
+	WarehouseID *uuid.UUID      `json:"warehouse_id,omitempty"` // nil means the default warehouse
+	Quantity    int             `json:"quantity"`
+	Reason      InventoryReason `json:"reason"`
+	ReferenceID *uuid.UUID      `json:"reference_id,omitempty"` // order or import job
+	ActorID     *uuid.UUID      `json:"actor_id,omitempty"`
+	Note        string          `json:"note"`
+	StockAfter  int             `json:"stock_after"` // across all warehouses
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type InventoryAdjustmentRequest struct {
+	WarehouseID *uuid.UUID      `json:"warehouse_id"`
+	Quantity    int             `json:"quantity" validate:"required"`
+	Reason      InventoryReason `json:"reason" validate:"required,oneof=adjustment return"`
+	Note        string          `json:"note"`
+}
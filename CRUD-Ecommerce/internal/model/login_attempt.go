@@ -0,0 +1,59 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginResult says how a login attempt ended.
+type LoginResult string
+
+const (
+	LoginResultSuccess        LoginResult = "success"
+	LoginResultBadPassword    LoginResult = "invalid_password"
+	LoginResultBadMFACode     LoginResult = "invalid_mfa_code"
+	LoginResultUnknownAccount LoginResult = "unknown_account"
+	LoginResultLocked         LoginResult = "locked"       // the account was locked out
+	LoginResultThrottled      LoginResult = "ip_throttled" // too many failures from the address
+)
+
+// LoginAttempt records one password or MFA step of a login, successful or
+// not. Attempts for unknown emails have no UserID.
+type LoginAttempt struct {
+	ID        uuid.UUID   `json:"id"`
+	UserID    *uuid.UUID  `json:"-"`
+	Email     string      `json:"-"`
+	IPAddress string      `json:"ip_address"`
+	UserAgent string      `json:"user_agent"`
+	Result    LoginResult `json:"result"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// LockedAccount is an account that is locked out after failed logins.
+type LockedAccount struct {
+	User         UserResponse `json:"user"`
+	FailedLogins int          `json:"failed_logins"`
+	LockedUntil  time.Time    `json:"locked_until"`
+}
+
+// LoginThrottledError is returned while an account or client address is
+// locked out after too many failed logins.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts; try again in %d seconds", e.RetryAfterSeconds())
+}
+
+// RetryAfterSeconds is RetryAfter rounded up to whole seconds, at least 1.
+func (e *LoginThrottledError) RetryAfterSeconds() int {
+	seconds := int(math.Ceil(e.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
@@ -0,0 +1,39 @@
+package model
+
+// MFASetupResponse carries a new TOTP secret for the user to add to an
+// authenticator app, as text or by scanning ProvisioningURI as a QR code.
+type MFASetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// MFACodeRequest carries a code from the authenticator app, or a recovery
+// code where one is accepted.
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type MFADisableRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// MFARecoveryCodesResponse lists recovery codes. They are shown once; only
+// their hashes are stored.
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAChallenge is the first step of a login for a user with MFA enabled:
+// the password was right, and MFAToken is exchanged together with a code
+// at /auth/mfa for the usual LoginResponse.
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"` // seconds
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"` // TOTP or recovery code
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NotificationType string
+
+const (
+	NotificationTypeBackInStock NotificationType = "back_in_stock"
+	NotificationTypePriceDrop   NotificationType = "price_drop"
+)
+
+type Notification struct {
+	ID        uuid.UUID        `json:"id"`
+	UserID    uuid.UUID        `json:"user_id"`
+	Type      NotificationType `json:"type"`
+	ProductID *uuid.UUID       `json:"product_id,omitempty"`
+	Message   string           `json:"message"`
+	ReadAt    *time.Time       `json:"read_at,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
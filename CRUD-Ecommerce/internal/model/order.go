@@ -17,27 +17,33 @@ const (
 )
 
 type Order struct {
-	ID         uuid.UUID   `json:"id"`
-	UserID     uuid.UUID   `json:"user_id"`
-	Status     OrderStatus `json:"status"`
-	TotalPrice float64     `json:"total_price"`
-	Items      []OrderItem `json:"items"`
-	CreatedAt  time.Time   `json:"created_at"`
-	UpdatedAt  time.Time   `json:"updated_at"`
+	ID             uuid.UUID   `json:"id"`
+	UserID         uuid.UUID   `json:"user_id"`
+	Status         OrderStatus `json:"status"`
+	TotalPrice     float64     `json:"total_price"`
+	ShippingRegion string      `json:"shipping_region"`
+	Items          []OrderItem `json:"items"`
+	Version        int         `json:"version"` // bumped on every change, exposed as the ETag
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
 }
 
 type OrderItem struct {
-	ID        uuid.UUID `json:"id"`
-	OrderID   uuid.UUID `json:"order_id"`
-	ProductID uuid.UUID `json:"product_id"`
-	Product   *Product  `json:"product,omitempty"`
-	Quantity  int       `json:"quantity" validate:"required,gt=0"`
-	Price     float64   `json:"price"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          uuid.UUID         `json:"id"`
+	OrderID     uuid.UUID         `json:"order_id"`
+	ProductID   uuid.UUID         `json:"product_id"`
+	ProductName string            `json:"product_name"` // snapshot at purchase time
+	ProductSKU  string            `json:"product_sku"`  // snapshot at purchase time
+	Product     *Product          `json:"product,omitempty"`
+	Quantity    int               `json:"quantity" validate:"required,gt=0"`
+	Price       float64           `json:"price"`
+	Allocations []OrderAllocation `json:"allocations"`
+	CreatedAt   time.Time         `json:"created_at"`
 }
 
 type OrderCreateRequest struct {
-	Items []OrderItemRequest `json:"items" validate:"required,dive"`
+	Items          []OrderItemRequest `json:"items" validate:"required,dive"`
+	ShippingRegion string             `json:"shipping_region"`
 }
 
 type OrderItemRequest struct {
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Cursor marks a position in a keyset-paginated listing. It is handed to
+// clients as an opaque token and must be replayed with the same sort order.
+type Cursor struct {
+	Sort     string    `json:"s"`
+	Value    string    `json:"v"`
+	ID       uuid.UUID `json:"id"`
+	Backward bool      `json:"b,omitempty"`
+}
+
+// ErrCursorMismatch is returned when a cursor is replayed against a different
+// sort order than the one it was issued for.
+var ErrCursorMismatch = errors.New("cursor does not match sort order")
+
+type Pagination struct {
+	Total      int    `json:"total"`
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	cursor := &Cursor{}
+	if err := json.Unmarshal(data, cursor); err != nil || cursor.ID == uuid.Nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return cursor, nil
+}
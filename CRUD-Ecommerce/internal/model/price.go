@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PriceKind string
+
+const (
+	// PriceKindBase records a change to the product's regular price
+	PriceKindBase PriceKind = "base"
+	// PriceKindScheduled overrides the regular price for a time window
+	PriceKindScheduled PriceKind = "scheduled"
+)
+
+// ProductPrice is one entry in a product's price history. Scheduled prices
+// without an end stay in effect until the regular price next changes.
+type ProductPrice struct {
+	ID             uuid.UUID  `json:"id"`
+	ProductID      uuid.UUID  `json:"product_id"`
+	Kind           PriceKind  `json:"kind"`
+	Price          float64    `json:"price"`
+	CompareAtPrice *float64   `json:"compare_at_price,omitempty"`
+	StartsAt       time.Time  `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	CreatedBy      *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type ProductPriceCreateRequest struct {
+	Price          float64    `json:"price" validate:"required,gt=0"`
+	CompareAtPrice *float64   `json:"compare_at_price"`
+	StartsAt       *time.Time `json:"starts_at"` // defaults to now
+	EndsAt         *time.Time `json:"ends_at"`
+}
@@ -6,41 +6,180 @@ import (
 	"github.com/google/uuid"
 )
 
+type ProductStatus string
+
+const (
+	ProductStatusDraft    ProductStatus = "draft"
+	ProductStatusActive   ProductStatus = "active"
+	ProductStatusArchived ProductStatus = "archived"
+)
+
+func (s ProductStatus) IsValid() bool {
+	switch s {
+	case ProductStatusDraft, ProductStatusActive, ProductStatusArchived:
+		return true
+	}
+	return false
+}
+
+// ProductType distinguishes products that carry their own stock from bundles
+// sold as a set of component products.
+type ProductType string
+
+const (
+	ProductTypeSimple ProductType = "simple"
+	ProductTypeBundle ProductType = "bundle"
+)
+
+func (t ProductType) IsValid() bool {
+	switch t {
+	case ProductTypeSimple, ProductTypeBundle:
+		return true
+	}
+	return false
+}
+
 type Product struct {
-	ID          uuid.UUID `json:"id"`
-	Name        string    `json:"name" validate:"required"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price" validate:"required,gt=0"`
-	Stock       int       `json:"stock" validate:"required,gte=0"`
-	CategoryID  uuid.UUID `json:"category_id" validate:"required"`
-	Category    *Category `json:"category,omitempty"`
-	ImageURL    string    `json:"image_url"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID               uuid.UUID              `json:"id"`
+	SKU              string                 `json:"sku"`
+	Name             string                 `json:"name" validate:"required"`
+	Slug             string                 `json:"slug"`
+	Type             ProductType            `json:"type"`
+	Description      string                 `json:"description"`
+	Price            float64                `json:"price"`                           // price it sells at now, including any scheduled price
+	BasePrice        float64                `json:"base_price"`                      // regular price, as set on create/update
+	CompareAtPrice   *float64               `json:"compare_at_price"`                // regular price while a lower price is in effect
+	Stock            int                    `json:"stock" validate:"required,gte=0"` // for bundles, computed from component stock
+	CategoryID       uuid.UUID              `json:"category_id" validate:"required"`
+	Category         *Category              `json:"category,omitempty"`
+	Breadcrumbs      []Breadcrumb           `json:"breadcrumbs,omitempty"` // top-level category first
+	Components       []BundleComponent      `json:"components,omitempty"`  // bundles only
+	ImageURL         string                 `json:"image_url"`
+	Status           ProductStatus          `json:"status"`
+	ReorderThreshold int                    `json:"reorder_threshold"` // 0 disables low-stock alerts
+	Attributes       map[string]interface{} `json:"attributes"`        // keyed by attribute code
+	AverageRating    float64                `json:"average_rating"`
+	ReviewCount      int                    `json:"review_count"`
+	Version          int                    `json:"version"` // bumped on every change, exposed as the ETag
+	CreatedAt        time.Time              `json:"created_at"`
+	UpdatedAt        time.Time              `json:"updated_at"`
 }
 
 type ProductCreateRequest struct {
-	Name        string    `json:"name" validate:"required"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price" validate:"required,gt=0"`
-	Stock       int       `json:"stock" validate:"required,gte=0"`
-	CategoryID  uuid.UUID `json:"category_id" validate:"required"`
-	ImageURL    string    `json:"image_url"`
+	SKU              string                 `json:"sku"`
+	Name             string                 `json:"name" validate:"required"`
+	Slug             string                 `json:"slug"` // generated from the name when empty
+	Type             ProductType            `json:"type" validate:"omitempty,oneof=simple bundle"`
+	Description      string                 `json:"description"`
+	Price            float64                `json:"price" validate:"required,gt=0"`
+	Stock            int                    `json:"stock" validate:"required,gte=0"` // must be 0 for bundles
+	CategoryID       uuid.UUID              `json:"category_id" validate:"required"`
+	ImageURL         string                 `json:"image_url"`
+	Status           ProductStatus          `json:"status" validate:"omitempty,oneof=draft active archived"`
+	ReorderThreshold int                    `json:"reorder_threshold" validate:"gte=0"`
+	Attributes       map[string]interface{} `json:"attributes"`
+	Components       []BundleComponentInput `json:"components"` // required for bundles
 }
 
 type ProductUpdateRequest struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price" validate:"omitempty,gt=0"`
-	Stock       int     `json:"stock" validate:"omitempty,gte=0"`
-	ImageURL    string  `json:"image_url"`
+	SKU              string                 `json:"sku"`
+	Name             string                 `json:"name"`
+	Slug             string                 `json:"slug"` // regenerated from a new name when empty
+	Description      string                 `json:"description"`
+	Price            float64                `json:"price" validate:"omitempty,gt=0"`
+	Stock            *int                   `json:"stock" validate:"omitempty,gte=0"`
+	ImageURL         string                 `json:"image_url"`
+	Status           ProductStatus          `json:"status" validate:"omitempty,oneof=draft active archived"`
+	ReorderThreshold *int                   `json:"reorder_threshold" validate:"omitempty,gte=0"`
+	Attributes       map[string]interface{} `json:"attributes"` // a null value removes the attribute
+}
+
+// ProductPatchDocument is the editable view of a product that PATCH merge
+// patches are applied to.
+type ProductPatchDocument struct {
+	SKU              string                 `json:"sku"`
+	Name             string                 `json:"name"`
+	Slug             string                 `json:"slug"`
+	Description      string                 `json:"description"`
+	Price            float64                `json:"price"`
+	Stock            int                    `json:"stock"`
+	CategoryID       uuid.UUID              `json:"category_id"`
+	ImageURL         string                 `json:"image_url"`
+	Status           ProductStatus          `json:"status"`
+	ReorderThreshold int                    `json:"reorder_threshold"`
+	Attributes       map[string]interface{} `json:"attributes"`
+}
+
+type ProductSort string
+
+const (
+	ProductSortNewest     ProductSort = "newest"
+	ProductSortPrice      ProductSort = "price"
+	ProductSortPriceDesc  ProductSort = "-price"
+	ProductSortName       ProductSort = "name"
+	ProductSortPopularity ProductSort = "popularity"
+	ProductSortRating     ProductSort = "rating"
+	ProductSortRelevance  ProductSort = "relevance"
+)
+
+func (s ProductSort) IsValid() bool {
+	switch s {
+	case ProductSortNewest, ProductSortPrice, ProductSortPriceDesc,
+		ProductSortName, ProductSortPopularity, ProductSortRating, ProductSortRelevance:
+		return true
+	}
+	return false
 }
 
 type ProductQueryParams struct {
 	Page       int
 	PageSize   int
 	CategoryID uuid.UUID
-	MinPrice   float64
-	MaxPrice   float64
-	Search     string
+	// IncludeDescendants widens CategoryID to its subcategories
+	IncludeDescendants bool
+	MinPrice           float64
+	MaxPrice           float64
+	InStock            *bool
+	Status             ProductStatus
+	MinRating          float64
+	Search             string
+	Sort               ProductSort
+	Cursor             *Cursor
+	Attributes         []AttributeFilter
+}
+
+type ProductPage struct {
+	Products   []Product  `json:"products"`
+	Pagination Pagination `json:"pagination"`
+}
+
+type ProductSearchResult struct {
+	Product
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+	Fuzzy   bool    `json:"fuzzy"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+type PriceRangeFacet struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"` // nil for the open-ended top bucket
+	Count int      `json:"count"`
+}
+
+type ProductFacets struct {
+	Categories   []FacetCount      `json:"categories"`
+	PriceRanges  []PriceRangeFacet `json:"price_ranges"`
+	Availability []FacetCount      `json:"availability"`
+	Attributes   []AttributeFacet  `json:"attributes"`
+}
+
+type ProductSearchResponse struct {
+	Results []ProductSearchResult `json:"results"`
+	Facets  *ProductFacets        `json:"facets"`
 }
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+type RecommendationSource string
+
+const (
+	// RecommendationSourceCoPurchase means the products were bought together
+	RecommendationSourceCoPurchase RecommendationSource = "co_purchase"
+	// RecommendationSourceCategory fills in with products from the same category
+	RecommendationSourceCategory RecommendationSource = "category"
+)
+
+type RelatedProduct struct {
+	Product
+	Score  float64              `json:"score"` // delivered orders containing both products
+	Source RecommendationSource `json:"source"`
+}
+
+type RecommendationRun struct {
+	Pairs      int       `json:"pairs"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
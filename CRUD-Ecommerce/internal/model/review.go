@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReviewStatus string
+
+const (
+	ReviewStatusPending  ReviewStatus = "pending"
+	ReviewStatusApproved ReviewStatus = "approved"
+	ReviewStatusHidden   ReviewStatus = "hidden"
+)
+
+type Review struct {
+	ID         uuid.UUID    `json:"id"`
+	ProductID  uuid.UUID    `json:"product_id"`
+	UserID     uuid.UUID    `json:"user_id"`
+	AuthorName string       `json:"author_name"`
+	Rating     int          `json:"rating"`
+	Title      string       `json:"title"`
+	Body       string       `json:"body"`
+	Status     ReviewStatus `json:"status"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+type ReviewCreateRequest struct {
+	Rating int    `json:"rating" validate:"required,gte=1,lte=5"`
+	Title  string `json:"title"`
+	Body   string `json:"body" validate:"required"`
+}
+
+type ReviewModerateRequest struct {
+	Status ReviewStatus `json:"status" validate:"required,oneof=approved hidden"`
+}
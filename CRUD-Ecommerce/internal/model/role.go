@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// Permission grants access to a group of staff operations.
+type Permission string
+
+const (
+	PermissionCatalogWrite       Permission = "catalog:write"        // products, categories, attributes, prices, imports
+	PermissionInventoryManage    Permission = "inventory:manage"     // stock adjustments, alerts and warehouses
+	PermissionOrdersReadAll      Permission = "orders:read_all"      // any customer's orders
+	PermissionOrdersUpdateStatus Permission = "orders:update_status" // move or cancel any order
+	PermissionReviewsModerate    Permission = "reviews:moderate"     // approve or hide reviews
+	PermissionUsersManage        Permission = "users:manage"         // account lockouts
+	PermissionRolesManage        Permission = "roles:manage"         // assigning roles to users
+)
+
+// RoleUser is the role customers get on registration. It has no
+// permissions; admin, support and warehouse are seeded staff roles.
+const RoleUser = "user"
+
+// Role is a named set of permissions. Every user has exactly one role.
+type Role struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
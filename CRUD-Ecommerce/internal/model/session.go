@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login on one device. Access tokens carry the session ID and
+// stop working once the session is revoked; the session's refresh tokens
+// form one rotation family.
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	MFA        bool       `json:"mfa"` // signed in with a second factor
+}
+
+// ClientInfo identifies the client a request came from.
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
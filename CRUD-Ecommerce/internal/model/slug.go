@@ -0,0 +1,7 @@
+package model
+
+import "errors"
+
+// ErrSlugTaken is returned when a slug was claimed by another record
+// between checking it and saving.
+var ErrSlugTaken = errors.New("slug is already in use")
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type StockAlertStatus string
+
+const (
+	StockAlertStatusOpen         StockAlertStatus = "open"
+	StockAlertStatusAcknowledged StockAlertStatus = "acknowledged"
+)
+
+// StockAlert is raised when a sale takes a product below its reorder
+// threshold. A product has at most one open alert.
+type StockAlert struct {
+	ID             uuid.UUID        `json:"id"`
+	ProductID      uuid.UUID        `json:"product_id"`
+	ProductName    string           `json:"product_name"`
+	Stock          int              `json:"stock"` // latest level while the alert is open
+	Threshold      int              `json:"threshold"`
+	Status         StockAlertStatus `json:"status"`
+	CreatedAt      time.Time        `json:"created_at"`
+	AcknowledgedAt *time.Time       `json:"acknowledged_at,omitempty"`
+}
+
+// StockSubscription is a customer's request to be told when an out-of-stock
+// product is available again.
+type StockSubscription struct {
+	ProductID  uuid.UUID  `json:"product_id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	NotifiedAt *time.Time `json:"notified_at,omitempty"`
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,11 +13,21 @@ type User struct {
 	Password  string    `json:"password,omitempty" validate:"required,min=6"`
 	FirstName string    `json:"first_name" validate:"required"`
 	LastName  string    `json:"last_name" validate:"required"`
-	Role      string    `json:"role"` // "user" or "admin"
+	Role      string    `json:"role"` // name of a row in roles; RoleUser for customers
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	EmailVerifiedAt *time.Time `json:"-"` // nil until the address is confirmed
+	MFASecret       string     `json:"-"` // TOTP secret; set during enrolment, before MFA is enabled
+	MFAEnabledAt    *time.Time `json:"-"` // nil until enrolment is confirmed with a code
+	FailedLogins    int        `json:"-"` // consecutive failed logins since the last success
+	LockedUntil     *time.Time `json:"-"` // logins are refused until then
 }
 
+// ErrEmailNotVerified is returned for actions that need a confirmed email
+// address, such as placing an order.
+var ErrEmailNotVerified = errors.New("verify your email address before placing orders")
+
 type UserRegisterRequest struct {
 	Email     string `json:"email" validate:"required,email"`
 	Password  string `json:"password" validate:"required,min=6"`
@@ -29,32 +40,72 @@ type UserLoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=6"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=6"`
+}
+
 type UserUpdateRequest struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 }
 
+// UserPatchDocument is the editable view of a profile that PATCH merge
+// patches are applied to.
+type UserPatchDocument struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
 type UserResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Email     string    `json:"email"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            uuid.UUID `json:"id"`
+	Email         string    `json:"email"`
+	FirstName     string    `json:"first_name"`
+	LastName      string    `json:"last_name"`
+	Role          string    `json:"role"`
+	EmailVerified bool      `json:"email_verified"`
+	MFAEnabled    bool      `json:"mfa_enabled"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  UserResponse `json:"user"`
+	Token        string       `json:"token"`         // short-lived access token
+	ExpiresIn    int          `json:"expires_in"`    // access token lifetime in seconds
+	RefreshToken string       `json:"refresh_token"` // single use; exchanged at /auth/refresh
+	User         UserResponse `json:"user"`
+	Permissions  []Permission `json:"permissions"` // granted by the user's role
+
+	// MFASetupRequired is set for staff who must enrol in MFA before staff
+	// routes accept their tokens.
+	MFASetupRequired bool `json:"mfa_setup_required,omitempty"`
 }
 
 func (u *User) ToResponse() UserResponse {
 	return UserResponse{
-		ID:        u.ID,
-		Email:     u.Email,
-		FirstName: u.FirstName,
-		LastName:  u.LastName,
-		Role:      u.Role,
-		CreatedAt: u.CreatedAt,
+		ID:            u.ID,
+		Email:         u.Email,
+		FirstName:     u.FirstName,
+		LastName:      u.LastName,
+		Role:          u.Role,
+		EmailVerified: u.EmailVerifiedAt != nil,
+		MFAEnabled:    u.MFAEnabledAt != nil,
+		CreatedAt:     u.CreatedAt,
 	}
 }
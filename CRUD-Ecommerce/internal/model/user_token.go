@@ -0,0 +1,18 @@
+package model
+
+import "errors"
+
+// UserTokenPurpose says what a single-use token handed to a user is for:
+// a link sent by email, or the challenge between the two steps of an MFA
+// login.
+type UserTokenPurpose string
+
+const (
+	UserTokenVerifyEmail   UserTokenPurpose = "verify_email"
+	UserTokenResetPassword UserTokenPurpose = "reset_password"
+	UserTokenMFAChallenge  UserTokenPurpose = "mfa_challenge"
+)
+
+// ErrTokenRecentlyIssued is returned when a token of the same purpose was
+// issued to the user too recently to send another email.
+var ErrTokenRecentlyIssued = errors.New("a link was sent recently; wait before asking for another")
@@ -0,0 +1,7 @@
+package model
+
+import "errors"
+
+// ErrVersionMismatch is returned when an update was based on a version of a
+// record that has since been changed by someone else.
+var ErrVersionMismatch = errors.New("the resource has been modified since it was read; fetch it again and retry")
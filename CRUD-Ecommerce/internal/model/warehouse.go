@@ -0,0 +1,72 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AllocationStrategy string
+
+const (
+	// AllocationStrategyPriority ships from warehouses in priority order
+	AllocationStrategyPriority AllocationStrategy = "priority"
+	// AllocationStrategyClosest prefers warehouses in the shipping region,
+	// falling back to priority order
+	AllocationStrategyClosest AllocationStrategy = "closest"
+)
+
+func (s AllocationStrategy) IsValid() bool {
+	switch s {
+	case AllocationStrategyPriority, AllocationStrategyClosest:
+		return true
+	}
+	return false
+}
+
+type Warehouse struct {
+	ID        uuid.UUID `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Region    string    `json:"region"`
+	Priority  int       `json:"priority"` // lower ships first
+	IsDefault bool      `json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WarehouseCreateRequest struct {
+	Code     string `json:"code" validate:"required"`
+	Name     string `json:"name" validate:"required"`
+	Region   string `json:"region"`
+	Priority int    `json:"priority"`
+}
+
+type WarehouseUpdateRequest struct {
+	Name     string `json:"name"`
+	Region   string `json:"region"`
+	Priority *int   `json:"priority"`
+}
+
+// WarehouseStock is a product's stock level in one warehouse.
+type WarehouseStock struct {
+	WarehouseID   uuid.UUID `json:"warehouse_id"`
+	WarehouseCode string    `json:"warehouse_code"`
+	Region        string    `json:"region"`
+	Priority      int       `json:"priority"`
+	Quantity      int       `json:"quantity"`
+}
+
+type ProductInventory struct {
+	ProductID       uuid.UUID        `json:"product_id"`
+	AvailableToSell int              `json:"available_to_sell"`
+	Warehouses      []WarehouseStock `json:"warehouses"`
+}
+
+// OrderAllocation is the part of an order item shipped from one warehouse.
+// ProductID is the item's product, or for a bundle the component shipped.
+type OrderAllocation struct {
+	ProductID   uuid.UUID `json:"product_id"`
+	WarehouseID uuid.UUID `json:"warehouse_id"`
+	Quantity    int       `json:"quantity"`
+}
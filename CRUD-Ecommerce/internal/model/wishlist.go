@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Wishlist struct {
+	ID         uuid.UUID      `json:"id"`
+	UserID     uuid.UUID      `json:"-"`
+	Name       string         `json:"name"`
+	ShareToken *string        `json:"share_token,omitempty"` // set while the list is shared
+	Items      []WishlistItem `json:"items"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+type WishlistItem struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Product   *Product  `json:"product,omitempty"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+type WishlistCreateRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type WishlistUpdateRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type WishlistItemRequest struct {
+	ProductID uuid.UUID `json:"product_id" validate:"required"`
+}
+
+// WishlistOrderRequest moves wishlist items into a new order. Items carry a
+// quantity each; product IDs order one of each. Without either every item
+// on the list is ordered once.
+type WishlistOrderRequest struct {
+	Items          []OrderItemRequest `json:"items"`
+	ProductIDs     []uuid.UUID        `json:"product_ids"`
+	ShippingRegion string             `json:"shipping_region"`
+}
@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type AttributeRepository interface {
+	CreateDefinition(definition *model.AttributeDefinition) error
+	GetDefinitions(categoryID uuid.UUID) ([]model.AttributeDefinition, error)
+	DeleteDefinition(id, categoryID uuid.UUID) error
+	SetValues(productID uuid.UUID, values []model.AttributeValue, removed []uuid.UUID) error
+}
+
+type attributeRepository struct {
+	db *sql.DB
+}
+
+func NewAttributeRepository(db *sql.DB) AttributeRepository {
+	return &attributeRepository{db: db}
+}
+
+func (r *attributeRepository) CreateDefinition(definition *model.AttributeDefinition) error {
+	query := `
+		INSERT INTO attribute_definitions (id, category_id, code, name, type, unit, options, required, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	definition.ID = uuid.New()
+	definition.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(
+		query,
+		definition.ID,
+		definition.CategoryID,
+		definition.Code,
+		definition.Name,
+		definition.Type,
+		definition.Unit,
+		pq.Array(definition.Options),
+		definition.Required,
+		definition.CreatedAt,
+	)
+	if err != nil {
+		if isViolation(err, uniqueViolation, "attribute_definitions_category_id_code_key") {
+			return fmt.Errorf("attribute %s already exists in this category", definition.Code)
+		}
+		return fmt.Errorf("failed to create attribute: %w", err)
+	}
+
+	return nil
+}
+
+func (r *attributeRepository) GetDefinitions(categoryID uuid.UUID) ([]model.AttributeDefinition, error) {
+	query := `
+		SELECT id, category_id, code, name, type, unit, options, required, created_at
+		FROM attribute_definitions
+		WHERE category_id = $1
+		ORDER BY code ASC
+	`
+
+	rows, err := r.db.Query(query, categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attributes: %w", err)
+	}
+	defer rows.Close()
+
+	definitions := []model.AttributeDefinition{}
+	for rows.Next() {
+		var definition model.AttributeDefinition
+		err := rows.Scan(
+			&definition.ID,
+			&definition.CategoryID,
+			&definition.Code,
+			&definition.Name,
+			&definition.Type,
+			&definition.Unit,
+			pq.Array(&definition.Options),
+			&definition.Required,
+			&definition.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attribute: %w", err)
+		}
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, nil
+}
+
+// DeleteDefinition removes the attribute along with every product's value
+// for it.
+func (r *attributeRepository) DeleteDefinition(id, categoryID uuid.UUID) error {
+	query := `DELETE FROM attribute_definitions WHERE id = $1 AND category_id = $2`
+
+	result, err := r.db.Exec(query, id, categoryID)
+	if err != nil {
+		return fmt.Errorf("failed to delete attribute: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("attribute not found")
+	}
+
+	return nil
+}
+
+// SetValues upserts the given values and deletes the removed attributes for
+// a product in one transaction. Attributes not mentioned are left alone.
+func (r *attributeRepository) SetValues(productID uuid.UUID, values []model.AttributeValue, removed []uuid.UUID) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := setAttributeValues(tx, productID, values, removed); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func setAttributeValues(tx *sql.Tx, productID uuid.UUID, values []model.AttributeValue, removed []uuid.UUID) error {
+	if len(removed) > 0 {
+		_, err := tx.Exec(
+			`DELETE FROM product_attribute_values WHERE product_id = $1 AND attribute_id = ANY($2)`,
+			productID, pq.Array(removed),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to remove attribute values: %w", err)
+		}
+	}
+
+	query := `
+		INSERT INTO product_attribute_values (product_id, attribute_id, value_text, value_number, value_bool)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (product_id, attribute_id) DO UPDATE SET
+			value_text = EXCLUDED.value_text,
+			value_number = EXCLUDED.value_number,
+			value_bool = EXCLUDED.value_bool
+	`
+
+	for _, value := range values {
+		_, err := tx.Exec(query, productID, value.AttributeID, value.Text, value.Number, value.Bool)
+		if err != nil {
+			return fmt.Errorf("failed to set attribute value: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// moveAttributeValues carries products' values for category fromID's
+// attributes over to the attributes of category toID with the same code and
+// type, before fromID's definitions, and with them the old values, are
+// deleted. Enum values only carry over if the target allows them. The
+// move is refused if toID requires an attribute that some of fromID's
+// products would be left without. Returns the number of values carried over
+// and, by code, the number that will be dropped.
+func moveAttributeValues(tx *sql.Tx, fromID, toID uuid.UUID) (int, map[string]int, error) {
+	// A target attribute matches a value when code and type agree and, for
+	// enums, the value is one of its options
+	const matches = `
+		td.category_id = $2 AND td.code = od.code AND td.type = od.type
+		AND (td.type <> 'enum' OR v.value_text = ANY(td.options))
+	`
+
+	var missing []string
+	rows, err := tx.Query(`
+		SELECT td.code, COUNT(*)
+		FROM attribute_definitions td
+		JOIN products p ON p.category_id = $1
+		WHERE td.category_id = $2 AND td.required
+		  AND NOT EXISTS (
+		      SELECT 1
+		      FROM product_attribute_values v
+		      JOIN attribute_definitions od ON od.id = v.attribute_id AND od.category_id = $1
+		      WHERE v.product_id = p.id AND `+matches+`
+		  )
+		GROUP BY td.code
+		ORDER BY td.code
+	`, fromID, toID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to check required attributes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code string
+		var products int
+		if err := rows.Scan(&code, &products); err != nil {
+			return 0, nil, fmt.Errorf("failed to scan required attribute: %w", err)
+		}
+		missing = append(missing, fmt.Sprintf("%s (%d products)", code, products))
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("failed to check required attributes: %w", err)
+	}
+	if len(missing) > 0 {
+		return 0, nil, fmt.Errorf("target category requires attributes the moved products do not have: %s", strings.Join(missing, ", "))
+	}
+
+	dropped := make(map[string]int)
+	rows, err = tx.Query(`
+		SELECT od.code, COUNT(*)
+		FROM product_attribute_values v
+		JOIN attribute_definitions od ON od.id = v.attribute_id AND od.category_id = $1
+		WHERE NOT EXISTS (SELECT 1 FROM attribute_definitions td WHERE `+matches+`)
+		GROUP BY od.code
+	`, fromID, toID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to count dropped attribute values: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code string
+		var values int
+		if err := rows.Scan(&code, &values); err != nil {
+			return 0, nil, fmt.Errorf("failed to scan dropped attribute values: %w", err)
+		}
+		dropped[code] = values
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("failed to count dropped attribute values: %w", err)
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO product_attribute_values (product_id, attribute_id, value_text, value_number, value_bool)
+		SELECT v.product_id, td.id, v.value_text, v.value_number, v.value_bool
+		FROM product_attribute_values v
+		JOIN attribute_definitions od ON od.id = v.attribute_id AND od.category_id = $1
+		JOIN attribute_definitions td ON `+matches+`
+		ON CONFLICT (product_id, attribute_id) DO NOTHING
+	`, fromID, toID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to move attribute values: %w", err)
+	}
+
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return int(moved), dropped, nil
+}
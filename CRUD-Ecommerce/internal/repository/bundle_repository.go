@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+)
+
+type BundleRepository interface {
+	GetComponents(bundleID uuid.UUID) ([]model.BundleComponent, error)
+	SetComponents(bundle *model.Product, components []model.BundleComponent) error
+}
+
+type bundleRepository struct {
+	db *sql.DB
+}
+
+func NewBundleRepository(db *sql.DB) BundleRepository {
+	return &bundleRepository{db: db}
+}
+
+func (r *bundleRepository) GetComponents(bundleID uuid.UUID) ([]model.BundleComponent, error) {
+	query := `
+		SELECT bc.component_id, bc.quantity, ` + productColumns + `
+		FROM bundle_components bc
+		JOIN products p ON p.id = bc.component_id
+		WHERE bc.bundle_id = $1
+		ORDER BY p.name ASC
+	`
+
+	rows, err := r.db.Query(query, bundleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bundle components: %w", err)
+	}
+	defer rows.Close()
+
+	var components []model.BundleComponent
+	for rows.Next() {
+		component := model.BundleComponent{Product: &model.Product{}}
+		err := rows.Scan(append([]interface{}{
+			&component.ProductID,
+			&component.Quantity,
+		}, productFields(component.Product)...)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bundle component: %w", err)
+		}
+		components = append(components, component)
+	}
+
+	return components, nil
+}
+
+// SetComponents replaces the bundle's components if bundle.Version is still
+// the stored version, and moves bundle.Version on to the new one.
+func (r *bundleRepository) SetComponents(bundle *model.Product, components []model.BundleComponent) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var version int
+	err = tx.QueryRow(`SELECT version FROM products WHERE id = $1 FOR UPDATE`, bundle.ID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("product not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update bundle components: %w", err)
+	}
+	if version != bundle.Version {
+		return model.ErrVersionMismatch
+	}
+
+	if err := setComponents(tx, bundle.ID, components); err != nil {
+		return err
+	}
+
+	bundle.UpdatedAt = time.Now()
+	err = tx.QueryRow(
+		`UPDATE products SET updated_at = $1, version = version + 1 WHERE id = $2 RETURNING version`,
+		bundle.UpdatedAt, bundle.ID,
+	).Scan(&bundle.Version)
+	if err != nil {
+		return fmt.Errorf("failed to update bundle components: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func setComponents(tx *sql.Tx, bundleID uuid.UUID, components []model.BundleComponent) error {
+	if _, err := tx.Exec(`DELETE FROM bundle_components WHERE bundle_id = $1`, bundleID); err != nil {
+		return fmt.Errorf("failed to update bundle components: %w", err)
+	}
+
+	for _, component := range components {
+		_, err := tx.Exec(
+			`INSERT INTO bundle_components (bundle_id, component_id, quantity) VALUES ($1, $2, $3)`,
+			bundleID, component.ProductID, component.Quantity,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add bundle component: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -12,9 +12,32 @@ import (
 type CategoryRepository interface {
 	Create(category *model.Category) error
 	GetByID(id uuid.UUID) (*model.Category, error)
+	GetByName(name string) (*model.Category, error)
+	GetBySlug(slug string) (*model.Category, error)
+	GetSlugRedirect(slug string) (string, error)
+	SlugExists(slug string, excludeID uuid.UUID) (bool, error)
 	GetAll() ([]model.Category, error)
+	GetPath(id uuid.UUID) ([]model.Breadcrumb, error)
 	Update(category *model.Category) error
-	Delete(id uuid.UUID) error
+	Delete(id, targetID uuid.UUID) (*model.CategoryDeleteResult, error)
+}
+
+// categoryColumns lists the categories columns read by every category query,
+// in the order expected by categoryFields.
+const categoryColumns = `id, parent_id, name, COALESCE(slug, ''), description, version, created_at, updated_at`
+
+// categoryFields returns scan destinations matching categoryColumns.
+func categoryFields(category *model.Category) []interface{} {
+	return []interface{}{
+		&category.ID,
+		&category.ParentID,
+		&category.Name,
+		&category.Slug,
+		&category.Description,
+		&category.Version,
+		&category.CreatedAt,
+		&category.UpdatedAt,
+	}
 }
 
 type categoryRepository struct {
@@ -25,28 +48,46 @@ func NewCategoryRepository(db *sql.DB) CategoryRepository {
 	return &categoryRepository{db: db}
 }
 
+// Create inserts the category. Placing it under a parent is checked again
+// under the category tree lock; see placeCategory.
 func (r *categoryRepository) Create(category *model.Category) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := placeCategory(tx, uuid.Nil, category.ParentID); err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO categories (id, name, description, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at, updated_at
+		INSERT INTO categories (id, parent_id, name, slug, description, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, version, created_at, updated_at
 	`
 
 	category.ID = uuid.New()
 	category.CreatedAt = time.Now()
 	category.UpdatedAt = time.Now()
 
-	err := r.db.QueryRow(
+	err = tx.QueryRow(
 		query,
 		category.ID,
+		category.ParentID,
 		category.Name,
+		nullString(category.Slug),
 		category.Description,
 		category.CreatedAt,
 		category.UpdatedAt,
-	).Scan(&category.ID, &category.CreatedAt, &category.UpdatedAt)
+	).Scan(&category.ID, &category.Version, &category.CreatedAt, &category.UpdatedAt)
 
 	if err != nil {
-		return fmt.Errorf("failed to create category: %w", err)
+		return slugConflict(err, categorySlugIndex, "create category")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
@@ -54,19 +95,13 @@ func (r *categoryRepository) Create(category *model.Category) error {
 
 func (r *categoryRepository) GetByID(id uuid.UUID) (*model.Category, error) {
 	query := `
-		SELECT id, name, description, created_at, updated_at
+		SELECT ` + categoryColumns + `
 		FROM categories
 		WHERE id = $1
 	`
 
 	category := &model.Category{}
-	err := r.db.QueryRow(query, id).Scan(
-		&category.ID,
-		&category.Name,
-		&category.Description,
-		&category.CreatedAt,
-		&category.UpdatedAt,
-	)
+	err := r.db.QueryRow(query, id).Scan(categoryFields(category)...)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("category not found")
@@ -78,9 +113,63 @@ func (r *categoryRepository) GetByID(id uuid.UUID) (*model.Category, error) {
 	return category, nil
 }
 
+// GetByName looks a category up by name, ignoring case.
+func (r *categoryRepository) GetByName(name string) (*model.Category, error) {
+	query := `
+		SELECT ` + categoryColumns + `
+		FROM categories
+		WHERE LOWER(name) = LOWER($1)
+	`
+
+	category := &model.Category{}
+	err := r.db.QueryRow(query, name).Scan(categoryFields(category)...)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("category not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category: %w", err)
+	}
+
+	return category, nil
+}
+
+// GetBySlug looks a category up by its current slug.
+func (r *categoryRepository) GetBySlug(slug string) (*model.Category, error) {
+	query := `
+		SELECT ` + categoryColumns + `
+		FROM categories
+		WHERE slug = $1
+	`
+
+	category := &model.Category{}
+	err := r.db.QueryRow(query, slug).Scan(categoryFields(category)...)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("category not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category: %w", err)
+	}
+
+	return category, nil
+}
+
+// GetSlugRedirect returns the current slug of the category a retired slug
+// belonged to.
+func (r *categoryRepository) GetSlugRedirect(slug string) (string, error) {
+	return slugRedirect(r.db, "categories", slugEntityCategory, slug)
+}
+
+// SlugExists reports whether slug belongs, or redirects, to a category other
+// than excludeID.
+func (r *categoryRepository) SlugExists(slug string, excludeID uuid.UUID) (bool, error) {
+	return slugTaken(r.db, "categories", slugEntityCategory, slug, excludeID)
+}
+
 func (r *categoryRepository) GetAll() ([]model.Category, error) {
 	query := `
-		SELECT id, name, description, created_at, updated_at
+		SELECT ` + categoryColumns + `
 		FROM categories
 		ORDER BY name ASC
 	`
@@ -94,13 +183,7 @@ func (r *categoryRepository) GetAll() ([]model.Category, error) {
 	var categories []model.Category
 	for rows.Next() {
 		var category model.Category
-		err := rows.Scan(
-			&category.ID,
-			&category.Name,
-			&category.Description,
-			&category.CreatedAt,
-			&category.UpdatedAt,
-		)
+		err := rows.Scan(categoryFields(&category)...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan category: %w", err)
 		}
@@ -110,47 +193,246 @@ func (r *categoryRepository) GetAll() ([]model.Category, error) {
 	return categories, nil
 }
 
+// GetPath returns the breadcrumbs leading to a category, from its top-level
+// ancestor down to the category itself.
+func (r *categoryRepository) GetPath(id uuid.UUID) ([]model.Breadcrumb, error) {
+	query := `
+		WITH RECURSIVE path AS (
+			SELECT id, parent_id, name, slug, 0 AS level
+			FROM categories
+			WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id, c.name, c.slug, path.level + 1
+			FROM categories c
+			JOIN path ON c.id = path.parent_id
+			WHERE path.level < $2
+		)
+		SELECT id, name, COALESCE(slug, '')
+		FROM path
+		ORDER BY level DESC
+	`
+
+	rows, err := r.db.Query(query, id, model.MaxCategoryDepth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category path: %w", err)
+	}
+	defer rows.Close()
+
+	var path []model.Breadcrumb
+	for rows.Next() {
+		var crumb model.Breadcrumb
+		if err := rows.Scan(&crumb.ID, &crumb.Name, &crumb.Slug); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		path = append(path, crumb)
+	}
+
+	return path, nil
+}
+
+// placeCategory checks, inside the transaction that saves it, that category
+// id (uuid.Nil for a new one) can sit under parentID: the parent exists, is
+// not the category or one of its subcategories, and the category's subtree
+// still fits within model.MaxCategoryDepth. A transaction-scoped advisory
+// lock serialises every placement, so two concurrent moves cannot together
+// build a cycle or a tree that is too deep. A nil parent needs no check.
+func placeCategory(tx *sql.Tx, id uuid.UUID, parentID *uuid.UUID) error {
+	if parentID == nil {
+		return nil
+	}
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('category_tree'))`); err != nil {
+		return fmt.Errorf("failed to lock category tree: %w", err)
+	}
+
+	// Ancestors of the new parent, the parent itself at depth 1. The depth
+	// bound stops the walk on a tree that already contains a cycle.
+	var found int
+	var cycle bool
+	var depth int
+	err := tx.QueryRow(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 1 AS depth
+			FROM categories
+			WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id, a.depth + 1
+			FROM categories c
+			JOIN ancestors a ON c.id = a.parent_id
+			WHERE a.depth <= $3
+		)
+		SELECT COUNT(*), COALESCE(BOOL_OR(id = $2), false), COALESCE(MAX(depth), 0)
+		FROM ancestors
+	`, *parentID, id, model.MaxCategoryDepth).Scan(&found, &cycle, &depth)
+	if err != nil {
+		return fmt.Errorf("failed to check category parent: %w", err)
+	}
+	if found == 0 {
+		return fmt.Errorf("parent category not found")
+	}
+	if cycle {
+		return fmt.Errorf("a category cannot be moved under itself or one of its subcategories")
+	}
+
+	// Levels in the subtree being placed, counting the category itself
+	levels := 1
+	if id != uuid.Nil {
+		err = tx.QueryRow(`
+			WITH RECURSIVE subtree AS (
+				SELECT id, 1 AS level
+				FROM categories
+				WHERE id = $1
+				UNION ALL
+				SELECT c.id, s.level + 1
+				FROM categories c
+				JOIN subtree s ON c.parent_id = s.id
+				WHERE s.level <= $2
+			)
+			SELECT COALESCE(MAX(level), 1) FROM subtree
+		`, id, model.MaxCategoryDepth).Scan(&levels)
+		if err != nil {
+			return fmt.Errorf("failed to check category parent: %w", err)
+		}
+	}
+
+	if depth+levels > model.MaxCategoryDepth {
+		return fmt.Errorf("categories can be nested at most %d levels deep", model.MaxCategoryDepth)
+	}
+
+	return nil
+}
+
+// Update saves the category if category.Version is still the stored
+// version, and moves category.Version on to the new one. A new parent is
+// checked again under the category tree lock; see placeCategory.
 func (r *categoryRepository) Update(category *model.Category) error {
 	query := `
 		UPDATE categories
-		SET name = $1, description = $2, updated_at = $3
-		WHERE id = $4
-		RETURNING updated_at
+		SET parent_id = $1, name = $2, slug = $3, description = $4, updated_at = $5, version = version + 1
+		WHERE id = $6
+		RETURNING version, updated_at
 	`
 
 	category.UpdatedAt = time.Now()
 
-	err := r.db.QueryRow(
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Take the tree lock before the row lock, in the same order as Create
+	if err := placeCategory(tx, category.ID, category.ParentID); err != nil {
+		return err
+	}
+
+	var oldSlug sql.NullString
+	var version int
+	err = tx.QueryRow(`SELECT slug, version FROM categories WHERE id = $1 FOR UPDATE`, category.ID).Scan(&oldSlug, &version)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("category not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+	if version != category.Version {
+		return model.ErrVersionMismatch
+	}
+
+	err = tx.QueryRow(
 		query,
+		category.ParentID,
 		category.Name,
+		nullString(category.Slug),
 		category.Description,
 		category.UpdatedAt,
 		category.ID,
-	).Scan(&category.UpdatedAt)
+	).Scan(&category.Version, &category.UpdatedAt)
 
 	if err != nil {
-		return fmt.Errorf("failed to update category: %w", err)
+		return slugConflict(err, categorySlugIndex, "update category")
+	}
+
+	if err := moveSlug(tx, slugEntityCategory, category.ID, oldSlug.String, category.Slug); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-func (r *categoryRepository) Delete(id uuid.UUID) error {
-	query := `DELETE FROM categories WHERE id = $1`
-
-	result, err := r.db.Exec(query, id)
+// Delete removes the category. Products in it are first moved to targetID,
+// in the same transaction, taking along the attribute values the target
+// has matching attributes for; see moveAttributeValues. With targetID
+// uuid.Nil the delete is refused if the category has any products.
+func (r *categoryRepository) Delete(id, targetID uuid.UUID) (*model.CategoryDeleteResult, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to delete category: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	rows, err := result.RowsAffected()
+	var found int
+	err = tx.QueryRow(`SELECT 1 FROM categories WHERE id = $1 FOR UPDATE`, id).Scan(&found)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("category not found")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get affected rows: %w", err)
+		return nil, fmt.Errorf("failed to delete category: %w", err)
 	}
 
-	if rows == 0 {
-		return fmt.Errorf("category not found")
+	result := &model.CategoryDeleteResult{DroppedAttributes: map[string]int{}}
+	if targetID != uuid.Nil {
+		// Keep the target from being deleted while products move into it
+		err = tx.QueryRow(`SELECT 1 FROM categories WHERE id = $1 FOR KEY SHARE`, targetID).Scan(&found)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("target category not found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete category: %w", err)
+		}
+
+		// Carry attribute values over while the products are still in the
+		// old category; the rest go with its definitions
+		if result.AttributeValuesMoved, result.DroppedAttributes, err = moveAttributeValues(tx, id, targetID); err != nil {
+			return nil, err
+		}
+
+		updated, err := tx.Exec(
+			`UPDATE products SET category_id = $1, updated_at = $2, version = version + 1 WHERE category_id = $3`,
+			targetID, time.Now(), id,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move products: %w", err)
+		}
+		moved, err := updated.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get affected rows: %w", err)
+		}
+		result.ProductsMoved = int(moved)
+	} else {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM products WHERE category_id = $1`, id).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count products: %w", err)
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("category has %d products; choose a target category to move them to", count)
+		}
 	}
 
-	return nil
+	if _, err := tx.Exec(`DELETE FROM categories WHERE id = $1`, id); err != nil {
+		if isViolation(err, foreignKeyViolation, "categories_parent_id_fkey") {
+			return nil, fmt.Errorf("category has subcategories; move or delete them first")
+		}
+		return nil, fmt.Errorf("failed to delete category: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
 }
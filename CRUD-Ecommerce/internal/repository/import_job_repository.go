@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+)
+
+type ImportJobRepository interface {
+	Create(job *model.ImportJob) error
+	GetByID(id uuid.UUID) (*model.ImportJob, error)
+	Update(job *model.ImportJob) error
+	Touch(id uuid.UUID) error
+	Interrupt(id uuid.UUID, message string, staleBefore time.Time) error
+}
+
+type importJobRepository struct {
+	db *sql.DB
+}
+
+func NewImportJobRepository(db *sql.DB) ImportJobRepository {
+	return &importJobRepository{db: db}
+}
+
+func (r *importJobRepository) Create(job *model.ImportJob) error {
+	query := `
+		INSERT INTO import_jobs (id, format, status, total_rows, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	job.ID = uuid.New()
+	job.CreatedAt = time.Now()
+
+	if job.Status == "" {
+		job.Status = model.ImportJobStatusPending
+	}
+
+	err := r.db.QueryRow(
+		query,
+		job.ID,
+		job.Format,
+		job.Status,
+		job.TotalRows,
+		job.CreatedBy,
+		job.CreatedAt,
+	).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create import job: %w", err)
+	}
+
+	return nil
+}
+
+func (r *importJobRepository) GetByID(id uuid.UUID) (*model.ImportJob, error) {
+	query := `
+		SELECT id, format, status, total_rows, processed_rows, created_count, updated_count,
+		       failed_count, errors, message, created_by, created_at, started_at, finished_at, updated_at
+		FROM import_jobs
+		WHERE id = $1
+	`
+
+	job := &model.ImportJob{}
+	var errorsJSON []byte
+	var startedAt, finishedAt sql.NullTime
+
+	err := r.db.QueryRow(query, id).Scan(
+		&job.ID,
+		&job.Format,
+		&job.Status,
+		&job.TotalRows,
+		&job.ProcessedRows,
+		&job.CreatedCount,
+		&job.UpdatedCount,
+		&job.FailedCount,
+		&errorsJSON,
+		&job.Message,
+		&job.CreatedBy,
+		&job.CreatedAt,
+		&startedAt,
+		&finishedAt,
+		&job.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("import job not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+
+	if err := json.Unmarshal(errorsJSON, &job.Errors); err != nil {
+		return nil, fmt.Errorf("failed to decode import errors: %w", err)
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+
+	return job, nil
+}
+
+// Update persists the job's progress counters, status and error list. Each
+// write also refreshes updated_at, which shows the job is still alive.
+func (r *importJobRepository) Update(job *model.ImportJob) error {
+	query := `
+		UPDATE import_jobs
+		SET status = $1, processed_rows = $2, created_count = $3, updated_count = $4,
+		    failed_count = $5, errors = $6, message = $7, started_at = $8, finished_at = $9, updated_at = $10
+		WHERE id = $11
+	`
+
+	job.UpdatedAt = time.Now()
+
+	errors := job.Errors
+	if errors == nil {
+		errors = []model.ImportRowError{}
+	}
+	errorsJSON, err := json.Marshal(errors)
+	if err != nil {
+		return fmt.Errorf("failed to encode import errors: %w", err)
+	}
+
+	_, err = r.db.Exec(
+		query,
+		job.Status,
+		job.ProcessedRows,
+		job.CreatedCount,
+		job.UpdatedCount,
+		job.FailedCount,
+		errorsJSON,
+		job.Message,
+		job.StartedAt,
+		job.FinishedAt,
+		job.UpdatedAt,
+		job.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update import job: %w", err)
+	}
+
+	return nil
+}
+
+// Touch refreshes updated_at without other changes, so a job whose rows are
+// slow to process is still seen to be alive.
+func (r *importJobRepository) Touch(id uuid.UUID) error {
+	if _, err := r.db.Exec(`UPDATE import_jobs SET updated_at = $1 WHERE id = $2`, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to update import job: %w", err)
+	}
+
+	return nil
+}
+
+// Interrupt marks a pending or running job interrupted if it has not been
+// written since staleBefore. The check and the write are one statement, so
+// a job whose worker writes meanwhile is left alone.
+func (r *importJobRepository) Interrupt(id uuid.UUID, message string, staleBefore time.Time) error {
+	query := `
+		UPDATE import_jobs
+		SET status = $1, message = $2, finished_at = $3, updated_at = $3
+		WHERE id = $4 AND status IN ($5, $6) AND updated_at < $7
+	`
+
+	_, err := r.db.Exec(
+		query,
+		model.ImportJobStatusInterrupted,
+		message,
+		time.Now(),
+		id,
+		model.ImportJobStatusPending,
+		model.ImportJobStatusRunning,
+		staleBefore,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update import job: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+)
+
+type InventoryRepository interface {
+	Record(movements []*model.InventoryMovement) error
+	SetStock(template model.InventoryMovement, target int) ([]*model.InventoryMovement, error)
+	GetByProduct(productID uuid.UUID) ([]model.InventoryMovement, error)
+}
+
+type inventoryRepository struct {
+	db *sql.DB
+}
+
+func NewInventoryRepository(db *sql.DB) InventoryRepository {
+	return &inventoryRepository{db: db}
+}
+
+// Record applies the movements to product stock and appends them to the
+// ledger in one transaction. If any movement would take stock below zero,
+// none of them are applied.
+func (r *inventoryRepository) Record(movements []*model.InventoryMovement) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := recordMovements(tx, movements); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SetStock brings the product's total stock to target, recording the
+// movements with the template's product, reason, reference and actor. See
+// setStock.
+func (r *inventoryRepository) SetStock(template model.InventoryMovement, target int) ([]*model.InventoryMovement, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	movements, err := setStock(tx, template, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return movements, nil
+}
+
+// setStock brings a product's total stock to target. The change is worked
+// out from the stock under the product's row lock, so movements committed
+// meanwhile are not overwritten. Stock is added to the default warehouse and
+// taken from the default warehouse first, then from the others in allocation
+// order, one movement per warehouse. Nothing is recorded when the stock is
+// already at target.
+func setStock(tx *sql.Tx, template model.InventoryMovement, target int) ([]*model.InventoryMovement, error) {
+	var stock int
+	err := tx.QueryRow(`SELECT stock FROM products WHERE id = $1 FOR UPDATE`, template.ProductID).Scan(&stock)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock product stock: %w", err)
+	}
+
+	if target == stock {
+		return nil, nil
+	}
+
+	if target > stock {
+		movement := template
+		movement.WarehouseID = nil
+		movement.Quantity = target - stock
+		if err := recordMovement(tx, &movement); err != nil {
+			return nil, err
+		}
+		return []*model.InventoryMovement{&movement}, nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT ws.warehouse_id, ws.quantity
+		FROM warehouse_stock ws
+		JOIN warehouses w ON w.id = ws.warehouse_id
+		WHERE ws.product_id = $1 AND ws.quantity > 0
+		ORDER BY w.is_default DESC, w.priority ASC, w.code ASC
+		FOR UPDATE OF ws
+	`, template.ProductID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock warehouse stock: %w", err)
+	}
+
+	type level struct {
+		warehouseID uuid.UUID
+		quantity    int
+	}
+	var levels []level
+	for rows.Next() {
+		var l level
+		if err := rows.Scan(&l.warehouseID, &l.quantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan warehouse stock: %w", err)
+		}
+		levels = append(levels, l)
+	}
+	rows.Close()
+
+	var movements []*model.InventoryMovement
+	remaining := stock - target
+	for _, l := range levels {
+		if remaining == 0 {
+			break
+		}
+
+		take := l.quantity
+		if take > remaining {
+			take = remaining
+		}
+
+		warehouseID := l.warehouseID
+		movement := template
+		movement.WarehouseID = &warehouseID
+		movement.Quantity = -take
+		if err := recordMovement(tx, &movement); err != nil {
+			return nil, err
+		}
+		movements = append(movements, &movement)
+		remaining -= take
+	}
+
+	if remaining > 0 {
+		return nil, fmt.Errorf("warehouse stock does not add up to the product's stock of %d", stock)
+	}
+
+	return movements, nil
+}
+
+// recordMovements applies the movements in product and then warehouse
+// order, sorting the slice in place. Taking the row locks in one order keeps
+// transactions that move the same products from deadlocking.
+func recordMovements(tx *sql.Tx, movements []*model.InventoryMovement) error {
+	sort.SliceStable(movements, func(i, j int) bool {
+		a, b := movements[i], movements[j]
+		if c := bytes.Compare(a.ProductID[:], b.ProductID[:]); c != 0 {
+			return c < 0
+		}
+		if a.WarehouseID == nil || b.WarehouseID == nil {
+			return a.WarehouseID == nil && b.WarehouseID != nil
+		}
+		return bytes.Compare(a.WarehouseID[:], b.WarehouseID[:]) < 0
+	})
+
+	for _, movement := range movements {
+		if err := recordMovement(tx, movement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordMovement applies one movement to the warehouse's stock and to the
+// product's total. Both rows are locked so concurrent movements serialise.
+func recordMovement(tx *sql.Tx, movement *model.InventoryMovement) error {
+	if movement.WarehouseID == nil {
+		var warehouseID uuid.UUID
+		err := tx.QueryRow(`SELECT id FROM warehouses WHERE is_default`).Scan(&warehouseID)
+		if err != nil {
+			return fmt.Errorf("failed to get default warehouse: %w", err)
+		}
+		movement.WarehouseID = &warehouseID
+	}
+
+	var name string
+	var stock, threshold int
+	var status model.ProductStatus
+	err := tx.QueryRow(
+		`SELECT name, stock, reorder_threshold, status FROM products WHERE id = $1 FOR UPDATE`,
+		movement.ProductID,
+	).Scan(&name, &stock, &threshold, &status)
+
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("product not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock product stock: %w", err)
+	}
+
+	var warehouseStock int
+	err = tx.QueryRow(
+		`SELECT quantity FROM warehouse_stock WHERE warehouse_id = $1 AND product_id = $2 FOR UPDATE`,
+		movement.WarehouseID, movement.ProductID,
+	).Scan(&warehouseStock)
+
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to lock warehouse stock: %w", err)
+	}
+
+	if warehouseStock+movement.Quantity < 0 {
+		return fmt.Errorf("insufficient stock for product %s. Available: %d, Requested: %d",
+			name, warehouseStock, -movement.Quantity)
+	}
+
+	movement.ID = uuid.New()
+	movement.CreatedAt = time.Now()
+	movement.StockAfter = stock + movement.Quantity
+
+	_, err = tx.Exec(
+		`INSERT INTO warehouse_stock (warehouse_id, product_id, quantity, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (warehouse_id, product_id) DO UPDATE SET quantity = EXCLUDED.quantity, updated_at = EXCLUDED.updated_at`,
+		movement.WarehouseID, movement.ProductID, warehouseStock+movement.Quantity, movement.CreatedAt,
+	)
+	if err != nil {
+		if isViolation(err, foreignKeyViolation, "warehouse_stock_warehouse_id_fkey") {
+			return fmt.Errorf("warehouse not found")
+		}
+		return fmt.Errorf("failed to update warehouse stock: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE products SET stock = $1, updated_at = $2 WHERE id = $3`,
+		movement.StockAfter, movement.CreatedAt, movement.ProductID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update stock: %w", err)
+	}
+
+	query := `
+		INSERT INTO inventory_movements (id, product_id, warehouse_id, quantity, reason, reference_id, actor_id, note, stock_after, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	_, err = tx.Exec(
+		query,
+		movement.ID,
+		movement.ProductID,
+		movement.WarehouseID,
+		movement.Quantity,
+		movement.Reason,
+		movement.ReferenceID,
+		movement.ActorID,
+		movement.Note,
+		movement.StockAfter,
+		movement.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record inventory movement: %w", err)
+	}
+
+	// Sales that leave stock under the reorder threshold raise an alert, or
+	// refresh the stock level on the one already open
+	if movement.Reason == model.InventoryReasonSale && movement.StockAfter < threshold {
+		_, err = tx.Exec(
+			`INSERT INTO stock_alerts (product_id, stock, threshold, status, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (product_id) WHERE status = 'open' DO UPDATE SET stock = EXCLUDED.stock`,
+			movement.ProductID, movement.StockAfter, threshold, model.StockAlertStatusOpen, movement.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to raise stock alert: %w", err)
+		}
+	}
+
+	// Coming back into stock notifies everyone waiting on the product
+	if stock <= 0 && movement.StockAfter > 0 && status == model.ProductStatusActive {
+		_, err = tx.Exec(
+			`WITH notified AS (
+				UPDATE stock_subscriptions SET notified_at = $2
+				WHERE product_id = $1 AND notified_at IS NULL
+				RETURNING user_id
+			)
+			INSERT INTO notifications (user_id, type, product_id, message, created_at)
+			SELECT user_id, $3, $1, $4, $2 FROM notified`,
+			movement.ProductID, movement.CreatedAt, model.NotificationTypeBackInStock,
+			fmt.Sprintf("%s is back in stock", name),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to send back-in-stock notifications: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *inventoryRepository) GetByProduct(productID uuid.UUID) ([]model.InventoryMovement, error) {
+	query := `
+		SELECT id, product_id, warehouse_id, quantity, reason, reference_id, actor_id, note, stock_after, created_at
+		FROM inventory_movements
+		WHERE product_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory movements: %w", err)
+	}
+	defer rows.Close()
+
+	movements := []model.InventoryMovement{}
+	for rows.Next() {
+		var movement model.InventoryMovement
+		var warehouseID, referenceID, actorID uuid.NullUUID
+		err := rows.Scan(
+			&movement.ID,
+			&movement.ProductID,
+			&warehouseID,
+			&movement.Quantity,
+			&movement.Reason,
+			&referenceID,
+			&actorID,
+			&movement.Note,
+			&movement.StockAfter,
+			&movement.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan inventory movement: %w", err)
+		}
+		if warehouseID.Valid {
+			movement.WarehouseID = &warehouseID.UUID
+		}
+		if referenceID.Valid {
+			movement.ReferenceID = &referenceID.UUID
+		}
+		if actorID.Valid {
+			movement.ActorID = &actorID.UUID
+		}
+		movements = append(movements, movement)
+	}
+
+	return movements, nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+)
+
+type LoginAttemptRepository interface {
+	Create(attempt *model.LoginAttempt) error
+	GetByUserID(userID uuid.UUID, limit int) ([]model.LoginAttempt, error)
+	GetFailuresByIP(ipAddress string, since time.Time) (int, time.Time, error)
+}
+
+type loginAttemptRepository struct {
+	db *sql.DB
+}
+
+func NewLoginAttemptRepository(db *sql.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
+
+func (r *loginAttemptRepository) Create(attempt *model.LoginAttempt) error {
+	query := `
+		INSERT INTO login_attempts (id, user_id, email, ip_address, user_agent, result, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	attempt.ID = uuid.New()
+	attempt.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(
+		query,
+		attempt.ID,
+		attempt.UserID,
+		attempt.Email,
+		attempt.IPAddress,
+		attempt.UserAgent,
+		attempt.Result,
+		attempt.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+
+	return nil
+}
+
+// GetByUserID returns the user's most recent login attempts, newest first.
+func (r *loginAttemptRepository) GetByUserID(userID uuid.UUID, limit int) ([]model.LoginAttempt, error) {
+	query := `
+		SELECT id, user_id, email, ip_address, COALESCE(user_agent, ''), result, created_at
+		FROM login_attempts
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []model.LoginAttempt{}
+	for rows.Next() {
+		var attempt model.LoginAttempt
+		err := rows.Scan(
+			&attempt.ID,
+			&attempt.UserID,
+			&attempt.Email,
+			&attempt.IPAddress,
+			&attempt.UserAgent,
+			&attempt.Result,
+			&attempt.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan login attempt: %w", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, nil
+}
+
+// GetFailuresByIP counts failed logins from an address since the given
+// time, and returns when the oldest of them happened.
+func (r *loginAttemptRepository) GetFailuresByIP(ipAddress string, since time.Time) (int, time.Time, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(MIN(created_at), $3)
+		FROM login_attempts
+		WHERE ip_address = $1 AND created_at > $2 AND result IN ($4, $5, $6)
+	`
+
+	var count int
+	var oldest time.Time
+	err := r.db.QueryRow(
+		query, ipAddress, since, time.Now(),
+		model.LoginResultBadPassword, model.LoginResultBadMFACode, model.LoginResultUnknownAccount,
+	).Scan(&count, &oldest)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to count login failures: %w", err)
+	}
+
+	return count, oldest, nil
+}
@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MFARepository interface {
+	SetSecret(userID uuid.UUID, secret string) error
+	Enable(userID uuid.UUID, recoveryCodeHashes []string) error
+	Disable(userID uuid.UUID) error
+	ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error
+	UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error)
+	UseStep(userID uuid.UUID, step int64) (bool, error)
+	RecordFailure(userID uuid.UUID, maxAttempts int) (bool, error)
+}
+
+type mfaRepository struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+// SetSecret stores a TOTP secret for a user who has not enabled MFA yet,
+// replacing any earlier unconfirmed one.
+func (r *mfaRepository) SetSecret(userID uuid.UUID, secret string) error {
+	query := `
+		UPDATE users SET mfa_secret = $1, mfa_last_step = NULL, mfa_attempts = 0, updated_at = $2
+		WHERE id = $3 AND mfa_enabled_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, secret, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to store MFA secret: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("MFA is already enabled")
+	}
+
+	return nil
+}
+
+// Enable turns on MFA with the stored secret and its first set of recovery
+// codes.
+func (r *mfaRepository) Enable(userID uuid.UUID, recoveryCodeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE users SET mfa_enabled_at = $1, updated_at = $1
+		WHERE id = $2 AND mfa_enabled_at IS NULL AND mfa_secret IS NOT NULL
+	`
+
+	result, err := tx.Exec(query, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable MFA: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("MFA is already enabled")
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Disable turns off MFA and discards the secret and recovery codes.
+func (r *mfaRepository) Disable(userID uuid.UUID) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE users SET mfa_secret = NULL, mfa_enabled_at = NULL, mfa_last_step = NULL, mfa_attempts = 0, updated_at = $1
+		WHERE id = $2
+	`
+
+	if _, err := tx.Exec(query, time.Now(), userID); err != nil {
+		return fmt.Errorf("failed to disable MFA: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes discards the user's recovery codes, used or not, in
+// favour of a new set.
+func (r *mfaRepository) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID uuid.UUID, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	now := time.Now()
+	for _, hash := range codeHashes {
+		_, err := tx.Exec(
+			`INSERT INTO mfa_recovery_codes (code_hash, user_id, created_at) VALUES ($1, $2, $3)`,
+			hash, userID, now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks one of the user's unused recovery codes as used,
+// reporting false if it does not match any.
+func (r *mfaRepository) UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes SET used_at = $1
+		WHERE code_hash = $2 AND user_id = $3 AND used_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, time.Now(), codeHash, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// UseStep records that a TOTP code for the time step was accepted, which
+// also clears the count of wrong codes. It reports false if a code for this
+// or a later step was already accepted, so each code works once.
+func (r *mfaRepository) UseStep(userID uuid.UUID, step int64) (bool, error) {
+	query := `
+		UPDATE users SET mfa_last_step = $1, mfa_attempts = 0
+		WHERE id = $2 AND (mfa_last_step IS NULL OR mfa_last_step < $1)
+	`
+
+	result, err := r.db.Exec(query, step, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record MFA code: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// RecordFailure counts a wrong code given outside a login challenge. On the
+// maxAttempts-th consecutive failure the count starts over and the user has
+// to authenticate again: a secret still being enrolled is discarded, and
+// with MFA enabled every session is signed out. Reports whether that
+// happened.
+func (r *mfaRepository) RecordFailure(userID uuid.UUID, maxAttempts int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var attempts int
+	var enabled bool
+	err = tx.QueryRow(
+		`UPDATE users SET mfa_attempts = mfa_attempts + 1 WHERE id = $1 RETURNING mfa_attempts, mfa_enabled_at IS NOT NULL`,
+		userID,
+	).Scan(&attempts, &enabled)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record attempt: %w", err)
+	}
+
+	exhausted := attempts >= maxAttempts
+	if exhausted {
+		query := `UPDATE users SET mfa_attempts = 0, mfa_secret = NULL, updated_at = $1 WHERE id = $2`
+		if enabled {
+			query = `UPDATE users SET mfa_attempts = 0, updated_at = $1 WHERE id = $2`
+			if err := revokeUserSessions(tx, userID); err != nil {
+				return false, err
+			}
+		}
+		if _, err := tx.Exec(query, time.Now(), userID); err != nil {
+			return false, fmt.Errorf("failed to record attempt: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return exhausted, nil
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+)
+
+type NotificationRepository interface {
+	GetByUser(userID uuid.UUID) ([]model.Notification, error)
+	MarkRead(id, userID uuid.UUID) error
+	Subscribe(productID, userID uuid.UUID) (*model.StockSubscription, error)
+	Unsubscribe(productID, userID uuid.UUID) error
+}
+
+type notificationRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationRepository(db *sql.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) GetByUser(userID uuid.UUID) ([]model.Notification, error) {
+	query := `
+		SELECT id, user_id, type, product_id, message, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []model.Notification{}
+	for rows.Next() {
+		var notification model.Notification
+		var productID uuid.NullUUID
+		var readAt sql.NullTime
+		err := rows.Scan(
+			&notification.ID,
+			&notification.UserID,
+			&notification.Type,
+			&productID,
+			&notification.Message,
+			&readAt,
+			&notification.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		if productID.Valid {
+			notification.ProductID = &productID.UUID
+		}
+		if readAt.Valid {
+			notification.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+func (r *notificationRepository) MarkRead(id, userID uuid.UUID) error {
+	query := `
+		UPDATE notifications
+		SET read_at = COALESCE(read_at, $1)
+		WHERE id = $2 AND user_id = $3
+	`
+
+	result, err := r.db.Exec(query, time.Now(), id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification read: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("notification not found")
+	}
+
+	return nil
+}
+
+// Subscribe registers the user for a back-in-stock notification. Subscribing
+// again after being notified re-arms the subscription.
+func (r *notificationRepository) Subscribe(productID, userID uuid.UUID) (*model.StockSubscription, error) {
+	query := `
+		INSERT INTO stock_subscriptions (product_id, user_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (product_id, user_id) DO UPDATE SET notified_at = NULL
+		RETURNING created_at
+	`
+
+	subscription := &model.StockSubscription{
+		ProductID: productID,
+		UserID:    userID,
+	}
+
+	err := r.db.QueryRow(query, productID, userID, time.Now()).Scan(&subscription.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	return subscription, nil
+}
+
+func (r *notificationRepository) Unsubscribe(productID, userID uuid.UUID) error {
+	query := `DELETE FROM stock_subscriptions WHERE product_id = $1 AND user_id = $2`
+
+	result, err := r.db.Exec(query, productID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
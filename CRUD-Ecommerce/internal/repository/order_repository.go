@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -10,11 +11,12 @@ import (
 )
 
 type OrderRepository interface {
-	Create(order *model.Order) error
+	Create(order *model.Order, movements []*model.InventoryMovement, wishlistID *uuid.UUID) error
 	GetByID(id uuid.UUID) (*model.Order, error)
 	GetByUserID(userID uuid.UUID) ([]model.Order, error)
 	GetAll() ([]model.Order, error)
-	UpdateStatus(id uuid.UUID, status model.OrderStatus) error
+	UpdateStatus(id uuid.UUID, status model.OrderStatus, version int) error
+	Cancel(id uuid.UUID, version int, movements []*model.InventoryMovement) error
 	Delete(id uuid.UUID) error
 }
 
@@ -26,7 +28,11 @@ func NewOrderRepository(db *sql.DB) OrderRepository {
 	return &orderRepository{db: db}
 }
 
-func (r *orderRepository) Create(order *model.Order) error {
+// Create inserts the order and records the movements that take its stock in
+// one transaction, so an order exists only if its stock was taken. The
+// movements' ReferenceID is set to the new order. With a wishlistID the
+// ordered products are taken off that wishlist in the same transaction.
+func (r *orderRepository) Create(order *model.Order, movements []*model.InventoryMovement, wishlistID *uuid.UUID) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -35,9 +41,9 @@ func (r *orderRepository) Create(order *model.Order) error {
 
 	// Insert order
 	orderQuery := `
-		INSERT INTO orders (id, user_id, status, total_price, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, created_at, updated_at
+		INSERT INTO orders (id, user_id, status, total_price, shipping_region, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, version, created_at, updated_at
 	`
 
 	order.ID = uuid.New()
@@ -54,9 +60,10 @@ func (r *orderRepository) Create(order *model.Order) error {
 		order.UserID,
 		order.Status,
 		order.TotalPrice,
+		order.ShippingRegion,
 		order.CreatedAt,
 		order.UpdatedAt,
-	).Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt)
+	).Scan(&order.ID, &order.Version, &order.CreatedAt, &order.UpdatedAt)
 
 	if err != nil {
 		return fmt.Errorf("failed to create order: %w", err)
@@ -64,8 +71,8 @@ func (r *orderRepository) Create(order *model.Order) error {
 
 	// Insert order items
 	itemQuery := `
-		INSERT INTO order_items (id, order_id, product_id, quantity, price, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO order_items (id, order_id, product_id, product_name, product_sku, quantity, price, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id, created_at
 	`
 
@@ -80,6 +87,8 @@ func (r *orderRepository) Create(order *model.Order) error {
 			item.ID,
 			item.OrderID,
 			item.ProductID,
+			item.ProductName,
+			nullString(item.ProductSKU),
 			item.Quantity,
 			item.Price,
 			item.CreatedAt,
@@ -88,6 +97,33 @@ func (r *orderRepository) Create(order *model.Order) error {
 		if err != nil {
 			return fmt.Errorf("failed to create order item: %w", err)
 		}
+
+		for _, allocation := range item.Allocations {
+			_, err = tx.Exec(
+				`INSERT INTO order_allocations (order_item_id, product_id, warehouse_id, quantity) VALUES ($1, $2, $3, $4)`,
+				item.ID, allocation.ProductID, allocation.WarehouseID, allocation.Quantity,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to create order allocation: %w", err)
+			}
+		}
+	}
+
+	for _, movement := range movements {
+		movement.ReferenceID = &order.ID
+	}
+	if err := recordMovements(tx, movements); err != nil {
+		return err
+	}
+
+	if wishlistID != nil {
+		productIDs := make([]uuid.UUID, len(order.Items))
+		for i, item := range order.Items {
+			productIDs[i] = item.ProductID
+		}
+		if err := removeWishlistItems(tx, *wishlistID, productIDs); err != nil {
+			return err
+		}
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -99,7 +135,7 @@ func (r *orderRepository) Create(order *model.Order) error {
 
 func (r *orderRepository) GetByID(id uuid.UUID) (*model.Order, error) {
 	orderQuery := `
-		SELECT id, user_id, status, total_price, created_at, updated_at
+		SELECT id, user_id, status, total_price, shipping_region, version, created_at, updated_at
 		FROM orders
 		WHERE id = $1
 	`
@@ -110,6 +146,8 @@ func (r *orderRepository) GetByID(id uuid.UUID) (*model.Order, error) {
 		&order.UserID,
 		&order.Status,
 		&order.TotalPrice,
+		&order.ShippingRegion,
+		&order.Version,
 		&order.CreatedAt,
 		&order.UpdatedAt,
 	)
@@ -123,8 +161,14 @@ func (r *orderRepository) GetByID(id uuid.UUID) (*model.Order, error) {
 
 	// Get order items
 	itemsQuery := `
-		SELECT oi.id, oi.order_id, oi.product_id, oi.quantity, oi.price, oi.created_at,
-		       p.id, p.name, p.description, p.price, p.stock, p.category_id, p.image_url, p.created_at, p.updated_at
+		SELECT oi.id, oi.order_id, oi.product_id, oi.product_name, COALESCE(oi.product_sku, ''),
+		       oi.quantity, oi.price, oi.created_at,
+		       COALESCE((
+		           SELECT json_agg(json_build_object('product_id', oa.product_id, 'warehouse_id', oa.warehouse_id, 'quantity', oa.quantity))
+		           FROM order_allocations oa
+		           WHERE oa.order_item_id = oi.id
+		       ), '[]'),
+		       ` + productColumns + `
 		FROM order_items oi
 		LEFT JOIN products p ON oi.product_id = p.id
 		WHERE oi.order_id = $1
@@ -138,29 +182,28 @@ func (r *orderRepository) GetByID(id uuid.UUID) (*model.Order, error) {
 
 	for rows.Next() {
 		var item model.OrderItem
+		var allocations []byte
 		item.Product = &model.Product{}
 
-		err := rows.Scan(
+		err := rows.Scan(append([]interface{}{
 			&item.ID,
 			&item.OrderID,
 			&item.ProductID,
+			&item.ProductName,
+			&item.ProductSKU,
 			&item.Quantity,
 			&item.Price,
 			&item.CreatedAt,
-			&item.Product.ID,
-			&item.Product.Name,
-			&item.Product.Description,
-			&item.Product.Price,
-			&item.Product.Stock,
-			&item.Product.CategoryID,
-			&item.Product.ImageURL,
-			&item.Product.CreatedAt,
-			&item.Product.UpdatedAt,
-		)
+			&allocations,
+		}, productFields(item.Product)...)...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan order item: %w", err)
 		}
 
+		if err := json.Unmarshal(allocations, &item.Allocations); err != nil {
+			return nil, fmt.Errorf("failed to decode order allocations: %w", err)
+		}
+
 		order.Items = append(order.Items, item)
 	}
 
@@ -169,7 +212,7 @@ func (r *orderRepository) GetByID(id uuid.UUID) (*model.Order, error) {
 
 func (r *orderRepository) GetByUserID(userID uuid.UUID) ([]model.Order, error) {
 	query := `
-		SELECT id, user_id, status, total_price, created_at, updated_at
+		SELECT id, user_id, status, total_price, shipping_region, version, created_at, updated_at
 		FROM orders
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -189,6 +232,8 @@ func (r *orderRepository) GetByUserID(userID uuid.UUID) ([]model.Order, error) {
 			&order.UserID,
 			&order.Status,
 			&order.TotalPrice,
+			&order.ShippingRegion,
+			&order.Version,
 			&order.CreatedAt,
 			&order.UpdatedAt,
 		)
@@ -211,7 +256,7 @@ func (r *orderRepository) GetByUserID(userID uuid.UUID) ([]model.Order, error) {
 
 func (r *orderRepository) GetAll() ([]model.Order, error) {
 	query := `
-		SELECT id, user_id, status, total_price, created_at, updated_at
+		SELECT id, user_id, status, total_price, shipping_region, version, created_at, updated_at
 		FROM orders
 		ORDER BY created_at DESC
 	`
@@ -230,6 +275,8 @@ func (r *orderRepository) GetAll() ([]model.Order, error) {
 			&order.UserID,
 			&order.Status,
 			&order.TotalPrice,
+			&order.ShippingRegion,
+			&order.Version,
 			&order.CreatedAt,
 			&order.UpdatedAt,
 		)
@@ -250,16 +297,24 @@ func (r *orderRepository) GetAll() ([]model.Order, error) {
 	return orders, nil
 }
 
-func (r *orderRepository) UpdateStatus(id uuid.UUID, status model.OrderStatus) error {
+// UpdateStatus sets the order's status if it is still at the given version;
+// a zero version updates unconditionally.
+func (r *orderRepository) UpdateStatus(id uuid.UUID, status model.OrderStatus, version int) error {
 	query := `
 		UPDATE orders
-		SET status = $1, updated_at = $2
-		WHERE id = $3
+		SET status = $1, updated_at = $2, version = version + 1
+		WHERE id = $3 AND ($4::int = 0 OR version = $4::int)
 		RETURNING updated_at
 	`
 
 	var updatedAt time.Time
-	err := r.db.QueryRow(query, status, time.Now(), id).Scan(&updatedAt)
+	err := r.db.QueryRow(query, status, time.Now(), id, version).Scan(&updatedAt)
+	if err == sql.ErrNoRows {
+		if version != 0 {
+			return model.ErrVersionMismatch
+		}
+		return fmt.Errorf("order not found")
+	}
 	if err != nil {
 		return fmt.Errorf("failed to update order status: %w", err)
 	}
@@ -267,6 +322,64 @@ func (r *orderRepository) UpdateStatus(id uuid.UUID, status model.OrderStatus) e
 	return nil
 }
 
+// Cancel moves a pending or processing order to cancelled and records the
+// movements that return its stock in one transaction. The status check is
+// part of the update, so an order is cancelled, and restocked, only once.
+// A zero version skips the version check.
+func (r *orderRepository) Cancel(id uuid.UUID, version int, movements []*model.InventoryMovement) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE orders
+		SET status = $1, updated_at = $2, version = version + 1
+		WHERE id = $3 AND status IN ($4, $5) AND ($6::int = 0 OR version = $6::int)
+	`
+
+	result, err := tx.Exec(
+		query,
+		model.OrderStatusCancelled, time.Now(), id,
+		model.OrderStatusPending, model.OrderStatusProcessing, version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to cancel order: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		var status model.OrderStatus
+		var current int
+		err := tx.QueryRow(`SELECT status, version FROM orders WHERE id = $1`, id).Scan(&status, &current)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("order not found")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get order: %w", err)
+		}
+		if version != 0 && current != version {
+			return model.ErrVersionMismatch
+		}
+		return fmt.Errorf("order cannot be cancelled in current status: %s", status)
+	}
+
+	if err := recordMovements(tx, movements); err != nil {
+		return fmt.Errorf("failed to restore stock: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
 func (r *orderRepository) Delete(id uuid.UUID) error {
 	query := `DELETE FROM orders WHERE id = $1`
 
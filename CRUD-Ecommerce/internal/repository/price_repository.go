@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+)
+
+type PriceRepository interface {
+	Create(price *model.ProductPrice) error
+	GetByProduct(productID uuid.UUID) ([]model.ProductPrice, error)
+	Cancel(id, productID uuid.UUID) error
+	RefreshEffectivePrices() (int, error)
+}
+
+type priceRepository struct {
+	db *sql.DB
+}
+
+func NewPriceRepository(db *sql.DB) PriceRepository {
+	return &priceRepository{db: db}
+}
+
+func (r *priceRepository) Create(price *model.ProductPrice) error {
+	query := `
+		INSERT INTO product_prices (id, product_id, kind, price, compare_at_price, starts_at, ends_at, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	price.ID = uuid.New()
+	price.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(
+		query,
+		price.ID,
+		price.ProductID,
+		price.Kind,
+		price.Price,
+		price.CompareAtPrice,
+		price.StartsAt,
+		price.EndsAt,
+		price.CreatedBy,
+		price.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create price: %w", err)
+	}
+
+	return nil
+}
+
+// GetByProduct returns the product's full price history, latest first.
+func (r *priceRepository) GetByProduct(productID uuid.UUID) ([]model.ProductPrice, error) {
+	query := `
+		SELECT id, product_id, kind, price, compare_at_price, starts_at, ends_at, created_by, created_at
+		FROM product_prices
+		WHERE product_id = $1
+		ORDER BY starts_at DESC, created_at DESC
+	`
+
+	rows, err := r.db.Query(query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prices: %w", err)
+	}
+	defer rows.Close()
+
+	prices := []model.ProductPrice{}
+	for rows.Next() {
+		var price model.ProductPrice
+		var createdBy uuid.NullUUID
+		err := rows.Scan(
+			&price.ID,
+			&price.ProductID,
+			&price.Kind,
+			&price.Price,
+			&price.CompareAtPrice,
+			&price.StartsAt,
+			&price.EndsAt,
+			&createdBy,
+			&price.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan price: %w", err)
+		}
+		if createdBy.Valid {
+			price.CreatedBy = &createdBy.UUID
+		}
+		prices = append(prices, price)
+	}
+
+	return prices, nil
+}
+
+// Cancel withdraws a scheduled price. One that has not started yet is
+// removed; one that is running is ended now so that it stays in the history.
+func (r *priceRepository) Cancel(id, productID uuid.UUID) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`DELETE FROM product_prices
+		WHERE id = $1 AND product_id = $2 AND kind = $3 AND starts_at > NOW()`,
+		id, productID, model.PriceKindScheduled,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to cancel price: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		result, err = tx.Exec(
+			`UPDATE product_prices SET ends_at = NOW()
+			WHERE id = $1 AND product_id = $2 AND kind = $3 AND (ends_at IS NULL OR ends_at > NOW())`,
+			id, productID, model.PriceKindScheduled,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to cancel price: %w", err)
+		}
+
+		if rows, err = result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to get affected rows: %w", err)
+		}
+		if rows == 0 {
+			return fmt.Errorf("scheduled price not found or already ended")
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RefreshEffectivePrices brings the stored effective price of products with
+// scheduled prices up to date with windows that have opened or closed since
+// it was last set. Returns the number of products whose price changed.
+func (r *priceRepository) RefreshEffectivePrices() (int, error) {
+	query := `
+		UPDATE products p SET effective_price = product_effective_price(p.id, p.price)
+		WHERE EXISTS (SELECT 1 FROM product_prices pp WHERE pp.product_id = p.id AND pp.kind = $1)
+		  AND p.effective_price IS DISTINCT FROM product_effective_price(p.id, p.price)
+	`
+
+	result, err := r.db.Exec(query, model.PriceKindScheduled)
+	if err != nil {
+		return 0, fmt.Errorf("failed to refresh effective prices: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return int(rows), nil
+}
@@ -2,22 +2,97 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type ProductRepository interface {
-	Create(product *model.Product) error
+	Create(product *model.Product, changes ProductChanges) error
 	GetByID(id uuid.UUID) (*model.Product, error)
+	GetBySlug(slug string) (*model.Product, error)
+	GetSlugRedirect(slug string) (string, error)
+	SlugExists(slug string, excludeID uuid.UUID) (bool, error)
 	GetAll(params model.ProductQueryParams) ([]model.Product, error)
+	List(params model.ProductQueryParams) (*model.ProductPage, error)
+	Search(params model.ProductQueryParams, fuzzy bool) ([]model.ProductSearchResult, error)
+	HasFullTextMatch(params model.ProductQueryParams) (bool, error)
+	GetFacets(params model.ProductQueryParams, fuzzy bool) (*model.ProductFacets, error)
 	GetByCategory(categoryID uuid.UUID) ([]model.Product, error)
-	Update(product *model.Product) error
-	UpdateStock(id uuid.UUID, stock int) error
-	Delete(id uuid.UUID) error
+	Update(product *model.Product, changes ProductChanges) error
+	UpdateStatus(id uuid.UUID, status model.ProductStatus) error
+	UpsertBySKU(product *model.Product, stock *StockTarget) (bool, error)
+	ExportRows(status model.ProductStatus) ([]model.CatalogRow, error)
+}
+
+type productSortSpec struct {
+	expr string // ordering expression
+	cast string // type the cursor value is cast back to
+	desc bool
+}
+
+var productSorts = map[model.ProductSort]productSortSpec{
+	model.ProductSortNewest:     {expr: "p.created_at", cast: "timestamp", desc: true},
+	model.ProductSortPrice:      {expr: indexedPrice, cast: "numeric"},
+	model.ProductSortPriceDesc:  {expr: indexedPrice, cast: "numeric", desc: true},
+	model.ProductSortName:       {expr: "p.name", cast: "text"},
+	model.ProductSortPopularity: {expr: "COALESCE(sales.units_sold, 0)", cast: "bigint", desc: true},
+	model.ProductSortRating:     {expr: "p.average_rating", cast: "numeric", desc: true},
+	model.ProductSortRelevance:  {expr: "ts_rank(p.search_vector, websearch_to_tsquery('english', $1))", cast: "real", desc: true},
+}
+
+// effectivePrice is the price a product sells at right now, taking scheduled
+// prices into account. It is what products show and orders charge.
+const effectivePrice = "product_effective_price(p.id, p.price)"
+
+// indexedPrice is the effective price as stored on the product row, where an
+// index can serve it. It follows price changes as they are made and scheduled
+// windows opening or closing on the next price refresh. Filters, sorts and
+// facets on price use it rather than p.price.
+const indexedPrice = "p.effective_price"
+
+// availableStock is how many units of a product can be sold: its own stock,
+// or for a bundle the number of complete sets its components make up.
+// Stock filters and facets use it rather than p.stock.
+const availableStock = "product_available_stock(p.id, p.type, p.stock)"
+
+// productColumns lists the products columns read by every product query, in
+// the order expected by productFields.
+const productColumns = `p.id, COALESCE(p.sku, ''), p.name, COALESCE(p.slug, ''), p.type, p.description,
+		       ` + effectivePrice + `, product_compare_at_price(p.id, p.price), p.price, ` + availableStock + `, p.category_id,
+		       p.image_url, p.status, p.reorder_threshold, product_attributes(p.id),
+		       p.average_rating, p.review_count, p.version, p.created_at, p.updated_at`
+
+// productFields returns scan destinations matching productColumns.
+func productFields(product *model.Product) []interface{} {
+	return []interface{}{
+		&product.ID,
+		&product.SKU,
+		&product.Name,
+		&product.Slug,
+		&product.Type,
+		&product.Description,
+		&product.Price,
+		&product.CompareAtPrice,
+		&product.BasePrice,
+		&product.Stock,
+		&product.CategoryID,
+		&product.ImageURL,
+		&product.Status,
+		&product.ReorderThreshold,
+		jsonObject{&product.Attributes},
+		&product.AverageRating,
+		&product.ReviewCount,
+		&product.Version,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+	}
 }
 
 type productRepository struct {
@@ -28,70 +103,141 @@ func NewProductRepository(db *sql.DB) ProductRepository {
 	return &productRepository{db: db}
 }
 
-func (r *productRepository) Create(product *model.Product) error {
+// ProductChanges are written together with a product, in the same
+// transaction.
+type ProductChanges struct {
+	Attributes        []model.AttributeValue  // upserted
+	RemovedAttributes []uuid.UUID             // attribute IDs whose values are deleted
+	Stock             *StockTarget            // nil leaves stock alone
+	Components        []model.BundleComponent // replace a bundle's components; nil leaves them alone
+}
+
+// StockTarget brings a product's stock to Level through the ledger, recording
+// the movements with Movement's reason, reference and actor.
+type StockTarget struct {
+	Level    int
+	Movement model.InventoryMovement
+}
+
+// Create inserts the product with its attribute values, opening stock and,
+// for a bundle, its components in one transaction. Products are inserted
+// with no stock; changes.Stock sets the opening level through the ledger.
+func (r *productRepository) Create(product *model.Product, changes ProductChanges) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO products (id, name, description, price, stock, category_id, image_url, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id, created_at, updated_at
+		INSERT INTO products (id, sku, name, slug, type, description, price, stock, category_id, image_url, status, reorder_threshold, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id, version, created_at, updated_at
 	`
 
 	product.ID = uuid.New()
 	product.CreatedAt = time.Now()
 	product.UpdatedAt = time.Now()
 
-	err := r.db.QueryRow(
+	if product.Status == "" {
+		product.Status = model.ProductStatusActive
+	}
+	if product.Type == "" {
+		product.Type = model.ProductTypeSimple
+	}
+
+	err = tx.QueryRow(
 		query,
 		product.ID,
+		nullString(product.SKU),
 		product.Name,
+		nullString(product.Slug),
+		product.Type,
 		product.Description,
-		product.Price,
+		product.BasePrice,
 		product.Stock,
 		product.CategoryID,
 		product.ImageURL,
+		product.Status,
+		product.ReorderThreshold,
 		product.CreatedAt,
 		product.UpdatedAt,
-	).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt)
+	).Scan(&product.ID, &product.Version, &product.CreatedAt, &product.UpdatedAt)
 
 	if err != nil {
-		return fmt.Errorf("failed to create product: %w", err)
+		return slugConflict(err, productSlugIndex, "create product")
+	}
+
+	if err := writeProductChanges(tx, product, changes); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func writeProductChanges(tx *sql.Tx, product *model.Product, changes ProductChanges) error {
+	if err := setAttributeValues(tx, product.ID, changes.Attributes, changes.RemovedAttributes); err != nil {
+		return err
+	}
+
+	if changes.Components != nil {
+		if err := setComponents(tx, product.ID, changes.Components); err != nil {
+			return err
+		}
+	}
+
+	if changes.Stock != nil {
+		template := changes.Stock.Movement
+		template.ProductID = product.ID
+		movements, err := setStock(tx, template, changes.Stock.Level)
+		if err != nil {
+			return fmt.Errorf("failed to update stock: %w", err)
+		}
+		if len(movements) > 0 {
+			product.Stock = movements[len(movements)-1].StockAfter
+		}
 	}
 
 	return nil
 }
 
 func (r *productRepository) GetByID(id uuid.UUID) (*model.Product, error) {
+	return r.getOne(`p.id = $1`, id)
+}
+
+// GetBySlug looks a product up by its current slug.
+func (r *productRepository) GetBySlug(slug string) (*model.Product, error) {
+	return r.getOne(`p.slug = $1`, slug)
+}
+
+func (r *productRepository) getOne(where string, arg interface{}) (*model.Product, error) {
 	query := `
-		SELECT p.id, p.name, p.description, p.price, p.stock, p.category_id, p.image_url, 
-		       p.created_at, p.updated_at,
-		       c.id, c.name, c.description, c.created_at, c.updated_at
+		SELECT ` + productColumns + `,
+		       c.id, c.name, c.slug, c.description, c.created_at, c.updated_at
 		FROM products p
 		LEFT JOIN categories c ON p.category_id = c.id
-		WHERE p.id = $1
-	`
+		WHERE ` + where
 
 	product := &model.Product{Category: &model.Category{}}
 	var categoryID sql.NullString
 	var categoryName sql.NullString
+	var categorySlug sql.NullString
 	var categoryDesc sql.NullString
 	var categoryCreated sql.NullTime
 	var categoryUpdated sql.NullTime
 
-	err := r.db.QueryRow(query, id).Scan(
-		&product.ID,
-		&product.Name,
-		&product.Description,
-		&product.Price,
-		&product.Stock,
-		&product.CategoryID,
-		&product.ImageURL,
-		&product.CreatedAt,
-		&product.UpdatedAt,
+	err := r.db.QueryRow(query, arg).Scan(append(productFields(product),
 		&categoryID,
 		&categoryName,
+		&categorySlug,
 		&categoryDesc,
 		&categoryCreated,
 		&categoryUpdated,
-	)
+	)...)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("product not found")
@@ -104,6 +250,7 @@ func (r *productRepository) GetByID(id uuid.UUID) (*model.Product, error) {
 		categoryUUID, _ := uuid.Parse(categoryID.String)
 		product.Category.ID = categoryUUID
 		product.Category.Name = categoryName.String
+		product.Category.Slug = categorySlug.String
 		product.Category.Description = categoryDesc.String
 		product.Category.CreatedAt = categoryCreated.Time
 		product.Category.UpdatedAt = categoryUpdated.Time
@@ -114,150 +261,386 @@ func (r *productRepository) GetByID(id uuid.UUID) (*model.Product, error) {
 	return product, nil
 }
 
+// GetSlugRedirect returns the current slug of the product a retired slug
+// belonged to.
+func (r *productRepository) GetSlugRedirect(slug string) (string, error) {
+	return slugRedirect(r.db, "products", slugEntityProduct, slug)
+}
+
+// SlugExists reports whether slug belongs, or redirects, to a product other
+// than excludeID.
+func (r *productRepository) SlugExists(slug string, excludeID uuid.UUID) (bool, error) {
+	return slugTaken(r.db, "products", slugEntityProduct, slug, excludeID)
+}
+
 func (r *productRepository) GetAll(params model.ProductQueryParams) ([]model.Product, error) {
 	query := `
-		SELECT p.id, p.name, p.description, p.price, p.stock, p.category_id, p.image_url, 
-		       p.created_at, p.updated_at
+		SELECT ` + productColumns + `
 		FROM products p
 		WHERE 1=1
 	`
 
-	var args []interface{}
-	argPos := 1
+	filters, args := buildProductFilters(params, nil)
+	query += filters
+	argPos := len(args) + 1
+
+	if params.Search != "" {
+		query += fmt.Sprintf(" AND p.search_vector @@ websearch_to_tsquery('english', $%d)", argPos)
+		query += fmt.Sprintf(" ORDER BY ts_rank(p.search_vector, websearch_to_tsquery('english', $%d)) DESC, p.created_at DESC", argPos)
+		args = append(args, params.Search)
+	} else {
+		query += " ORDER BY p.created_at DESC"
+	}
+
+	query += buildPagination(params, &args)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products: %w", err)
+	}
+	defer rows.Close()
+
+	var products []model.Product
+	for rows.Next() {
+		var product model.Product
+		err := rows.Scan(productFields(&product)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, product)
+	}
+
+	return products, nil
+}
 
-	if params.CategoryID != uuid.Nil {
+// buildProductFilters appends the non-search filters in params to args and
+// returns the matching SQL fragment, numbering placeholders after existing args.
+func buildProductFilters(params model.ProductQueryParams, args []interface{}) (string, []interface{}) {
+	query := ""
+	argPos := len(args) + 1
+
+	if params.CategoryID != uuid.Nil && params.IncludeDescendants {
+		query += fmt.Sprintf(`
+			AND p.category_id IN (
+				WITH RECURSIVE subtree AS (
+					SELECT id FROM categories WHERE id = $%d
+					UNION
+					SELECT c.id FROM categories c JOIN subtree ON c.parent_id = subtree.id
+				)
+				SELECT id FROM subtree
+			)`, argPos)
+		args = append(args, params.CategoryID)
+		argPos++
+	} else if params.CategoryID != uuid.Nil {
 		query += fmt.Sprintf(" AND p.category_id = $%d", argPos)
 		args = append(args, params.CategoryID)
 		argPos++
 	}
 
 	if params.MinPrice > 0 {
-		query += fmt.Sprintf(" AND p.price >= $%d", argPos)
+		query += fmt.Sprintf(" AND %s >= $%d", indexedPrice, argPos)
 		args = append(args, params.MinPrice)
 		argPos++
 	}
 
 	if params.MaxPrice > 0 {
-		query += fmt.Sprintf(" AND p.price <= $%d", argPos)
+		query += fmt.Sprintf(" AND %s <= $%d", indexedPrice, argPos)
 		args = append(args, params.MaxPrice)
 		argPos++
 	}
 
-	if params.Search != "" {
-		query += fmt.Sprintf(" AND (p.name ILIKE $%d OR p.description ILIKE $%d)", argPos, argPos)
-		args = append(args, "%"+params.Search+"%")
+	if params.Status != "" {
+		query += fmt.Sprintf(" AND p.status = $%d", argPos)
+		args = append(args, params.Status)
 		argPos++
 	}
 
-	query += " ORDER BY p.created_at DESC"
-
-	if params.PageSize > 0 {
-		query += fmt.Sprintf(" LIMIT $%d", argPos)
-		args = append(args, params.PageSize)
+	if params.MinRating > 0 {
+		query += fmt.Sprintf(" AND p.average_rating >= $%d", argPos)
+		args = append(args, params.MinRating)
 		argPos++
+	}
+
+	if params.InStock != nil {
+		if *params.InStock {
+			query += " AND " + availableStock + " > 0"
+		} else {
+			query += " AND " + availableStock + " <= 0"
+		}
+	}
 
-		if params.Page > 0 {
-			offset := (params.Page - 1) * params.PageSize
-			query += fmt.Sprintf(" OFFSET $%d", argPos)
-			args = append(args, offset)
+	for _, filter := range params.Attributes {
+		condition, value, ok := attributeCondition(filter, argPos+1)
+		if !ok {
+			continue
 		}
+
+		query += fmt.Sprintf(`
+			AND EXISTS (
+				SELECT 1 FROM product_attribute_values v
+				JOIN attribute_definitions d ON d.id = v.attribute_id
+				WHERE v.product_id = p.id AND d.code = $%d AND %s
+			)`, argPos, condition)
+		args = append(args, filter.Code, value)
+		argPos += 2
+	}
+
+	return query, args
+}
+
+// attributeCondition returns the predicate on v for one attribute filter and
+// the value bound to placeholder argPos. Range operators need a number; eq and
+// in compare numerically when every value is a number and as case-insensitive
+// text otherwise. Filters that cannot be applied are reported as not ok.
+func attributeCondition(filter model.AttributeFilter, argPos int) (string, interface{}, bool) {
+	operators := map[model.AttributeFilterOp]string{
+		model.AttributeFilterGt:  ">",
+		model.AttributeFilterGte: ">=",
+		model.AttributeFilterLt:  "<",
+		model.AttributeFilterLte: "<=",
+	}
+
+	if operator, ok := operators[filter.Op]; ok {
+		number, err := strconv.ParseFloat(filter.Value, 64)
+		if err != nil {
+			return "", nil, false
+		}
+		return fmt.Sprintf("v.value_number %s $%d", operator, argPos), number, true
+	}
+
+	values := []string{filter.Value}
+	if filter.Op == model.AttributeFilterIn {
+		values = strings.Split(filter.Value, ",")
+	}
+
+	numbers := make([]float64, 0, len(values))
+	texts := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		texts = append(texts, strings.ToLower(value))
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			numbers = append(numbers, number)
+		}
+	}
+
+	if len(numbers) == len(values) {
+		return fmt.Sprintf("v.value_number = ANY($%d::numeric[])", argPos), pq.Array(numbers), true
+	}
+	return fmt.Sprintf("lower(v.value_text) = ANY($%d::text[])", argPos), pq.Array(texts), true
+}
+
+func buildPagination(params model.ProductQueryParams, args *[]interface{}) string {
+	if params.PageSize <= 0 {
+		return ""
 	}
 
+	argPos := len(*args) + 1
+	query := fmt.Sprintf(" LIMIT $%d", argPos)
+	*args = append(*args, params.PageSize)
+	argPos++
+
+	if params.Page > 0 {
+		query += fmt.Sprintf(" OFFSET $%d", argPos)
+		*args = append(*args, (params.Page-1)*params.PageSize)
+	}
+
+	return query
+}
+
+// List returns one keyset-paginated page of products. The sort key is
+// selected as text so that cursors replay the exact stored value, and p.id
+// breaks ties so that pages never overlap or skip rows.
+func (r *productRepository) List(params model.ProductQueryParams) (*model.ProductPage, error) {
+	spec, ok := productSorts[params.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort: %s", params.Sort)
+	}
+
+	_, _, predicate, args := searchClauses(params.Search, false)
+	filters, args := buildProductFilters(params, args)
+	where := " WHERE 1=1" + predicate + filters
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM products p"+where, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count products: %w", err)
+	}
+
+	from := " FROM products p"
+	if params.Sort == model.ProductSortPopularity {
+		from += `
+			LEFT JOIN (
+				SELECT oi.product_id, SUM(oi.quantity) AS units_sold
+				FROM order_items oi
+				JOIN orders o ON o.id = oi.order_id
+				WHERE o.status <> 'cancelled'
+				GROUP BY oi.product_id
+			) sales ON sales.product_id = p.id`
+	}
+
+	cursor := params.Cursor
+	backward := cursor != nil && cursor.Backward
+	desc := spec.desc != backward
+
+	if cursor != nil {
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		where += fmt.Sprintf(" AND (%s, p.id) %s ($%d::%s, $%d)", spec.expr, op, len(args)+1, spec.cast, len(args)+2)
+		args = append(args, cursor.Value, cursor.ID)
+	}
+
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT `+productColumns+`, (%s)::text
+		%s%s
+		ORDER BY %s %s, p.id %s
+		LIMIT $%d
+	`, spec.expr, from, where, spec.expr, dir, dir, len(args)+1)
+	args = append(args, params.PageSize+1)
+
 	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get products: %w", err)
 	}
 	defer rows.Close()
 
-	var products []model.Product
+	products := []model.Product{}
+	var keys []string
 	for rows.Next() {
 		var product model.Product
-		err := rows.Scan(
-			&product.ID,
-			&product.Name,
-			&product.Description,
-			&product.Price,
-			&product.Stock,
-			&product.CategoryID,
-			&product.ImageURL,
-			&product.CreatedAt,
-			&product.UpdatedAt,
-		)
+		var key string
+		err := rows.Scan(append(productFields(&product), &key)...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan product: %w", err)
 		}
 		products = append(products, product)
+		keys = append(keys, key)
 	}
 
-	return products, nil
+	hasMore := len(products) > params.PageSize
+	if hasMore {
+		products = products[:params.PageSize]
+		keys = keys[:params.PageSize]
+	}
+
+	if backward {
+		for i, j := 0, len(products)-1; i < j; i, j = i+1, j-1 {
+			products[i], products[j] = products[j], products[i]
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	page := &model.ProductPage{
+		Products: products,
+		Pagination: model.Pagination{
+			Total:    total,
+			PageSize: params.PageSize,
+		},
+	}
+
+	if len(products) == 0 {
+		return page, nil
+	}
+
+	last := len(products) - 1
+	if (!backward && hasMore) || backward {
+		next := model.Cursor{Sort: string(params.Sort), Value: keys[last], ID: products[last].ID}
+		page.Pagination.NextCursor = next.Encode()
+	}
+	if (backward && hasMore) || (!backward && cursor != nil) {
+		prev := model.Cursor{Sort: string(params.Sort), Value: keys[0], ID: products[0].ID, Backward: true}
+		page.Pagination.PrevCursor = prev.Encode()
+	}
+
+	return page, nil
 }
 
 func (r *productRepository) GetByCategory(categoryID uuid.UUID) ([]model.Product, error) {
-	return r.GetAll(model.ProductQueryParams{CategoryID: categoryID})
+	return r.GetAll(model.ProductQueryParams{CategoryID: categoryID, Status: model.ProductStatusActive})
 }
 
-func (r *productRepository) Update(product *model.Product) error {
-	updates := []string{}
-	args := []interface{}{}
-	argPos := 1
+// Update writes every editable column; callers start from the stored
+// product so that fields they do not change keep their values. Attribute
+// and stock changes are written in the same transaction, stock through the
+// inventory ledger. The update only applies if product.Version is still the
+// stored version, and product.Version is moved on to the new one.
+func (r *productRepository) Update(product *model.Product, changes ProductChanges) error {
+	query := `
+		UPDATE products
+		SET sku = $1, name = $2, slug = $3, description = $4, price = $5, category_id = $6,
+		    image_url = $7, status = $8, reorder_threshold = $9, updated_at = $10, version = version + 1
+		WHERE id = $11
+		RETURNING version, updated_at
+	`
 
-	if product.Name != "" {
-		updates = append(updates, fmt.Sprintf("name = $%d", argPos))
-		args = append(args, product.Name)
-		argPos++
-	}
+	product.UpdatedAt = time.Now()
 
-	if product.Description != "" {
-		updates = append(updates, fmt.Sprintf("description = $%d", argPos))
-		args = append(args, product.Description)
-		argPos++
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	if product.Price > 0 {
-		updates = append(updates, fmt.Sprintf("price = $%d", argPos))
-		args = append(args, product.Price)
-		argPos++
+	// Lock the row so the version check holds until commit and the slug
+	// being retired is the one actually replaced
+	var oldSlug sql.NullString
+	var version int
+	err = tx.QueryRow(`SELECT slug, version FROM products WHERE id = $1 FOR UPDATE`, product.ID).Scan(&oldSlug, &version)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("product not found")
 	}
-
-	if product.Stock >= 0 {
-		updates = append(updates, fmt.Sprintf("stock = $%d", argPos))
-		args = append(args, product.Stock)
-		argPos++
+	if err != nil {
+		return fmt.Errorf("failed to update product: %w", err)
 	}
-
-	if product.ImageURL != "" {
-		updates = append(updates, fmt.Sprintf("image_url = $%d", argPos))
-		args = append(args, product.ImageURL)
-		argPos++
+	if version != product.Version {
+		return model.ErrVersionMismatch
 	}
 
-	if len(updates) == 0 {
-		return fmt.Errorf("no fields to update")
+	err = tx.QueryRow(
+		query,
+		nullString(product.SKU),
+		product.Name,
+		nullString(product.Slug),
+		product.Description,
+		product.BasePrice,
+		product.CategoryID,
+		product.ImageURL,
+		product.Status,
+		product.ReorderThreshold,
+		product.UpdatedAt,
+		product.ID,
+	).Scan(&product.Version, &product.UpdatedAt)
+
+	if err != nil {
+		return slugConflict(err, productSlugIndex, "update product")
 	}
 
-	product.UpdatedAt = time.Now()
-	updates = append(updates, fmt.Sprintf("updated_at = $%d", argPos))
-	args = append(args, product.UpdatedAt)
-	argPos++
+	if err := moveSlug(tx, slugEntityProduct, product.ID, oldSlug.String, product.Slug); err != nil {
+		return err
+	}
 
-	args = append(args, product.ID)
-	query := fmt.Sprintf("UPDATE products SET %s WHERE id = $%d RETURNING updated_at",
-		strings.Join(updates, ", "), argPos)
+	if err := writeProductChanges(tx, product, changes); err != nil {
+		return err
+	}
 
-	err := r.db.QueryRow(query, args...).Scan(&product.UpdatedAt)
-	if err != nil {
-		return fmt.Errorf("failed to update product: %w", err)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-func (r *productRepository) UpdateStock(id uuid.UUID, stock int) error {
-	query := `UPDATE products SET stock = $1, updated_at = $2 WHERE id = $3`
+func (r *productRepository) UpdateStatus(id uuid.UUID, status model.ProductStatus) error {
+	query := `UPDATE products SET status = $1, updated_at = $2, version = version + 1 WHERE id = $3`
 
-	result, err := r.db.Exec(query, stock, time.Now(), id)
+	result, err := r.db.Exec(query, status, time.Now(), id)
 	if err != nil {
-		return fmt.Errorf("failed to update stock: %w", err)
+		return fmt.Errorf("failed to update product status: %w", err)
 	}
 
 	rows, err := result.RowsAffected()
@@ -272,22 +655,130 @@ func (r *productRepository) UpdateStock(id uuid.UUID, stock int) error {
 	return nil
 }
 
-func (r *productRepository) Delete(id uuid.UUID) error {
-	query := `DELETE FROM products WHERE id = $1`
+// UpsertBySKU inserts the product or overwrites the one sharing its SKU,
+// reporting whether a new row was created. An empty status keeps the
+// existing product's status, or defaults to active for new products.
+// New products start with no stock; a non-nil stock brings the product to
+// its level through the ledger in the same transaction, except for bundles,
+// which have no stock of their own. product.Slug is only used for new
+// products; existing ones keep their slug.
+func (r *productRepository) UpsertBySKU(product *model.Product, stock *StockTarget) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO products (id, sku, name, description, price, stock, category_id, image_url, status, created_at, updated_at, slug)
+		VALUES ($1, $2, $3, $4, $5, 0, $6, $7, COALESCE(NULLIF($8::text, ''), 'active'), $9, $9, $10)
+		ON CONFLICT (sku) DO UPDATE SET
+			name = EXCLUDED.name,
+			description = EXCLUDED.description,
+			price = EXCLUDED.price,
+			category_id = EXCLUDED.category_id,
+			image_url = EXCLUDED.image_url,
+			status = COALESCE(NULLIF($8::text, ''), products.status),
+			updated_at = EXCLUDED.updated_at,
+			version = products.version + 1
+		RETURNING id, COALESCE(slug, ''), type, stock, status, version, created_at, updated_at, (xmax = 0) AS inserted
+	`
+
+	var inserted bool
+	err = tx.QueryRow(
+		query,
+		uuid.New(),
+		product.SKU,
+		product.Name,
+		product.Description,
+		product.BasePrice,
+		product.CategoryID,
+		product.ImageURL,
+		string(product.Status),
+		time.Now(),
+		nullString(product.Slug),
+	).Scan(&product.ID, &product.Slug, &product.Type, &product.Stock, &product.Status, &product.Version, &product.CreatedAt, &product.UpdatedAt, &inserted)
 
-	result, err := r.db.Exec(query, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete product: %w", err)
+		return false, slugConflict(err, productSlugIndex, "upsert product")
 	}
 
-	rows, err := result.RowsAffected()
+	if product.Type == model.ProductTypeBundle {
+		stock = nil
+	}
+	if err := writeProductChanges(tx, product, ProductChanges{Stock: stock}); err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return inserted, nil
+}
+
+func (r *productRepository) ExportRows(status model.ProductStatus) ([]model.CatalogRow, error) {
+	query := `
+		SELECT COALESCE(p.sku, ''), p.name, COALESCE(p.description, ''), p.price, ` + availableStock + `,
+		       COALESCE(c.name, ''), COALESCE(p.image_url, ''), p.status
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE $1::text = '' OR p.status = $1::text
+		ORDER BY p.sku ASC NULLS LAST, p.created_at ASC
+	`
+
+	rows, err := r.db.Query(query, string(status))
 	if err != nil {
-		return fmt.Errorf("failed to get affected rows: %w", err)
+		return nil, fmt.Errorf("failed to export products: %w", err)
 	}
+	defer rows.Close()
 
-	if rows == 0 {
-		return fmt.Errorf("product not found")
+	var catalog []model.CatalogRow
+	for rows.Next() {
+		var row model.CatalogRow
+		err := rows.Scan(
+			&row.SKU,
+			&row.Name,
+			&row.Description,
+			&row.Price,
+			&row.Stock,
+			&row.Category,
+			&row.ImageURL,
+			&row.Status,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		catalog = append(catalog, row)
 	}
 
-	return nil
+	return catalog, nil
+}
+
+// jsonObject scans a JSON object column into a map.
+type jsonObject struct {
+	dest *map[string]interface{}
+}
+
+func (j jsonObject) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*j.dest = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSON object", src)
+	}
+
+	return json.Unmarshal(data, j.dest)
+}
+
+// nullString stores empty strings as NULL so that optional unique columns
+// such as sku do not collide on ”.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// priceBucketBounds are the lower bounds of the price range facet buckets.
+// The last bucket is open-ended.
+var priceBucketBounds = []float64{0, 25, 50, 100, 250, 500, 1000}
+
+// Search ranks products against a web-style query (quoted phrases, OR, -term).
+// A fuzzy search matches on trigram similarity to the product name instead of
+// the full-text index, so that misspelled queries still return results. An
+// empty query lists every product that matches the other filters.
+func (r *productRepository) Search(params model.ProductQueryParams, fuzzy bool) ([]model.ProductSearchResult, error) {
+	return r.searchProducts(params, fuzzy && params.Search != "")
+}
+
+// HasFullTextMatch reports whether any product matches the query on the
+// full-text index and the other filters, regardless of pagination. Searches
+// without one fall back to fuzzy matching.
+func (r *productRepository) HasFullTextMatch(params model.ProductQueryParams) (bool, error) {
+	_, _, predicate, args := searchClauses(params.Search, false)
+	filters, args := buildProductFilters(params, args)
+
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM products p WHERE 1=1%s%s)`, predicate, filters)
+
+	var exists bool
+	if err := r.db.QueryRow(query, args...).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to search products: %w", err)
+	}
+
+	return exists, nil
+}
+
+func (r *productRepository) searchProducts(params model.ProductQueryParams, fuzzy bool) ([]model.ProductSearchResult, error) {
+	rank, snippet, predicate, args := searchClauses(params.Search, fuzzy)
+	filters, args := buildProductFilters(params, args)
+
+	query := fmt.Sprintf(`
+		SELECT `+productColumns+`,
+		       %s AS rank,
+		       %s AS snippet
+		FROM products p
+		WHERE 1=1%s%s
+		ORDER BY rank DESC, p.created_at DESC
+	`, rank, snippet, predicate, filters)
+	query += buildPagination(params, &args)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search products: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.ProductSearchResult
+	for rows.Next() {
+		result := model.ProductSearchResult{Fuzzy: fuzzy}
+		err := rows.Scan(append(productFields(&result.Product), &result.Rank, &result.Snippet)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// GetFacets counts the products matching params along each facet dimension.
+// Every facet honours all active filters except its own, so selecting a
+// category still shows how many products the sibling categories hold.
+func (r *productRepository) GetFacets(params model.ProductQueryParams, fuzzy bool) (*model.ProductFacets, error) {
+	facets := &model.ProductFacets{}
+	var err error
+
+	withoutCategory := params
+	withoutCategory.CategoryID = uuid.Nil
+	if facets.Categories, err = r.categoryFacet(withoutCategory, fuzzy); err != nil {
+		return nil, err
+	}
+
+	withoutPrice := params
+	withoutPrice.MinPrice, withoutPrice.MaxPrice = 0, 0
+	if facets.PriceRanges, err = r.priceRangeFacet(withoutPrice, fuzzy); err != nil {
+		return nil, err
+	}
+
+	withoutStock := params
+	withoutStock.InStock = nil
+	if facets.Availability, err = r.availabilityFacet(withoutStock, fuzzy); err != nil {
+		return nil, err
+	}
+
+	if facets.Attributes, err = r.attributeFacets(params, fuzzy); err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
+
+// attributeFacets counts enum and boolean attribute values. Attributes that
+// are not filtered on share one query; each filtered attribute gets its own
+// query without its filter, like the other facets.
+func (r *productRepository) attributeFacets(params model.ProductQueryParams, fuzzy bool) ([]model.AttributeFacet, error) {
+	filtered := []string{}
+	for _, filter := range params.Attributes {
+		filtered = append(filtered, filter.Code)
+	}
+
+	facets, err := r.attributeFacet(params, fuzzy, "d.code <> ALL($%d::text[])", pq.Array(filtered))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, code := range filtered {
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+
+		withoutAttribute := params
+		withoutAttribute.Attributes = nil
+		for _, filter := range params.Attributes {
+			if filter.Code != code {
+				withoutAttribute.Attributes = append(withoutAttribute.Attributes, filter)
+			}
+		}
+
+		facet, err := r.attributeFacet(withoutAttribute, fuzzy, "d.code = $%d", code)
+		if err != nil {
+			return nil, err
+		}
+		facets = append(facets, facet...)
+	}
+
+	sort.Slice(facets, func(i, j int) bool { return facets[i].Code < facets[j].Code })
+	return facets, nil
+}
+
+func (r *productRepository) attributeFacet(params model.ProductQueryParams, fuzzy bool, codeFilter string, code interface{}) ([]model.AttributeFacet, error) {
+	_, _, predicate, args := searchClauses(params.Search, fuzzy)
+	filters, args := buildProductFilters(params, args)
+
+	query := fmt.Sprintf(`
+		SELECT d.code, MIN(d.name), v.value_text, COUNT(DISTINCT p.id)
+		FROM products p
+		JOIN product_attribute_values v ON v.product_id = p.id
+		JOIN attribute_definitions d ON d.id = v.attribute_id
+		WHERE d.type IN ('enum', 'boolean') AND %s%s%s
+		GROUP BY d.code, v.value_text
+		ORDER BY d.code ASC, COUNT(DISTINCT p.id) DESC, v.value_text ASC
+	`, fmt.Sprintf(codeFilter, len(args)+1), predicate, filters)
+	args = append(args, code)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attribute facet: %w", err)
+	}
+	defer rows.Close()
+
+	facets := []model.AttributeFacet{}
+	for rows.Next() {
+		var code, name string
+		var count model.FacetCount
+		if err := rows.Scan(&code, &name, &count.Value, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan attribute facet: %w", err)
+		}
+		count.Label = count.Value
+
+		if len(facets) == 0 || facets[len(facets)-1].Code != code {
+			facets = append(facets, model.AttributeFacet{Code: code, Name: name})
+		}
+		last := &facets[len(facets)-1]
+		last.Values = append(last.Values, count)
+	}
+
+	return facets, nil
+}
+
+func (r *productRepository) categoryFacet(params model.ProductQueryParams, fuzzy bool) ([]model.FacetCount, error) {
+	_, _, predicate, args := searchClauses(params.Search, fuzzy)
+	filters, args := buildProductFilters(params, args)
+
+	query := fmt.Sprintf(`
+		SELECT c.id, c.name, COUNT(*)
+		FROM products p
+		JOIN categories c ON p.category_id = c.id
+		WHERE 1=1%s%s
+		GROUP BY c.id, c.name
+		ORDER BY COUNT(*) DESC, c.name ASC
+	`, predicate, filters)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category facet: %w", err)
+	}
+	defer rows.Close()
+
+	facet := []model.FacetCount{}
+	for rows.Next() {
+		var count model.FacetCount
+		if err := rows.Scan(&count.Value, &count.Label, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan category facet: %w", err)
+		}
+		facet = append(facet, count)
+	}
+
+	return facet, nil
+}
+
+func (r *productRepository) priceRangeFacet(params model.ProductQueryParams, fuzzy bool) ([]model.PriceRangeFacet, error) {
+	_, _, predicate, args := searchClauses(params.Search, fuzzy)
+	filters, args := buildProductFilters(params, args)
+
+	query := fmt.Sprintf(`
+		SELECT width_bucket(%s, $%d::numeric[]) AS bucket, COUNT(*)
+		FROM products p
+		WHERE 1=1%s%s
+		GROUP BY bucket
+	`, indexedPrice, len(args)+1, predicate, filters)
+	args = append(args, pq.Array(priceBucketBounds))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price facet: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan price facet: %w", err)
+		}
+		counts[bucket] = count
+	}
+
+	facet := make([]model.PriceRangeFacet, 0, len(priceBucketBounds))
+	for i, lower := range priceBucketBounds {
+		bucket := model.PriceRangeFacet{Min: lower, Count: counts[i+1]}
+		if i+1 < len(priceBucketBounds) {
+			upper := priceBucketBounds[i+1]
+			bucket.Max = &upper
+		}
+		facet = append(facet, bucket)
+	}
+
+	return facet, nil
+}
+
+func (r *productRepository) availabilityFacet(params model.ProductQueryParams, fuzzy bool) ([]model.FacetCount, error) {
+	_, _, predicate, args := searchClauses(params.Search, fuzzy)
+	filters, args := buildProductFilters(params, args)
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) FILTER (WHERE `+availableStock+` > 0), COUNT(*) FILTER (WHERE `+availableStock+` <= 0)
+		FROM products p
+		WHERE 1=1%s%s
+	`, predicate, filters)
+
+	var inStock, outOfStock int
+	if err := r.db.QueryRow(query, args...).Scan(&inStock, &outOfStock); err != nil {
+		return nil, fmt.Errorf("failed to get availability facet: %w", err)
+	}
+
+	return []model.FacetCount{
+		{Value: "in_stock", Label: "In stock", Count: inStock},
+		{Value: "out_of_stock", Label: "Out of stock", Count: outOfStock},
+	}, nil
+}
+
+// searchClauses returns the rank and snippet expressions and the WHERE
+// predicate for a text query, binding the query itself as $1.
+func searchClauses(search string, fuzzy bool) (string, string, string, []interface{}) {
+	if search == "" {
+		return "0", "''", "", nil
+	}
+
+	args := []interface{}{search}
+	if fuzzy {
+		return "word_similarity($1, p.name)", "p.name", " AND $1 <% p.name", args
+	}
+
+	return "ts_rank(p.search_vector, websearch_to_tsquery('english', $1))",
+		`ts_headline('english', COALESCE(NULLIF(p.description, ''), p.name), websearch_to_tsquery('english', $1),
+		             'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15')`,
+		" AND p.search_vector @@ websearch_to_tsquery('english', $1)",
+		args
+}
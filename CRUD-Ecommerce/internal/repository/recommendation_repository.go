@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+)
+
+type RecommendationRepository interface {
+	Recompute() (int, error)
+	GetRelated(productID uuid.UUID, limit int) ([]model.RelatedProduct, error)
+}
+
+type recommendationRepository struct {
+	db *sql.DB
+}
+
+func NewRecommendationRepository(db *sql.DB) RecommendationRepository {
+	return &recommendationRepository{db: db}
+}
+
+// Recompute rebuilds the co-purchase scores from delivered orders and
+// returns the number of product pairs stored. Readers keep seeing the old
+// scores until the transaction commits. An advisory lock held for the
+// transaction keeps runs from overlapping, across every API instance.
+func (r *recommendationRepository) Recompute() (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err = tx.QueryRow(`SELECT pg_try_advisory_xact_lock(hashtext('product_recommendations'))`).Scan(&locked); err != nil {
+		return 0, fmt.Errorf("failed to lock recommendations: %w", err)
+	}
+	if !locked {
+		return 0, fmt.Errorf("recommendations are already being computed")
+	}
+
+	if _, err = tx.Exec(`DELETE FROM product_recommendations`); err != nil {
+		return 0, fmt.Errorf("failed to clear recommendations: %w", err)
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO product_recommendations (product_id, related_product_id, score, computed_at)
+		SELECT a.product_id, b.product_id, COUNT(DISTINCT a.order_id), $1
+		FROM order_items a
+		JOIN order_items b ON b.order_id = a.order_id AND b.product_id <> a.product_id
+		JOIN orders o ON o.id = a.order_id
+		WHERE o.status = $2
+		GROUP BY a.product_id, b.product_id
+	`, time.Now(), model.OrderStatusDelivered)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute recommendations: %w", err)
+	}
+
+	pairs, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return int(pairs), nil
+}
+
+// GetRelated returns the active products most often bought with the given
+// one. When there are fewer than limit, the rest are filled with the best
+// rated products from the same category.
+func (r *recommendationRepository) GetRelated(productID uuid.UUID, limit int) ([]model.RelatedProduct, error) {
+	query := `
+		WITH related AS (
+			SELECT pr.related_product_id AS id, pr.score::float8 AS score, $3::text AS source, 0 AS tier
+			FROM product_recommendations pr
+			WHERE pr.product_id = $1
+			UNION ALL
+			SELECT p.id, 0, $4::text, 1
+			FROM products p
+			JOIN products src ON src.id = $1
+			WHERE p.category_id = src.category_id AND p.id <> $1
+			  AND NOT EXISTS (
+			      SELECT 1 FROM product_recommendations pr
+			      WHERE pr.product_id = $1 AND pr.related_product_id = p.id
+			  )
+		)
+		SELECT ` + productColumns + `, related.score, related.source
+		FROM related
+		JOIN products p ON p.id = related.id
+		WHERE p.status = $5
+		ORDER BY related.tier ASC, related.score DESC, p.average_rating DESC, p.review_count DESC, p.id ASC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(
+		query,
+		productID,
+		limit,
+		model.RecommendationSourceCoPurchase,
+		model.RecommendationSourceCategory,
+		model.ProductStatusActive,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get related products: %w", err)
+	}
+	defer rows.Close()
+
+	related := []model.RelatedProduct{}
+	for rows.Next() {
+		var product model.RelatedProduct
+		err := rows.Scan(append(productFields(&product.Product), &product.Score, &product.Source)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan related product: %w", err)
+		}
+		related = append(related, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get related products: %w", err)
+	}
+
+	return related, nil
+}
@@ -1,19 +1,69 @@
 package repository
 
-import "database/sql"
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// SQLSTATE codes of the constraint violations repositories translate into
+// domain errors.
+const (
+	foreignKeyViolation pq.ErrorCode = "23503"
+	uniqueViolation     pq.ErrorCode = "23505"
+)
 
 type Repositories struct {
-	User     UserRepository
-	Product  ProductRepository
-	Category CategoryRepository
-	Order    OrderRepository
+	User           UserRepository
+	Product        ProductRepository
+	Category       CategoryRepository
+	Order          OrderRepository
+	Review         ReviewRepository
+	ImportJob      ImportJobRepository
+	Inventory      InventoryRepository
+	Warehouse      WarehouseRepository
+	StockAlert     StockAlertRepository
+	Notification   NotificationRepository
+	Price          PriceRepository
+	Attribute      AttributeRepository
+	Recommendation RecommendationRepository
+	Wishlist       WishlistRepository
+	Bundle         BundleRepository
+	Session        SessionRepository
+	UserToken      UserTokenRepository
+	MFA            MFARepository
+	LoginAttempt   LoginAttemptRepository
+	Role           RoleRepository
 }
 
 func NewRepositories(db *sql.DB) *Repositories {
 	return &Repositories{
-		User:     NewUserRepository(db),
-		Product:  NewProductRepository(db),
-		Category: NewCategoryRepository(db),
-		Order:    NewOrderRepository(db),
+		User:           NewUserRepository(db),
+		Product:        NewProductRepository(db),
+		Category:       NewCategoryRepository(db),
+		Order:          NewOrderRepository(db),
+		Review:         NewReviewRepository(db),
+		ImportJob:      NewImportJobRepository(db),
+		Inventory:      NewInventoryRepository(db),
+		Warehouse:      NewWarehouseRepository(db),
+		StockAlert:     NewStockAlertRepository(db),
+		Notification:   NewNotificationRepository(db),
+		Price:          NewPriceRepository(db),
+		Attribute:      NewAttributeRepository(db),
+		Recommendation: NewRecommendationRepository(db),
+		Wishlist:       NewWishlistRepository(db),
+		Bundle:         NewBundleRepository(db),
+		Session:        NewSessionRepository(db),
+		UserToken:      NewUserTokenRepository(db),
+		MFA:            NewMFARepository(db),
+		LoginAttempt:   NewLoginAttemptRepository(db),
+		Role:           NewRoleRepository(db),
 	}
 }
+
+// isViolation reports whether err is a violation of the named constraint.
+func isViolation(err error, code pq.ErrorCode, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code && pqErr.Constraint == constraint
+}
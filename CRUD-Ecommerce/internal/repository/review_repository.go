@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+)
+
+type ReviewRepository interface {
+	Create(review *model.Review) error
+	GetByID(id uuid.UUID) (*model.Review, error)
+	GetByProduct(productID uuid.UUID, status model.ReviewStatus) ([]model.Review, error)
+	GetByStatus(status model.ReviewStatus) ([]model.Review, error)
+	UpdateStatus(id uuid.UUID, status model.ReviewStatus) error
+	Exists(productID, userID uuid.UUID) (bool, error)
+	HasDeliveredPurchase(productID, userID uuid.UUID) (bool, error)
+}
+
+type reviewRepository struct {
+	db *sql.DB
+}
+
+func NewReviewRepository(db *sql.DB) ReviewRepository {
+	return &reviewRepository{db: db}
+}
+
+// Create adds a review and recomputes the product's rating aggregates in the
+// same transaction, under a lock on the product.
+func (r *reviewRepository) Create(review *model.Review) error {
+	query := `
+		INSERT INTO reviews (id, product_id, user_id, rating, title, body, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at
+	`
+
+	review.ID = uuid.New()
+	review.CreatedAt = time.Now()
+	review.UpdatedAt = time.Now()
+
+	if review.Status == "" {
+		review.Status = model.ReviewStatusPending
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockRatedProduct(tx, review.ProductID); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		query,
+		review.ID,
+		review.ProductID,
+		review.UserID,
+		review.Rating,
+		review.Title,
+		review.Body,
+		review.Status,
+		review.CreatedAt,
+		review.UpdatedAt,
+	).Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create review: %w", err)
+	}
+
+	if err := updateProductRating(tx, review.ProductID); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *reviewRepository) GetByID(id uuid.UUID) (*model.Review, error) {
+	query := `
+		SELECT r.id, r.product_id, r.user_id, u.first_name, r.rating, r.title, r.body, r.status,
+		       r.created_at, r.updated_at
+		FROM reviews r
+		JOIN users u ON r.user_id = u.id
+		WHERE r.id = $1
+	`
+
+	review := &model.Review{}
+	err := r.db.QueryRow(query, id).Scan(
+		&review.ID,
+		&review.ProductID,
+		&review.UserID,
+		&review.AuthorName,
+		&review.Rating,
+		&review.Title,
+		&review.Body,
+		&review.Status,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("review not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review: %w", err)
+	}
+
+	return review, nil
+}
+
+func (r *reviewRepository) GetByProduct(productID uuid.UUID, status model.ReviewStatus) ([]model.Review, error) {
+	query := `
+		SELECT r.id, r.product_id, r.user_id, u.first_name, r.rating, r.title, r.body, r.status,
+		       r.created_at, r.updated_at
+		FROM reviews r
+		JOIN users u ON r.user_id = u.id
+		WHERE r.product_id = $1 AND r.status = $2
+		ORDER BY r.created_at DESC
+	`
+
+	return r.queryReviews(query, productID, status)
+}
+
+func (r *reviewRepository) GetByStatus(status model.ReviewStatus) ([]model.Review, error) {
+	query := `
+		SELECT r.id, r.product_id, r.user_id, u.first_name, r.rating, r.title, r.body, r.status,
+		       r.created_at, r.updated_at
+		FROM reviews r
+		JOIN users u ON r.user_id = u.id
+		WHERE r.status = $1
+		ORDER BY r.created_at ASC
+	`
+
+	return r.queryReviews(query, status)
+}
+
+func (r *reviewRepository) queryReviews(query string, args ...interface{}) ([]model.Review, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviews: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := []model.Review{}
+	for rows.Next() {
+		var review model.Review
+		err := rows.Scan(
+			&review.ID,
+			&review.ProductID,
+			&review.UserID,
+			&review.AuthorName,
+			&review.Rating,
+			&review.Title,
+			&review.Body,
+			&review.Status,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan review: %w", err)
+		}
+		reviews = append(reviews, review)
+	}
+
+	return reviews, nil
+}
+
+// UpdateStatus moderates a review and recomputes the product's rating
+// aggregates from its approved reviews in the same transaction.
+func (r *reviewRepository) UpdateStatus(id uuid.UUID, status model.ReviewStatus) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var productID uuid.UUID
+	err = tx.QueryRow(`SELECT product_id FROM reviews WHERE id = $1`, id).Scan(&productID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("review not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get review: %w", err)
+	}
+
+	if err := lockRatedProduct(tx, productID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(
+		`UPDATE reviews SET status = $1, updated_at = $2 WHERE id = $3`,
+		status, time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update review status: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("review not found")
+	}
+
+	if err := updateProductRating(tx, productID); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// lockRatedProduct locks the product whose reviews are about to change, so
+// that concurrent changes recompute its aggregates one after another and
+// each sees the others' reviews.
+func lockRatedProduct(tx *sql.Tx, productID uuid.UUID) error {
+	var found int
+	err := tx.QueryRow(`SELECT 1 FROM products WHERE id = $1 FOR UPDATE`, productID).Scan(&found)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("product not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock product: %w", err)
+	}
+
+	return nil
+}
+
+// updateProductRating recomputes a product's rating aggregates from its
+// approved reviews.
+func updateProductRating(tx *sql.Tx, productID uuid.UUID) error {
+	query := `
+		UPDATE products
+		SET average_rating = COALESCE(stats.average, 0), review_count = stats.count
+		FROM (
+			SELECT ROUND(AVG(rating), 2) AS average, COUNT(*) AS count
+			FROM reviews
+			WHERE product_id = $1 AND status = 'approved'
+		) stats
+		WHERE products.id = $1
+	`
+
+	if _, err := tx.Exec(query, productID); err != nil {
+		return fmt.Errorf("failed to update product rating: %w", err)
+	}
+
+	return nil
+}
+
+func (r *reviewRepository) Exists(productID, userID uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM reviews WHERE product_id = $1 AND user_id = $2)`
+
+	var exists bool
+	if err := r.db.QueryRow(query, productID, userID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check review: %w", err)
+	}
+
+	return exists, nil
+}
+
+func (r *reviewRepository) HasDeliveredPurchase(productID, userID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1
+			FROM orders o
+			JOIN order_items oi ON oi.order_id = o.id
+			WHERE o.user_id = $1 AND oi.product_id = $2 AND o.status = $3
+		)
+	`
+
+	var exists bool
+	if err := r.db.QueryRow(query, userID, productID, model.OrderStatusDelivered).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check purchase: %w", err)
+	}
+
+	return exists, nil
+}
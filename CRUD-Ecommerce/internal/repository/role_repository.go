@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/lib/pq"
+)
+
+type RoleRepository interface {
+	GetAll() ([]model.Role, error)
+	GetByName(name string) (*model.Role, error)
+	GetPermissions(role string) ([]model.Permission, error)
+}
+
+type roleRepository struct {
+	db *sql.DB
+}
+
+func NewRoleRepository(db *sql.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+// roleQuery selects roles with their permissions, for GetAll and GetByName
+// to filter and order.
+const roleQuery = `
+	SELECT r.name, COALESCE(r.description, ''), r.created_at,
+	       COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+	FROM roles r
+	LEFT JOIN role_permissions rp ON rp.role = r.name
+`
+
+func (r *roleRepository) GetAll() ([]model.Role, error) {
+	rows, err := r.db.Query(roleQuery + ` GROUP BY r.name ORDER BY r.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []model.Role{}
+	for rows.Next() {
+		var role model.Role
+		var permissions []string
+		err := rows.Scan(&role.Name, &role.Description, &role.CreatedAt, pq.Array(&permissions))
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		role.Permissions = toPermissions(permissions)
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+func (r *roleRepository) GetByName(name string) (*model.Role, error) {
+	role := &model.Role{}
+	var permissions []string
+	err := r.db.QueryRow(roleQuery+` WHERE r.name = $1 GROUP BY r.name`, name).Scan(
+		&role.Name, &role.Description, &role.CreatedAt, pq.Array(&permissions),
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("role not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	role.Permissions = toPermissions(permissions)
+
+	return role, nil
+}
+
+// GetPermissions returns the permissions the role grants; none for an
+// unknown role.
+func (r *roleRepository) GetPermissions(role string) ([]model.Permission, error) {
+	rows, err := r.db.Query(`SELECT permission FROM role_permissions WHERE role = $1 ORDER BY permission`, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions := []model.Permission{}
+	for rows.Next() {
+		var permission model.Permission
+		if err := rows.Scan(&permission); err != nil {
+			return nil, fmt.Errorf("failed to scan permission: %w", err)
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, nil
+}
+
+func toPermissions(names []string) []model.Permission {
+	permissions := make([]model.Permission, len(names))
+	for i, name := range names {
+		permissions[i] = model.Permission(name)
+	}
+	return permissions
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+)
+
+type SessionRepository interface {
+	Create(session *model.Session, tokenHash string, expiresAt time.Time) error
+	Rotate(tokenHash, newTokenHash string, expiresAt time.Time) (*model.Session, error)
+	RevokeByToken(tokenHash string) error
+	RevokeAllForUser(userID uuid.UUID) error
+	MarkMFA(id uuid.UUID) error
+	IsActive(id uuid.UUID) (bool, error)
+}
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// Create starts a session together with its first refresh token.
+func (r *sessionRepository) Create(session *model.Session, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	session.ID = uuid.New()
+	session.CreatedAt = time.Now()
+	session.LastUsedAt = session.CreatedAt
+
+	_, err = tx.Exec(
+		`INSERT INTO sessions (id, user_id, user_agent, ip_address, mfa, created_at, last_used_at) VALUES ($1, $2, $3, $4, $5, $6, $6)`,
+		session.ID, session.UserID, session.UserAgent, session.IPAddress, session.MFA, session.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO refresh_tokens (token_hash, session_id, created_at, expires_at) VALUES ($1, $2, $3, $4)`,
+		tokenHash, session.ID, session.CreatedAt, expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Rotate exchanges a refresh token for newTokenHash within the same
+// session. A token can be exchanged once: presenting one that was already
+// used means it has leaked, so the whole session is revoked and an error
+// returned.
+func (r *sessionRepository) Rotate(tokenHash, newTokenHash string, expiresAt time.Time) (*model.Session, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT s.id, s.user_id, s.user_agent, s.ip_address, s.mfa, s.created_at, s.last_used_at, s.revoked_at,
+		       t.used_at, t.expires_at
+		FROM refresh_tokens t
+		JOIN sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1
+		FOR UPDATE
+	`
+
+	session := &model.Session{}
+	var usedAt sql.NullTime
+	var tokenExpiresAt time.Time
+	err = tx.QueryRow(query, tokenHash).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.MFA,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.RevokedAt,
+		&usedAt,
+		&tokenExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh session: %w", err)
+	}
+
+	if session.RevokedAt != nil {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+
+	now := time.Now()
+	if usedAt.Valid {
+		if _, err := tx.Exec(`UPDATE sessions SET revoked_at = $1 WHERE id = $2`, now, session.ID); err != nil {
+			return nil, fmt.Errorf("failed to revoke session: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil, fmt.Errorf("refresh token has already been used; the session has been revoked")
+	}
+
+	if now.After(tokenExpiresAt) {
+		return nil, fmt.Errorf("refresh token has expired")
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET used_at = $1 WHERE token_hash = $2`, now, tokenHash); err != nil {
+		return nil, fmt.Errorf("failed to refresh session: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO refresh_tokens (token_hash, session_id, created_at, expires_at) VALUES ($1, $2, $3, $4)`,
+		newTokenHash, session.ID, now, expiresAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE sessions SET last_used_at = $1 WHERE id = $2`, now, session.ID); err != nil {
+		return nil, fmt.Errorf("failed to refresh session: %w", err)
+	}
+	session.LastUsedAt = now
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return session, nil
+}
+
+// RevokeByToken revokes the session a refresh token belongs to.
+func (r *sessionRepository) RevokeByToken(tokenHash string) error {
+	query := `
+		UPDATE sessions SET revoked_at = COALESCE(revoked_at, $1)
+		WHERE id = (SELECT session_id FROM refresh_tokens WHERE token_hash = $2)
+	`
+
+	result, err := r.db.Exec(query, time.Now(), tokenHash)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("invalid refresh token")
+	}
+
+	return nil
+}
+
+// RevokeAllForUser signs the user out everywhere.
+func (r *sessionRepository) RevokeAllForUser(userID uuid.UUID) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := revokeUserSessions(tx, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func revokeUserSessions(tx *sql.Tx, userID uuid.UUID) error {
+	_, err := tx.Exec(
+		`UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`,
+		time.Now(), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return nil
+}
+
+// MarkMFA records that the session has been confirmed with a second
+// factor, so tokens refreshed from it carry the mfa claim.
+func (r *sessionRepository) MarkMFA(id uuid.UUID) error {
+	if _, err := r.db.Exec(`UPDATE sessions SET mfa = TRUE WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return nil
+}
+
+// IsActive reports whether the session exists and has not been revoked.
+func (r *sessionRepository) IsActive(id uuid.UUID) (bool, error) {
+	var active bool
+	err := r.db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND revoked_at IS NULL)`, id,
+	).Scan(&active)
+	if err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+
+	return active, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+)
+
+// Entities whose retired slugs are kept in slug_redirects.
+const (
+	slugEntityProduct  = "product"
+	slugEntityCategory = "category"
+)
+
+// Unique indexes on the slug columns, from migration 014.
+const (
+	productSlugIndex  = "idx_products_slug"
+	categorySlugIndex = "idx_categories_slug"
+)
+
+// slugTaken reports whether slug is used by another row of table, either as
+// its current slug or as a redirect to it. Redirects left behind by deleted
+// rows are ignored.
+func slugTaken(db *sql.DB, table, entity, slug string, excludeID uuid.UUID) (bool, error) {
+	query := fmt.Sprintf(`
+		SELECT EXISTS (SELECT 1 FROM %s WHERE slug = $1 AND id <> $2)
+		    OR EXISTS (
+				SELECT 1 FROM slug_redirects r
+				JOIN %s t ON t.id = r.target_id
+				WHERE r.entity = $3 AND r.slug = $1 AND r.target_id <> $2
+			)
+	`, table, table)
+
+	var taken bool
+	if err := db.QueryRow(query, slug, excludeID, entity).Scan(&taken); err != nil {
+		return false, fmt.Errorf("failed to check slug: %w", err)
+	}
+
+	return taken, nil
+}
+
+// slugRedirect returns the current slug of the row a retired slug points to.
+func slugRedirect(db *sql.DB, table, entity, slug string) (string, error) {
+	query := fmt.Sprintf(`
+		SELECT t.slug
+		FROM slug_redirects r
+		JOIN %s t ON t.id = r.target_id
+		WHERE r.entity = $1 AND r.slug = $2
+	`, table)
+
+	var current string
+	err := db.QueryRow(query, entity, slug).Scan(&current)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%s not found", entity)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get slug redirect: %w", err)
+	}
+
+	return current, nil
+}
+
+// moveSlug keeps oldSlug as a redirect to id after the row has moved to
+// newSlug. Redirects always point at the row rather than the next slug, so
+// renaming several times never builds chains. A row taking back one of its
+// retired slugs drops that redirect.
+func moveSlug(tx *sql.Tx, entity string, id uuid.UUID, oldSlug, newSlug string) error {
+	if oldSlug == newSlug {
+		return nil
+	}
+
+	if _, err := tx.Exec(`DELETE FROM slug_redirects WHERE entity = $1 AND slug = $2`, entity, newSlug); err != nil {
+		return fmt.Errorf("failed to update slug redirects: %w", err)
+	}
+
+	if oldSlug == "" {
+		return nil
+	}
+
+	query := `
+		INSERT INTO slug_redirects (entity, slug, target_id, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (entity, slug) DO UPDATE SET target_id = EXCLUDED.target_id, created_at = EXCLUDED.created_at
+	`
+
+	if _, err := tx.Exec(query, entity, oldSlug, id, time.Now()); err != nil {
+		return fmt.Errorf("failed to record slug redirect: %w", err)
+	}
+
+	return nil
+}
+
+// slugConflict reports a unique violation on a slug index as
+// model.ErrSlugTaken, so the caller can pick another slug. Other errors are
+// wrapped with action.
+func slugConflict(err error, index, action string) error {
+	if isViolation(err, uniqueViolation, index) {
+		return model.ErrSlugTaken
+	}
+	return fmt.Errorf("failed to %s: %w", action, err)
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+)
+
+type StockAlertRepository interface {
+	GetAll(status model.StockAlertStatus) ([]model.StockAlert, error)
+	Acknowledge(id uuid.UUID) (*model.StockAlert, error)
+}
+
+type stockAlertRepository struct {
+	db *sql.DB
+}
+
+func NewStockAlertRepository(db *sql.DB) StockAlertRepository {
+	return &stockAlertRepository{db: db}
+}
+
+// GetAll lists alerts newest first; an empty status lists every alert.
+func (r *stockAlertRepository) GetAll(status model.StockAlertStatus) ([]model.StockAlert, error) {
+	query := `
+		SELECT a.id, a.product_id, p.name, a.stock, a.threshold, a.status, a.created_at, a.acknowledged_at
+		FROM stock_alerts a
+		JOIN products p ON p.id = a.product_id
+		WHERE $1::text = '' OR a.status = $1::text
+		ORDER BY a.created_at DESC
+	`
+
+	rows, err := r.db.Query(query, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []model.StockAlert{}
+	for rows.Next() {
+		var alert model.StockAlert
+		var acknowledgedAt sql.NullTime
+		err := rows.Scan(
+			&alert.ID,
+			&alert.ProductID,
+			&alert.ProductName,
+			&alert.Stock,
+			&alert.Threshold,
+			&alert.Status,
+			&alert.CreatedAt,
+			&acknowledgedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stock alert: %w", err)
+		}
+		if acknowledgedAt.Valid {
+			alert.AcknowledgedAt = &acknowledgedAt.Time
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, nil
+}
+
+func (r *stockAlertRepository) Acknowledge(id uuid.UUID) (*model.StockAlert, error) {
+	query := `
+		UPDATE stock_alerts a
+		SET status = $1, acknowledged_at = $2
+		FROM products p
+		WHERE a.id = $3 AND a.status = $4 AND p.id = a.product_id
+		RETURNING a.id, a.product_id, p.name, a.stock, a.threshold, a.status, a.created_at, a.acknowledged_at
+	`
+
+	alert := &model.StockAlert{}
+	var acknowledgedAt time.Time
+	err := r.db.QueryRow(
+		query,
+		model.StockAlertStatusAcknowledged,
+		time.Now(),
+		id,
+		model.StockAlertStatusOpen,
+	).Scan(
+		&alert.ID,
+		&alert.ProductID,
+		&alert.ProductName,
+		&alert.Stock,
+		&alert.Threshold,
+		&alert.Status,
+		&alert.CreatedAt,
+		&acknowledgedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("open stock alert not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acknowledge stock alert: %w", err)
+	}
+
+	alert.AcknowledgedAt = &acknowledgedAt
+	return alert, nil
+}
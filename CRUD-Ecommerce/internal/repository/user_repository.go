@@ -14,9 +14,41 @@ type UserRepository interface {
 	GetByID(id uuid.UUID) (*model.User, error)
 	GetByEmail(email string) (*model.User, error)
 	Update(user *model.User) error
+	MarkEmailVerified(id uuid.UUID) error
+	UpdatePassword(id uuid.UUID, passwordHash string) error
+	ResetPassword(tokenHash string, passwordHash string) error
+	UpdateRole(id uuid.UUID, role string) error
+	RecordFailedLogin(id uuid.UUID, window time.Duration) (int, error)
+	LockUntil(id uuid.UUID, until time.Time) error
+	ClearFailedLogins(id uuid.UUID) error
+	GetLocked() ([]model.User, error)
 	Delete(id uuid.UUID) error
 }
 
+// userColumns lists the users columns read by every user query, in the
+// order expected by userFields.
+const userColumns = `id, email, password, first_name, last_name, role, created_at, updated_at, email_verified_at,
+	COALESCE(mfa_secret, ''), mfa_enabled_at, failed_logins, locked_until`
+
+// userFields returns scan destinations matching userColumns.
+func userFields(user *model.User) []interface{} {
+	return []interface{}{
+		&user.ID,
+		&user.Email,
+		&user.Password,
+		&user.FirstName,
+		&user.LastName,
+		&user.Role,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.EmailVerifiedAt,
+		&user.MFASecret,
+		&user.MFAEnabledAt,
+		&user.FailedLogins,
+		&user.LockedUntil,
+	}
+}
+
 type userRepository struct {
 	db *sql.DB
 }
@@ -37,7 +69,7 @@ func (r *userRepository) Create(user *model.User) error {
 	user.UpdatedAt = time.Now()
 
 	if user.Role == "" {
-		user.Role = "user"
+		user.Role = model.RoleUser
 	}
 
 	err := r.db.QueryRow(
@@ -61,22 +93,13 @@ func (r *userRepository) Create(user *model.User) error {
 
 func (r *userRepository) GetByID(id uuid.UUID) (*model.User, error) {
 	query := `
-		SELECT id, email, password, first_name, last_name, role, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE id = $1
 	`
 
 	user := &model.User{}
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Password,
-		&user.FirstName,
-		&user.LastName,
-		&user.Role,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	err := r.db.QueryRow(query, id).Scan(userFields(user)...)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("user not found")
@@ -90,22 +113,13 @@ func (r *userRepository) GetByID(id uuid.UUID) (*model.User, error) {
 
 func (r *userRepository) GetByEmail(email string) (*model.User, error) {
 	query := `
-		SELECT id, email, password, first_name, last_name, role, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE email = $1
 	`
 
 	user := &model.User{}
-	err := r.db.QueryRow(query, email).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Password,
-		&user.FirstName,
-		&user.LastName,
-		&user.Role,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	err := r.db.QueryRow(query, email).Scan(userFields(user)...)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("user not found")
@@ -142,6 +156,220 @@ func (r *userRepository) Update(user *model.User) error {
 	return nil
 }
 
+// MarkEmailVerified records that the user confirmed their email address.
+// Verifying twice keeps the first timestamp.
+func (r *userRepository) MarkEmailVerified(id uuid.UUID) error {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, $1), updated_at = $1 WHERE id = $2`
+
+	result, err := r.db.Exec(query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// UpdatePassword sets the user's password and, in the same transaction,
+// signs them out of every session.
+func (r *userRepository) UpdatePassword(id uuid.UUID, passwordHash string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET password = $1, updated_at = $2 WHERE id = $3`
+
+	result, err := tx.Exec(query, passwordHash, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := revokeUserSessions(tx, id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword uses up a password reset token and, in the same
+// transaction, sets the new password of the user it was issued to, signs
+// them out of every session, lifts any lockout and marks their email
+// verified, since following the emailed link proves the address.
+func (r *userRepository) ResetPassword(tokenHash string, passwordHash string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeToken(tx, tokenHash, model.UserTokenResetPassword)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE users
+		SET password = $1, failed_logins = 0, locked_until = NULL,
+		    email_verified_at = COALESCE(email_verified_at, $2), updated_at = $2
+		WHERE id = $3
+	`
+
+	result, err := tx.Exec(query, passwordHash, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := revokeUserSessions(tx, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *userRepository) UpdateRole(id uuid.UUID, role string) error {
+	query := `UPDATE users SET role = $1, updated_at = $2 WHERE id = $3`
+
+	result, err := r.db.Exec(query, role, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// RecordFailedLogin counts a failed login and returns how many there have
+// been in a row. The count starts over when the previous failure is older
+// than window.
+func (r *userRepository) RecordFailedLogin(id uuid.UUID, window time.Duration) (int, error) {
+	query := `
+		UPDATE users SET
+			failed_logins = CASE
+				WHEN last_failed_login_at IS NULL OR last_failed_login_at < $2 THEN 1
+				ELSE failed_logins + 1
+			END,
+			last_failed_login_at = $3
+		WHERE id = $1
+		RETURNING failed_logins
+	`
+
+	now := time.Now()
+	var failures int
+	err := r.db.QueryRow(query, id, now.Add(-window), now).Scan(&failures)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to record failed login: %w", err)
+	}
+
+	return failures, nil
+}
+
+// LockUntil refuses logins to the account until the given time. A lock
+// that already runs longer is kept.
+func (r *userRepository) LockUntil(id uuid.UUID, until time.Time) error {
+	query := `UPDATE users SET locked_until = GREATEST(COALESCE(locked_until, $1), $1) WHERE id = $2`
+
+	if _, err := r.db.Exec(query, until, id); err != nil {
+		return fmt.Errorf("failed to lock account: %w", err)
+	}
+
+	return nil
+}
+
+// ClearFailedLogins resets the failed login count and lifts any lock.
+func (r *userRepository) ClearFailedLogins(id uuid.UUID) error {
+	query := `UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = $1`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to unlock account: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// GetLocked returns the accounts that are currently locked out.
+func (r *userRepository) GetLocked() ([]model.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE locked_until > $1
+		ORDER BY locked_until DESC
+	`
+
+	rows, err := r.db.Query(query, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get locked accounts: %w", err)
+	}
+	defer rows.Close()
+
+	users := []model.User{}
+	for rows.Next() {
+		var user model.User
+		if err := rows.Scan(userFields(&user)...); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
 func (r *userRepository) Delete(id uuid.UUID) error {
 	query := `DELETE FROM users WHERE id = $1`
 
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+)
+
+type UserTokenRepository interface {
+	Create(userID uuid.UUID, purpose model.UserTokenPurpose, tokenHash string, expiresAt time.Time, cooldown time.Duration) error
+	Find(tokenHash string, purpose model.UserTokenPurpose) (uuid.UUID, error)
+	Consume(tokenHash string, purpose model.UserTokenPurpose) (uuid.UUID, error)
+	RecordFailure(tokenHash string, purpose model.UserTokenPurpose, maxAttempts int) error
+}
+
+type userTokenRepository struct {
+	db *sql.DB
+}
+
+func NewUserTokenRepository(db *sql.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+// Create stores a new token for the user. Earlier unused tokens with the
+// same purpose stop working, so only the most recent email's link is valid.
+// If a token with the same purpose was issued less than cooldown ago,
+// nothing is stored and model.ErrTokenRecentlyIssued is returned; requests
+// for the same user and purpose are serialised so that the check holds.
+func (r *userTokenRepository) Create(userID uuid.UUID, purpose model.UserTokenPurpose, tokenHash string, expiresAt time.Time, cooldown time.Duration) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if cooldown > 0 {
+		_, err = tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1::text || ':' || $2::text))`, userID, purpose)
+		if err != nil {
+			return fmt.Errorf("failed to lock tokens: %w", err)
+		}
+
+		var recent bool
+		err = tx.QueryRow(
+			`SELECT EXISTS (SELECT 1 FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND created_at > $3)`,
+			userID, purpose, time.Now().Add(-cooldown),
+		).Scan(&recent)
+		if err != nil {
+			return fmt.Errorf("failed to check recent tokens: %w", err)
+		}
+		if recent {
+			return model.ErrTokenRecentlyIssued
+		}
+	}
+
+	_, err = tx.Exec(
+		`DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		userID, purpose,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to replace token: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO user_tokens (token_hash, user_id, purpose, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)`,
+		tokenHash, userID, purpose, time.Now(), expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Find returns the user an unused, unexpired token was issued to, without
+// using it up.
+func (r *userTokenRepository) Find(tokenHash string, purpose model.UserTokenPurpose) (uuid.UUID, error) {
+	query := `
+		SELECT user_id FROM user_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+	`
+
+	var userID uuid.UUID
+	err := r.db.QueryRow(query, tokenHash, purpose, time.Now()).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, fmt.Errorf("invalid or expired token")
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	return userID, nil
+}
+
+// RecordFailure counts a wrong answer given with the token. On the
+// maxAttempts-th failure the token is used up.
+func (r *userTokenRepository) RecordFailure(tokenHash string, purpose model.UserTokenPurpose, maxAttempts int) error {
+	query := `
+		UPDATE user_tokens
+		SET attempts = attempts + 1,
+		    used_at = CASE WHEN attempts + 1 >= $1 THEN $2 ELSE used_at END
+		WHERE token_hash = $3 AND purpose = $4 AND used_at IS NULL
+	`
+
+	if _, err := r.db.Exec(query, maxAttempts, time.Now(), tokenHash, purpose); err != nil {
+		return fmt.Errorf("failed to record attempt: %w", err)
+	}
+
+	return nil
+}
+
+// Consume marks an unused, unexpired token as used and returns the user it
+// was issued to.
+func (r *userTokenRepository) Consume(tokenHash string, purpose model.UserTokenPurpose) (uuid.UUID, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeToken(tx, tokenHash, purpose)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return userID, nil
+}
+
+func consumeToken(tx *sql.Tx, tokenHash string, purpose model.UserTokenPurpose) (uuid.UUID, error) {
+	query := `
+		UPDATE user_tokens SET used_at = $1
+		WHERE token_hash = $2 AND purpose = $3 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id
+	`
+
+	var userID uuid.UUID
+	err := tx.QueryRow(query, time.Now(), tokenHash, purpose).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, fmt.Errorf("invalid or expired token")
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to use token: %w", err)
+	}
+
+	return userID, nil
+}
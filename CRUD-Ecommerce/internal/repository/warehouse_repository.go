@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+)
+
+type WarehouseRepository interface {
+	Create(warehouse *model.Warehouse) error
+	GetByID(id uuid.UUID) (*model.Warehouse, error)
+	GetAll() ([]model.Warehouse, error)
+	Update(warehouse *model.Warehouse) error
+	GetStockLevels(productID uuid.UUID) ([]model.WarehouseStock, error)
+}
+
+type warehouseRepository struct {
+	db *sql.DB
+}
+
+func NewWarehouseRepository(db *sql.DB) WarehouseRepository {
+	return &warehouseRepository{db: db}
+}
+
+func (r *warehouseRepository) Create(warehouse *model.Warehouse) error {
+	query := `
+		INSERT INTO warehouses (id, code, name, region, priority, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, is_default, created_at, updated_at
+	`
+
+	warehouse.ID = uuid.New()
+	warehouse.CreatedAt = time.Now()
+	warehouse.UpdatedAt = time.Now()
+
+	err := r.db.QueryRow(
+		query,
+		warehouse.ID,
+		warehouse.Code,
+		warehouse.Name,
+		warehouse.Region,
+		warehouse.Priority,
+		warehouse.CreatedAt,
+		warehouse.UpdatedAt,
+	).Scan(&warehouse.ID, &warehouse.IsDefault, &warehouse.CreatedAt, &warehouse.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create warehouse: %w", err)
+	}
+
+	return nil
+}
+
+func (r *warehouseRepository) GetByID(id uuid.UUID) (*model.Warehouse, error) {
+	query := `
+		SELECT id, code, name, region, priority, is_default, created_at, updated_at
+		FROM warehouses
+		WHERE id = $1
+	`
+
+	warehouse := &model.Warehouse{}
+	err := r.db.QueryRow(query, id).Scan(
+		&warehouse.ID,
+		&warehouse.Code,
+		&warehouse.Name,
+		&warehouse.Region,
+		&warehouse.Priority,
+		&warehouse.IsDefault,
+		&warehouse.CreatedAt,
+		&warehouse.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("warehouse not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get warehouse: %w", err)
+	}
+
+	return warehouse, nil
+}
+
+func (r *warehouseRepository) GetAll() ([]model.Warehouse, error) {
+	query := `
+		SELECT id, code, name, region, priority, is_default, created_at, updated_at
+		FROM warehouses
+		ORDER BY priority ASC, code ASC
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get warehouses: %w", err)
+	}
+	defer rows.Close()
+
+	warehouses := []model.Warehouse{}
+	for rows.Next() {
+		var warehouse model.Warehouse
+		err := rows.Scan(
+			&warehouse.ID,
+			&warehouse.Code,
+			&warehouse.Name,
+			&warehouse.Region,
+			&warehouse.Priority,
+			&warehouse.IsDefault,
+			&warehouse.CreatedAt,
+			&warehouse.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan warehouse: %w", err)
+		}
+		warehouses = append(warehouses, warehouse)
+	}
+
+	return warehouses, nil
+}
+
+func (r *warehouseRepository) Update(warehouse *model.Warehouse) error {
+	query := `
+		UPDATE warehouses
+		SET name = $1, region = $2, priority = $3, updated_at = $4
+		WHERE id = $5
+		RETURNING updated_at
+	`
+
+	warehouse.UpdatedAt = time.Now()
+
+	err := r.db.QueryRow(
+		query,
+		warehouse.Name,
+		warehouse.Region,
+		warehouse.Priority,
+		warehouse.UpdatedAt,
+		warehouse.ID,
+	).Scan(&warehouse.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to update warehouse: %w", err)
+	}
+
+	return nil
+}
+
+// GetStockLevels lists the product's stock in every warehouse, including
+// warehouses that hold none of it.
+func (r *warehouseRepository) GetStockLevels(productID uuid.UUID) ([]model.WarehouseStock, error) {
+	query := `
+		SELECT w.id, w.code, w.region, w.priority, COALESCE(ws.quantity, 0)
+		FROM warehouses w
+		LEFT JOIN warehouse_stock ws ON ws.warehouse_id = w.id AND ws.product_id = $1
+		ORDER BY w.priority ASC, w.code ASC
+	`
+
+	rows, err := r.db.Query(query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock levels: %w", err)
+	}
+	defer rows.Close()
+
+	levels := []model.WarehouseStock{}
+	for rows.Next() {
+		var level model.WarehouseStock
+		err := rows.Scan(
+			&level.WarehouseID,
+			&level.WarehouseCode,
+			&level.Region,
+			&level.Priority,
+			&level.Quantity,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stock level: %w", err)
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, nil
+}
@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type WishlistRepository interface {
+	Create(wishlist *model.Wishlist) error
+	GetByID(id uuid.UUID) (*model.Wishlist, error)
+	GetByUser(userID uuid.UUID) ([]model.Wishlist, error)
+	GetByShareToken(token string) (*model.Wishlist, error)
+	Update(wishlist *model.Wishlist) error
+	Delete(id uuid.UUID) error
+	AddItem(wishlistID, productID uuid.UUID) error
+	RemoveItems(wishlistID uuid.UUID, productIDs []uuid.UUID) (int, error)
+	NotifyPriceDrops() (int, error)
+}
+
+type wishlistRepository struct {
+	db *sql.DB
+}
+
+func NewWishlistRepository(db *sql.DB) WishlistRepository {
+	return &wishlistRepository{db: db}
+}
+
+func (r *wishlistRepository) Create(wishlist *model.Wishlist) error {
+	query := `
+		INSERT INTO wishlists (id, user_id, name, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	wishlist.ID = uuid.New()
+	wishlist.CreatedAt = time.Now()
+	wishlist.UpdatedAt = time.Now()
+	wishlist.Items = []model.WishlistItem{}
+
+	_, err := r.db.Exec(
+		query,
+		wishlist.ID,
+		wishlist.UserID,
+		wishlist.Name,
+		wishlist.CreatedAt,
+		wishlist.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create wishlist: %w", err)
+	}
+
+	return nil
+}
+
+func (r *wishlistRepository) GetByID(id uuid.UUID) (*model.Wishlist, error) {
+	return r.getOne(`WHERE id = $1`, id)
+}
+
+func (r *wishlistRepository) GetByShareToken(token string) (*model.Wishlist, error) {
+	return r.getOne(`WHERE share_token = $1`, token)
+}
+
+func (r *wishlistRepository) getOne(where string, arg interface{}) (*model.Wishlist, error) {
+	query := `
+		SELECT id, user_id, name, share_token, created_at, updated_at
+		FROM wishlists
+		` + where
+
+	wishlist := &model.Wishlist{}
+	err := r.db.QueryRow(query, arg).Scan(
+		&wishlist.ID,
+		&wishlist.UserID,
+		&wishlist.Name,
+		&wishlist.ShareToken,
+		&wishlist.CreatedAt,
+		&wishlist.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("wishlist not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wishlist: %w", err)
+	}
+
+	if wishlist.Items, err = r.getItems(wishlist.ID); err != nil {
+		return nil, err
+	}
+
+	return wishlist, nil
+}
+
+func (r *wishlistRepository) GetByUser(userID uuid.UUID) ([]model.Wishlist, error) {
+	query := `
+		SELECT id, user_id, name, share_token, created_at, updated_at
+		FROM wishlists
+		WHERE user_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wishlists: %w", err)
+	}
+	defer rows.Close()
+
+	wishlists := []model.Wishlist{}
+	for rows.Next() {
+		var wishlist model.Wishlist
+		err := rows.Scan(
+			&wishlist.ID,
+			&wishlist.UserID,
+			&wishlist.Name,
+			&wishlist.ShareToken,
+			&wishlist.CreatedAt,
+			&wishlist.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan wishlist: %w", err)
+		}
+		wishlists = append(wishlists, wishlist)
+	}
+	rows.Close()
+
+	for i := range wishlists {
+		if wishlists[i].Items, err = r.getItems(wishlists[i].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return wishlists, nil
+}
+
+func (r *wishlistRepository) getItems(wishlistID uuid.UUID) ([]model.WishlistItem, error) {
+	query := `
+		SELECT wi.product_id, wi.added_at, ` + productColumns + `
+		FROM wishlist_items wi
+		JOIN products p ON p.id = wi.product_id
+		WHERE wi.wishlist_id = $1
+		ORDER BY wi.added_at DESC
+	`
+
+	rows, err := r.db.Query(query, wishlistID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wishlist items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []model.WishlistItem{}
+	for rows.Next() {
+		item := model.WishlistItem{Product: &model.Product{}}
+		err := rows.Scan(append([]interface{}{&item.ProductID, &item.AddedAt}, productFields(item.Product)...)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan wishlist item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// Update saves the name and share token.
+func (r *wishlistRepository) Update(wishlist *model.Wishlist) error {
+	query := `
+		UPDATE wishlists
+		SET name = $1, share_token = $2, updated_at = $3
+		WHERE id = $4
+		RETURNING updated_at
+	`
+
+	wishlist.UpdatedAt = time.Now()
+
+	err := r.db.QueryRow(
+		query,
+		wishlist.Name,
+		wishlist.ShareToken,
+		wishlist.UpdatedAt,
+		wishlist.ID,
+	).Scan(&wishlist.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to update wishlist: %w", err)
+	}
+
+	return nil
+}
+
+func (r *wishlistRepository) Delete(id uuid.UUID) error {
+	query := `DELETE FROM wishlists WHERE id = $1`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete wishlist: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("wishlist not found")
+	}
+
+	return nil
+}
+
+// AddItem puts a product on the list, remembering its current effective
+// price as the baseline for price-drop notifications. Adding a product that
+// is already there is a no-op.
+func (r *wishlistRepository) AddItem(wishlistID, productID uuid.UUID) error {
+	query := `
+		INSERT INTO wishlist_items (wishlist_id, product_id, tracked_price, added_at)
+		SELECT $1::uuid, p.id, ` + effectivePrice + `, $3::timestamp
+		FROM products p
+		WHERE p.id = $2
+		ON CONFLICT (wishlist_id, product_id) DO NOTHING
+	`
+
+	_, err := r.db.Exec(query, wishlistID, productID, time.Now())
+	if err != nil {
+		if isViolation(err, foreignKeyViolation, "wishlist_items_wishlist_id_fkey") {
+			return fmt.Errorf("wishlist not found")
+		}
+		return fmt.Errorf("failed to add wishlist item: %w", err)
+	}
+
+	return nil
+}
+
+func (r *wishlistRepository) RemoveItems(wishlistID uuid.UUID, productIDs []uuid.UUID) (int, error) {
+	query := `DELETE FROM wishlist_items WHERE wishlist_id = $1 AND product_id = ANY($2)`
+
+	result, err := r.db.Exec(query, wishlistID, pq.Array(productIDs))
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove wishlist items: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return int(rows), nil
+}
+
+// removeWishlistItems takes ordered products off a wishlist as part of the
+// transaction that places the order.
+func removeWishlistItems(tx *sql.Tx, wishlistID uuid.UUID, productIDs []uuid.UUID) error {
+	_, err := tx.Exec(
+		`DELETE FROM wishlist_items WHERE wishlist_id = $1 AND product_id = ANY($2)`,
+		wishlistID, pq.Array(productIDs),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove wishlist items: %w", err)
+	}
+
+	return nil
+}
+
+// NotifyPriceDrops compares every wishlisted product's effective price with
+// the price last seen for it, notifies the owner of each drop once per
+// product, and moves the baseline to the current price. Returns the number
+// of notifications sent.
+func (r *wishlistRepository) NotifyPriceDrops() (int, error) {
+	query := `
+		WITH changed AS (
+			SELECT wi.wishlist_id, wi.product_id, w.user_id, p.name,
+			       wi.tracked_price AS old_price, ` + effectivePrice + ` AS new_price
+			FROM wishlist_items wi
+			JOIN wishlists w ON w.id = wi.wishlist_id
+			JOIN products p ON p.id = wi.product_id
+			WHERE p.status = $1
+			  AND wi.tracked_price IS DISTINCT FROM ` + effectivePrice + `
+		), tracked AS (
+			UPDATE wishlist_items wi SET tracked_price = c.new_price
+			FROM changed c
+			WHERE wi.wishlist_id = c.wishlist_id AND wi.product_id = c.product_id
+		)
+		INSERT INTO notifications (user_id, type, product_id, message, created_at)
+		SELECT DISTINCT ON (c.user_id, c.product_id)
+		       c.user_id, $2::text, c.product_id,
+		       format('%s dropped from %s to %s', c.name, c.old_price, c.new_price), $3::timestamp
+		FROM changed c
+		WHERE c.new_price < c.old_price
+		ORDER BY c.user_id, c.product_id, c.old_price DESC
+	`
+
+	result, err := r.db.Exec(query, model.ProductStatusActive, model.NotificationTypePriceDrop, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to check wishlist prices: %w", err)
+	}
+
+	sent, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return int(sent), nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
+	"github.com/google/uuid"
+)
+
+var attributeCodePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type AttributeService interface {
+	Create(categoryID uuid.UUID, req *model.AttributeDefinitionCreateRequest) (*model.AttributeDefinition, error)
+	GetByCategory(categoryID uuid.UUID) ([]model.AttributeDefinition, error)
+	Delete(categoryID, id uuid.UUID) error
+}
+
+type attributeService struct {
+	repo         repository.AttributeRepository
+	categoryRepo repository.CategoryRepository
+}
+
+func NewAttributeService(repo repository.AttributeRepository, categoryRepo repository.CategoryRepository) AttributeService {
+	return &attributeService{
+		repo:         repo,
+		categoryRepo: categoryRepo,
+	}
+}
+
+func (s *attributeService) Create(categoryID uuid.UUID, req *model.AttributeDefinitionCreateRequest) (*model.AttributeDefinition, error) {
+	if _, err := s.categoryRepo.GetByID(categoryID); err != nil {
+		return nil, err
+	}
+
+	code := strings.TrimSpace(req.Code)
+	if !attributeCodePattern.MatchString(code) {
+		return nil, fmt.Errorf("attribute code must be lowercase letters, digits and underscores")
+	}
+	if !req.Type.IsValid() {
+		return nil, fmt.Errorf("invalid attribute type: %s", req.Type)
+	}
+
+	var options []string
+	for _, option := range req.Options {
+		if option = strings.TrimSpace(option); option != "" {
+			options = append(options, option)
+		}
+	}
+	if req.Type == model.AttributeTypeEnum && len(options) == 0 {
+		return nil, fmt.Errorf("enum attributes need at least one option")
+	}
+	if req.Type != model.AttributeTypeEnum && len(options) > 0 {
+		return nil, fmt.Errorf("only enum attributes take options")
+	}
+
+	definition := &model.AttributeDefinition{
+		CategoryID: categoryID,
+		Code:       code,
+		Name:       req.Name,
+		Type:       req.Type,
+		Unit:       strings.TrimSpace(req.Unit),
+		Options:    options,
+		Required:   req.Required,
+	}
+
+	if err := s.repo.CreateDefinition(definition); err != nil {
+		return nil, err
+	}
+
+	return definition, nil
+}
+
+func (s *attributeService) GetByCategory(categoryID uuid.UUID) ([]model.AttributeDefinition, error) {
+	if _, err := s.categoryRepo.GetByID(categoryID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetDefinitions(categoryID)
+}
+
+func (s *attributeService) Delete(categoryID, id uuid.UUID) error {
+	return s.repo.DeleteDefinition(id, categoryID)
+}
+
+// resolveAttributes checks raw attribute values, keyed by code, against a
+// category's definitions. It returns the values to store and the attributes
+// set to null, which are removed. With requireAll, every required attribute
+// must be present.
+func resolveAttributes(definitions []model.AttributeDefinition, raw map[string]interface{}, requireAll bool) ([]model.AttributeValue, []uuid.UUID, error) {
+	byCode := make(map[string]model.AttributeDefinition, len(definitions))
+	for _, definition := range definitions {
+		byCode[definition.Code] = definition
+	}
+
+	var values []model.AttributeValue
+	var removed []uuid.UUID
+	var problems []string
+
+	// Visit codes in order so that the reported problems are too
+	codes := make([]string, 0, len(raw))
+	for code := range raw {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		value := raw[code]
+		definition, ok := byCode[code]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown attribute %s", code))
+			continue
+		}
+
+		if value == nil {
+			if definition.Required {
+				problems = append(problems, fmt.Sprintf("attribute %s is required", code))
+				continue
+			}
+			removed = append(removed, definition.ID)
+			continue
+		}
+
+		resolved, err := resolveAttributeValue(definition, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("attribute %s: %v", code, err))
+			continue
+		}
+		values = append(values, *resolved)
+	}
+
+	if requireAll {
+		for _, definition := range definitions {
+			if _, ok := raw[definition.Code]; definition.Required && !ok {
+				problems = append(problems, fmt.Sprintf("attribute %s is required", definition.Code))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	return values, removed, nil
+}
+
+func resolveAttributeValue(definition model.AttributeDefinition, value interface{}) (*model.AttributeValue, error) {
+	resolved := &model.AttributeValue{AttributeID: definition.ID}
+
+	switch definition.Type {
+	case model.AttributeTypeInteger, model.AttributeTypeDecimal:
+		number, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("must be a number")
+		}
+		if definition.Type == model.AttributeTypeInteger && number != float64(int64(number)) {
+			return nil, fmt.Errorf("must be a whole number")
+		}
+		resolved.Number = &number
+		resolved.Text = strconv.FormatFloat(number, 'f', -1, 64)
+
+	case model.AttributeTypeBoolean:
+		flag, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		resolved.Bool = &flag
+		resolved.Text = strconv.FormatBool(flag)
+
+	case model.AttributeTypeText, model.AttributeTypeEnum:
+		text, ok := value.(string)
+		if !ok || strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("must be a non-empty string")
+		}
+		resolved.Text = strings.TrimSpace(text)
+
+		if definition.Type == model.AttributeTypeEnum {
+			valid := false
+			for _, option := range definition.Options {
+				if option == resolved.Text {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return nil, fmt.Errorf("must be one of %s", strings.Join(definition.Options, ", "))
+			}
+		}
+	}
+
+	return resolved, nil
+}
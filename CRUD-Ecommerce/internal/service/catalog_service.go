@@ -0,0 +1,516 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// importProgressInterval is how many rows are processed between progress
+	// writes to the import job.
+	importProgressInterval = 50
+
+	// importHeartbeatInterval is how often a running job is touched to show
+	// its worker is alive, however slowly its rows are going.
+	importHeartbeatInterval = time.Minute
+
+	// importStaleAfter is how long a job may go without a write before it is
+	// presumed lost with the process that ran it.
+	importStaleAfter = 5 * importHeartbeatInterval
+)
+
+var catalogColumns = []string{"sku", "name", "description", "price", "stock", "category", "image_url", "status"}
+
+type CatalogService interface {
+	StartImport(userID uuid.UUID, format model.CatalogFormat, r io.Reader) (*model.ImportJob, error)
+	GetImportJob(id uuid.UUID) (*model.ImportJob, error)
+	Export(status model.ProductStatus) ([]model.CatalogRow, error)
+	Shutdown(ctx context.Context) error
+}
+
+type catalogService struct {
+	jobRepo      repository.ImportJobRepository
+	productRepo  repository.ProductRepository
+	categoryRepo repository.CategoryRepository
+
+	// stopping is closed by Shutdown; running imports stop at the next row
+	stopping chan struct{}
+	stopOnce sync.Once
+	imports  sync.WaitGroup
+
+	// running holds the jobs this process is working on
+	mu      sync.Mutex
+	running map[uuid.UUID]bool
+}
+
+func NewCatalogService(jobRepo repository.ImportJobRepository, productRepo repository.ProductRepository, categoryRepo repository.CategoryRepository) CatalogService {
+	return &catalogService{
+		jobRepo:      jobRepo,
+		productRepo:  productRepo,
+		categoryRepo: categoryRepo,
+		stopping:     make(chan struct{}),
+		running:      make(map[uuid.UUID]bool),
+	}
+}
+
+// importRow is a parsed input row; err holds a parse failure that will be
+// reported against the row instead of aborting the whole file.
+type importRow struct {
+	line int
+	row  model.CatalogRow
+	err  error
+}
+
+// StartImport parses the whole upload up front, so malformed files are
+// rejected synchronously, then upserts the rows in the background.
+func (s *catalogService) StartImport(userID uuid.UUID, format model.CatalogFormat, r io.Reader) (*model.ImportJob, error) {
+	rows, err := parseCatalog(format, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("import file contains no rows")
+	}
+
+	job := &model.ImportJob{
+		Format:    format,
+		Status:    model.ImportJobStatusPending,
+		TotalRows: len(rows),
+		CreatedBy: userID,
+	}
+
+	if err := s.jobRepo.Create(job); err != nil {
+		return nil, fmt.Errorf("failed to create import job: %w", err)
+	}
+
+	s.setRunning(job.ID, true)
+	s.imports.Add(1)
+	go func() {
+		defer s.imports.Done()
+		defer s.setRunning(job.ID, false)
+		s.runImport(job, rows)
+	}()
+
+	return job, nil
+}
+
+// GetImportJob returns an import job. A job that no worker has touched for
+// importStaleAfter was lost with the process running it, without the chance
+// to mark itself interrupted, so it is marked here. Jobs running in this
+// process are never marked.
+func (s *catalogService) GetImportJob(id uuid.UUID) (*model.ImportJob, error) {
+	job, err := s.jobRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	active := job.Status == model.ImportJobStatusPending || job.Status == model.ImportJobStatusRunning
+	if !active || s.isRunning(id) || time.Since(job.UpdatedAt) <= importStaleAfter {
+		return job, nil
+	}
+
+	if err := s.jobRepo.Interrupt(id, interruptedMessage(job), time.Now().Add(-importStaleAfter)); err != nil {
+		return nil, err
+	}
+
+	// Read it back: it is either interrupted now or was written meanwhile
+	return s.jobRepo.GetByID(id)
+}
+
+func (s *catalogService) setRunning(id uuid.UUID, running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if running {
+		s.running[id] = true
+	} else {
+		delete(s.running, id)
+	}
+}
+
+func (s *catalogService) isRunning(id uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.running[id]
+}
+
+// Shutdown stops running imports at their next row, marking them
+// interrupted, and waits for them to do so or for ctx to expire.
+func (s *catalogService) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stopping) })
+
+	done := make(chan struct{})
+	go func() {
+		s.imports.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *catalogService) Export(status model.ProductStatus) ([]model.CatalogRow, error) {
+	if status != "" && !status.IsValid() {
+		return nil, fmt.Errorf("invalid product status: %s", status)
+	}
+
+	return s.productRepo.ExportRows(status)
+}
+
+func (s *catalogService) runImport(job *model.ImportJob, rows []importRow) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.finishImport(job, model.ImportJobStatusFailed, fmt.Sprintf("import aborted: %v", r))
+		}
+	}()
+
+	startedAt := time.Now()
+	job.Status = model.ImportJobStatusRunning
+	job.StartedAt = &startedAt
+	s.saveImportProgress(job)
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.heartbeat(job.ID, done)
+
+	categories := make(map[string]uuid.UUID)
+
+	for _, row := range rows {
+		select {
+		case <-s.stopping:
+			s.finishImport(job, model.ImportJobStatusInterrupted, interruptedMessage(job))
+			return
+		default:
+		}
+
+		created, err := s.importRow(job, row, categories)
+		if err != nil {
+			job.FailedCount++
+			job.Errors = append(job.Errors, model.ImportRowError{
+				Row:   row.line,
+				SKU:   row.row.SKU,
+				Error: err.Error(),
+			})
+		} else if created {
+			job.CreatedCount++
+		} else {
+			job.UpdatedCount++
+		}
+
+		job.ProcessedRows++
+		if job.ProcessedRows%importProgressInterval == 0 {
+			s.saveImportProgress(job)
+		}
+	}
+
+	s.finishImport(job, model.ImportJobStatusCompleted, "")
+}
+
+func (s *catalogService) importRow(job *model.ImportJob, row importRow, categories map[string]uuid.UUID) (bool, error) {
+	if row.err != nil {
+		return false, row.err
+	}
+	if err := validateCatalogRow(&row.row); err != nil {
+		return false, err
+	}
+
+	key := strings.ToLower(row.row.Category)
+	categoryID, ok := categories[key]
+	if !ok {
+		category, err := s.categoryRepo.GetByName(row.row.Category)
+		if err != nil {
+			return false, fmt.Errorf("unknown category %q", row.row.Category)
+		}
+		categoryID = category.ID
+		categories[key] = categoryID
+	}
+
+	product := &model.Product{
+		SKU:         row.row.SKU,
+		Name:        row.row.Name,
+		Description: row.row.Description,
+		BasePrice:   row.row.Price,
+		CategoryID:  categoryID,
+		ImageURL:    row.row.ImageURL,
+		Status:      model.ProductStatus(row.row.Status),
+	}
+
+	// Stock is brought to the imported level through the ledger, attributed
+	// to this job, in the same transaction as the product
+	stock := &repository.StockTarget{
+		Level: row.row.Stock,
+		Movement: model.InventoryMovement{
+			Reason:      model.InventoryReasonImport,
+			ReferenceID: &job.ID,
+			ActorID:     &job.CreatedBy,
+		},
+	}
+
+	// The slug is only used if the SKU is new; existing products keep theirs
+	var created bool
+	err := saveSlug("", row.row.Name, "product", func(slug string) (bool, error) {
+		return s.productRepo.SlugExists(slug, uuid.Nil)
+	}, func(slug string) error {
+		product.Slug = slug
+		var err error
+		created, err = s.productRepo.UpsertBySKU(product, stock)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return created, nil
+}
+
+// heartbeat touches a running job every importHeartbeatInterval until done
+// is closed, so that other processes do not take it for lost.
+func (s *catalogService) heartbeat(id uuid.UUID, done <-chan struct{}) {
+	ticker := time.NewTicker(importHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := s.jobRepo.Touch(id); err != nil {
+				log.Printf("import job %s: %v", id, err)
+			}
+		}
+	}
+}
+
+func (s *catalogService) finishImport(job *model.ImportJob, status model.ImportJobStatus, message string) {
+	finishedAt := time.Now()
+	job.Status = status
+	job.Message = message
+	job.FinishedAt = &finishedAt
+	s.saveImportProgress(job)
+}
+
+// interruptedMessage tells the user how to finish an interrupted job. Rows
+// are upserted by SKU, so uploading the same file again is safe.
+func interruptedMessage(job *model.ImportJob) string {
+	return fmt.Sprintf("import interrupted after %d of %d rows; upload the file again to finish it", job.ProcessedRows, job.TotalRows)
+}
+
+func (s *catalogService) saveImportProgress(job *model.ImportJob) {
+	if err := s.jobRepo.Update(job); err != nil {
+		log.Printf("import job %s: %v", job.ID, err)
+	}
+}
+
+func validateCatalogRow(row *model.CatalogRow) error {
+	row.SKU = strings.TrimSpace(row.SKU)
+	row.Name = strings.TrimSpace(row.Name)
+	row.Category = strings.TrimSpace(row.Category)
+	row.Status = strings.TrimSpace(row.Status)
+
+	var problems []string
+	if row.SKU == "" {
+		problems = append(problems, "sku is required")
+	}
+	if row.Name == "" {
+		problems = append(problems, "name is required")
+	}
+	if row.Price <= 0 {
+		problems = append(problems, "price must be greater than 0")
+	}
+	if row.Stock < 0 {
+		problems = append(problems, "stock must not be negative")
+	}
+	if row.Category == "" {
+		problems = append(problems, "category is required")
+	}
+	if row.Status != "" && !model.ProductStatus(row.Status).IsValid() {
+		problems = append(problems, "status must be one of draft, active, archived")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func parseCatalog(format model.CatalogFormat, r io.Reader) ([]importRow, error) {
+	switch format {
+	case model.CatalogFormatCSV:
+		return parseCatalogCSV(r)
+	case model.CatalogFormatNDJSON:
+		return parseCatalogNDJSON(r)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+func parseCatalogCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"sku", "name", "price", "category"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header is missing required column %q", required)
+		}
+	}
+
+	// Rows are reported by the file line they start on; quoted fields may
+	// span lines, so this is not the record count
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				rows = append(rows, importRow{line: parseErr.StartLine, err: err})
+				continue
+			}
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := importRow{line: line}
+		row.row = model.CatalogRow{
+			SKU:         field("sku"),
+			Name:        field("name"),
+			Description: field("description"),
+			Category:    field("category"),
+			ImageURL:    field("image_url"),
+			Status:      field("status"),
+		}
+
+		if price := field("price"); price != "" {
+			if row.row.Price, err = strconv.ParseFloat(price, 64); err != nil {
+				row.err = fmt.Errorf("invalid price %q", price)
+			}
+		}
+		if stock := field("stock"); stock != "" && row.err == nil {
+			if row.row.Stock, err = strconv.Atoi(stock); err != nil {
+				row.err = fmt.Errorf("invalid stock %q", stock)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func parseCatalogNDJSON(r io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []importRow
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		row := importRow{line: line}
+		if err := json.Unmarshal([]byte(text), &row.row); err != nil {
+			row.err = fmt.Errorf("invalid JSON: %v", err)
+		}
+		rows = append(rows, row)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read NDJSON: %w", err)
+	}
+
+	return rows, nil
+}
+
+// WriteCatalog encodes rows in the given format, using the same columns the
+// importer accepts so that exports can be re-imported unchanged.
+func WriteCatalog(w io.Writer, format model.CatalogFormat, rows []model.CatalogRow) error {
+	switch format {
+	case model.CatalogFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(catalogColumns); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			record := []string{
+				row.SKU,
+				row.Name,
+				row.Description,
+				strconv.FormatFloat(row.Price, 'f', 2, 64),
+				strconv.Itoa(row.Stock),
+				row.Category,
+				row.ImageURL,
+				row.Status,
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case model.CatalogFormatNDJSON:
+		encoder := json.NewEncoder(w)
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// WriteImportErrors encodes an import job's row errors as a CSV report.
+func WriteImportErrors(w io.Writer, errors []model.ImportRowError) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"row", "sku", "error"}); err != nil {
+		return err
+	}
+	for _, rowErr := range errors {
+		if err := writer.Write([]string{strconv.Itoa(rowErr.Row), rowErr.SKU, rowErr.Error}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
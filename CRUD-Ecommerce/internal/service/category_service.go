@@ -2,6 +2,7 @@ package service
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
 	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
@@ -11,9 +12,12 @@ import (
 type CategoryService interface {
 	Create(req *model.CategoryCreateRequest) (*model.Category, error)
 	GetByID(id uuid.UUID) (*model.Category, error)
+	GetBySlug(slug string) (*model.Category, string, error)
 	GetAll() ([]model.Category, error)
-	Update(id uuid.UUID, req *model.CategoryUpdateRequest) (*model.Category, error)
-	Delete(id uuid.UUID) error
+	GetTree() ([]model.CategoryTreeNode, error)
+	Update(id uuid.UUID, version int, req *model.CategoryUpdateRequest) (*model.Category, error)
+	Patch(id uuid.UUID, version int, patch []byte) (*model.Category, error)
+	Delete(id, targetID uuid.UUID) (*model.CategoryDeleteResult, error)
 }
 
 type categoryService struct {
@@ -25,12 +29,21 @@ func NewCategoryService(repo repository.CategoryRepository) CategoryService {
 }
 
 func (s *categoryService) Create(req *model.CategoryCreateRequest) (*model.Category, error) {
+	if err := s.checkParent(uuid.Nil, req.ParentID); err != nil {
+		return nil, err
+	}
+
 	category := &model.Category{
+		ParentID:    req.ParentID,
 		Name:        req.Name,
 		Description: req.Description,
 	}
 
-	if err := s.repo.Create(category); err != nil {
+	err := saveSlug(req.Slug, req.Name, "category", s.slugExists(uuid.Nil), func(slug string) error {
+		category.Slug = slug
+		return s.repo.Create(category)
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to create category: %w", err)
 	}
 
@@ -41,15 +54,82 @@ func (s *categoryService) GetByID(id uuid.UUID) (*model.Category, error) {
 	return s.repo.GetByID(id)
 }
 
+// GetBySlug returns a category by slug. For a slug retired by a rename the
+// second result is the category's current slug instead.
+func (s *categoryService) GetBySlug(slug string) (*model.Category, string, error) {
+	category, err := s.repo.GetBySlug(slug)
+	if err != nil {
+		current, redirectErr := s.repo.GetSlugRedirect(slug)
+		if redirectErr != nil {
+			return nil, "", err
+		}
+		return nil, current, nil
+	}
+
+	return category, "", nil
+}
+
 func (s *categoryService) GetAll() ([]model.Category, error) {
 	return s.repo.GetAll()
 }
 
-func (s *categoryService) Update(id uuid.UUID, req *model.CategoryUpdateRequest) (*model.Category, error) {
+// GetTree returns the top-level categories with their subcategories nested
+// beneath them, each level ordered by name.
+func (s *categoryService) GetTree() ([]model.CategoryTreeNode, error) {
+	categories, err := s.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[uuid.UUID][]model.Category)
+	var roots []model.Category
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+
+	var build func(categories []model.Category) []model.CategoryTreeNode
+	build = func(categories []model.Category) []model.CategoryTreeNode {
+		nodes := make([]model.CategoryTreeNode, 0, len(categories))
+		for _, category := range categories {
+			nodes = append(nodes, model.CategoryTreeNode{
+				Category: category,
+				Children: build(children[category.ID]),
+			})
+		}
+		return nodes
+	}
+
+	return build(roots), nil
+}
+
+// Update applies a PUT to the category. version is the version the client
+// last read; 0 skips the check.
+func (s *categoryService) Update(id uuid.UUID, version int, req *model.CategoryUpdateRequest) (*model.Category, error) {
 	category, err := s.repo.GetByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("category not found: %w", err)
 	}
+	if version != 0 && category.Version != version {
+		return nil, model.ErrVersionMismatch
+	}
+
+	// A new name moves the category to a new slug unless one is given; the
+	// old slug keeps working as a redirect
+	var newSlug *string
+	if req.Slug != "" || (req.Name != "" && req.Name != category.Name) {
+		newSlug = &req.Slug
+	}
+
+	if req.ParentID != nil {
+		if err := s.checkParent(category.ID, req.ParentID); err != nil {
+			return nil, err
+		}
+		category.ParentID = req.ParentID
+	}
 
 	if req.Name != "" {
 		category.Name = req.Name
@@ -58,13 +138,166 @@ func (s *categoryService) Update(id uuid.UUID, req *model.CategoryUpdateRequest)
 		category.Description = req.Description
 	}
 
-	if err := s.repo.Update(category); err != nil {
+	if err := s.update(category, newSlug); err != nil {
+		return nil, fmt.Errorf("failed to update category: %w", err)
+	}
+
+	return category, nil
+}
+
+// Patch applies an RFC 7396 merge patch to a category. A null description
+// clears it; a null slug, or a rename without a slug, generates a new one.
+// version works as for Update.
+func (s *categoryService) Patch(id uuid.UUID, version int, patch []byte) (*model.Category, error) {
+	category, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && category.Version != version {
+		return nil, model.ErrVersionMismatch
+	}
+
+	current := model.CategoryPatchDocument{
+		ParentID:    category.ParentID,
+		Name:        category.Name,
+		Slug:        category.Slug,
+		Description: category.Description,
+	}
+
+	var doc model.CategoryPatchDocument
+	changes, err := applyMergePatch(current, patch, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := rejectNulls(changes, "name"); err != nil {
+		return nil, err
+	}
+
+	doc.Name = strings.TrimSpace(doc.Name)
+	if doc.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	// A null parent moves the category to the top level
+	if _, moved := changes["parent_id"]; moved {
+		if err := s.checkParent(category.ID, doc.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	var newSlug *string
+	slug, slugPatched := changes["slug"]
+	if slugPatched || doc.Name != category.Name {
+		requested := ""
+		if slug != nil {
+			requested = doc.Slug
+		}
+		newSlug = &requested
+	}
+
+	category.ParentID = doc.ParentID
+	category.Name = doc.Name
+	category.Description = doc.Description
+
+	if err := s.update(category, newSlug); err != nil {
 		return nil, fmt.Errorf("failed to update category: %w", err)
 	}
 
 	return category, nil
 }
 
-func (s *categoryService) Delete(id uuid.UUID) error {
-	return s.repo.Delete(id)
+// update saves the category. A non-nil newSlug moves it to that slug, or to
+// one generated from its name when empty.
+func (s *categoryService) update(category *model.Category, newSlug *string) error {
+	if newSlug == nil {
+		return s.repo.Update(category)
+	}
+
+	return saveSlug(*newSlug, category.Name, "category", s.slugExists(category.ID), func(slug string) error {
+		category.Slug = slug
+		return s.repo.Update(category)
+	})
+}
+
+// slugExists reports whether a slug is used by a category other than id.
+func (s *categoryService) slugExists(id uuid.UUID) func(string) (bool, error) {
+	return func(slug string) (bool, error) {
+		return s.repo.SlugExists(slug, id)
+	}
+}
+
+// Delete removes a category, moving its products to targetID. Without a
+// target, only a category with no products can be deleted. The result
+// reports the products moved and what happened to their attribute values.
+func (s *categoryService) Delete(id, targetID uuid.UUID) (*model.CategoryDeleteResult, error) {
+	if targetID == id {
+		return nil, fmt.Errorf("target category must be a different category")
+	}
+
+	return s.repo.Delete(id, targetID)
+}
+
+// checkParent verifies that category id, uuid.Nil for a new category, can be
+// placed under parentID: the parent must exist, must not be the category or
+// one of its subcategories, and the category's subtree must still fit within
+// model.MaxCategoryDepth. This rejects bad moves early; the repository
+// repeats the check under a lock as it saves, which is what keeps
+// concurrent moves from building a cycle.
+func (s *categoryService) checkParent(id uuid.UUID, parentID *uuid.UUID) error {
+	if parentID == nil {
+		return nil
+	}
+
+	categories, err := s.repo.GetAll()
+	if err != nil {
+		return err
+	}
+
+	parents := make(map[uuid.UUID]*uuid.UUID, len(categories))
+	children := make(map[uuid.UUID][]uuid.UUID)
+	for _, category := range categories {
+		parents[category.ID] = category.ParentID
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+
+	if _, ok := parents[*parentID]; !ok {
+		return fmt.Errorf("parent category not found")
+	}
+
+	// Depth of the parent, walking up to the top level
+	depth := 0
+	for ancestor := parentID; ancestor != nil; ancestor = parents[*ancestor] {
+		if *ancestor == id {
+			return fmt.Errorf("a category cannot be moved under itself or one of its subcategories")
+		}
+		depth++
+		if depth > len(categories) {
+			return fmt.Errorf("category tree contains a cycle")
+		}
+	}
+
+	// Levels in the subtree being placed, counting the category itself
+	var height func(id uuid.UUID) int
+	height = func(id uuid.UUID) int {
+		levels := 0
+		for _, child := range children[id] {
+			if h := height(child); h > levels {
+				levels = h
+			}
+		}
+		return levels + 1
+	}
+
+	levels := 1
+	if id != uuid.Nil {
+		levels = height(id)
+	}
+
+	if depth+levels > model.MaxCategoryDepth {
+		return fmt.Errorf("categories can be nested at most %d levels deep", model.MaxCategoryDepth)
+	}
+
+	return nil
 }
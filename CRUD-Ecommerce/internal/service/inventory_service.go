@@ -0,0 +1,105 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
+	"github.com/google/uuid"
+)
+
+type InventoryService interface {
+	Adjust(productID, actorID uuid.UUID, req *model.InventoryAdjustmentRequest) (*model.InventoryMovement, error)
+	GetMovements(productID uuid.UUID) ([]model.InventoryMovement, error)
+	GetProductInventory(productID uuid.UUID) (*model.ProductInventory, error)
+	GetAlerts(status model.StockAlertStatus) ([]model.StockAlert, error)
+	AcknowledgeAlert(id uuid.UUID) (*model.StockAlert, error)
+}
+
+type inventoryService struct {
+	repo          repository.InventoryRepository
+	productRepo   repository.ProductRepository
+	warehouseRepo repository.WarehouseRepository
+	alertRepo     repository.StockAlertRepository
+}
+
+func NewInventoryService(repo repository.InventoryRepository, productRepo repository.ProductRepository, warehouseRepo repository.WarehouseRepository, alertRepo repository.StockAlertRepository) InventoryService {
+	return &inventoryService{
+		repo:          repo,
+		productRepo:   productRepo,
+		warehouseRepo: warehouseRepo,
+		alertRepo:     alertRepo,
+	}
+}
+
+func (s *inventoryService) Adjust(productID, actorID uuid.UUID, req *model.InventoryAdjustmentRequest) (*model.InventoryMovement, error) {
+	if req.Quantity == 0 {
+		return nil, fmt.Errorf("quantity must not be zero")
+	}
+	if req.Reason != model.InventoryReasonAdjustment && req.Reason != model.InventoryReasonReturn {
+		return nil, fmt.Errorf("reason must be adjustment or return")
+	}
+
+	product, err := s.productRepo.GetByID(productID)
+	if err != nil {
+		return nil, err
+	}
+	if product.Type == model.ProductTypeBundle {
+		return nil, errBundleStock
+	}
+
+	movement := &model.InventoryMovement{
+		ProductID:   productID,
+		WarehouseID: req.WarehouseID,
+		Quantity:    req.Quantity,
+		Reason:      req.Reason,
+		ActorID:     &actorID,
+		Note:        req.Note,
+	}
+
+	if err := s.repo.Record([]*model.InventoryMovement{movement}); err != nil {
+		return nil, err
+	}
+
+	return movement, nil
+}
+
+func (s *inventoryService) GetMovements(productID uuid.UUID) ([]model.InventoryMovement, error) {
+	if _, err := s.productRepo.GetByID(productID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByProduct(productID)
+}
+
+// GetProductInventory breaks a product's available-to-sell stock down by
+// warehouse.
+func (s *inventoryService) GetProductInventory(productID uuid.UUID) (*model.ProductInventory, error) {
+	product, err := s.productRepo.GetByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	levels, err := s.warehouseRepo.GetStockLevels(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.ProductInventory{
+		ProductID:       product.ID,
+		AvailableToSell: product.Stock,
+		Warehouses:      levels,
+	}, nil
+}
+
+func (s *inventoryService) GetAlerts(status model.StockAlertStatus) ([]model.StockAlert, error) {
+	if status != "" && status != model.StockAlertStatusOpen && status != model.StockAlertStatusAcknowledged {
+		return nil, fmt.Errorf("invalid alert status: %s", status)
+	}
+
+	return s.alertRepo.GetAll(status)
+}
+
+func (s *inventoryService) AcknowledgeAlert(id uuid.UUID) (*model.StockAlert, error) {
+	return s.alertRepo.Acknowledge(id)
+}
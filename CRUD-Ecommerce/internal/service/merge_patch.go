@@ -0,0 +1,78 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// applyMergePatch applies an RFC 7396 JSON merge patch to the JSON form of
+// doc and decodes the merged document into dest. Keys absent from the patch
+// keep their current value, null removes a key (leaving dest's zero value)
+// and anything else replaces it, recursing into objects. Keys the document
+// does not have are rejected. The decoded patch is returned so callers can
+// tell which fields it touched.
+func applyMergePatch(doc interface{}, patch []byte, dest interface{}) (map[string]interface{}, error) {
+	var changes map[string]interface{}
+	if err := json.Unmarshal(patch, &changes); err != nil || changes == nil {
+		return nil, fmt.Errorf("merge patch must be a JSON object")
+	}
+
+	current, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode document: %w", err)
+	}
+
+	var target map[string]interface{}
+	if err := json.Unmarshal(current, &target); err != nil {
+		return nil, fmt.Errorf("failed to decode document: %w", err)
+	}
+
+	merged, err := json.Marshal(mergePatch(target, changes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode patched document: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dest); err != nil {
+		return nil, fmt.Errorf("invalid patch: %s", strings.TrimPrefix(err.Error(), "json: "))
+	}
+
+	return changes, nil
+}
+
+// mergePatch is the MergePatch function from RFC 7396.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+// rejectNulls fails when the patch sets any of the given fields to null;
+// used for fields that have no empty state to clear to.
+func rejectNulls(changes map[string]interface{}, fields ...string) error {
+	for _, field := range fields {
+		if value, ok := changes[field]; ok && value == nil {
+			return fmt.Errorf("%s cannot be null", field)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
+	"github.com/google/uuid"
+)
+
+type NotificationService interface {
+	GetUserNotifications(userID uuid.UUID) ([]model.Notification, error)
+	MarkRead(id, userID uuid.UUID) error
+	Subscribe(productID, userID uuid.UUID) (*model.StockSubscription, error)
+	Unsubscribe(productID, userID uuid.UUID) error
+}
+
+type notificationService struct {
+	repo        repository.NotificationRepository
+	productRepo repository.ProductRepository
+}
+
+func NewNotificationService(repo repository.NotificationRepository, productRepo repository.ProductRepository) NotificationService {
+	return &notificationService{
+		repo:        repo,
+		productRepo: productRepo,
+	}
+}
+
+func (s *notificationService) GetUserNotifications(userID uuid.UUID) ([]model.Notification, error) {
+	return s.repo.GetByUser(userID)
+}
+
+func (s *notificationService) MarkRead(id, userID uuid.UUID) error {
+	return s.repo.MarkRead(id, userID)
+}
+
+// Subscribe asks to be notified when an out-of-stock product is back.
+func (s *notificationService) Subscribe(productID, userID uuid.UUID) (*model.StockSubscription, error) {
+	product, err := s.productRepo.GetByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if product.Status != model.ProductStatusActive {
+		return nil, fmt.Errorf("product not found")
+	}
+	// Restocks are tracked per product, so a bundle never comes back in
+	// stock by itself
+	if product.Type == model.ProductTypeBundle {
+		return nil, fmt.Errorf("back-in-stock alerts are not available for bundles")
+	}
+	if product.Stock > 0 {
+		return nil, fmt.Errorf("product is in stock")
+	}
+
+	return s.repo.Subscribe(productID, userID)
+}
+
+func (s *notificationService) Unsubscribe(productID, userID uuid.UUID) error {
+	return s.repo.Unsubscribe(productID, userID)
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
 	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
@@ -10,38 +12,73 @@ import (
 
 type OrderService interface {
 	Create(userID uuid.UUID, req *model.OrderCreateRequest) (*model.Order, error)
-	GetByID(orderID, userID uuid.UUID, isAdmin bool) (*model.Order, error)
+	CreateFromWishlist(userID, wishlistID uuid.UUID, req *model.OrderCreateRequest) (*model.Order, error)
+	GetByID(orderID, userID uuid.UUID, readAll bool) (*model.Order, error)
 	GetUserOrders(userID uuid.UUID) ([]model.Order, error)
 	GetAllOrders() ([]model.Order, error)
-	UpdateStatus(orderID uuid.UUID, status model.OrderStatus) (*model.Order, error)
-	Cancel(orderID, userID uuid.UUID, isAdmin bool) error
+	UpdateStatus(orderID, actorID uuid.UUID, version int, status model.OrderStatus) (*model.Order, error)
+	Cancel(orderID, userID uuid.UUID, cancelAny bool) error
 }
 
 type orderService struct {
-	orderRepo   repository.OrderRepository
-	productRepo repository.ProductRepository
+	orderRepo     repository.OrderRepository
+	userRepo      repository.UserRepository
+	productRepo   repository.ProductRepository
+	bundleRepo    repository.BundleRepository
+	warehouseRepo repository.WarehouseRepository
+	strategy      model.AllocationStrategy
 }
 
-func NewOrderService(orderRepo repository.OrderRepository, productRepo repository.ProductRepository) OrderService {
+func NewOrderService(orderRepo repository.OrderRepository, userRepo repository.UserRepository, productRepo repository.ProductRepository, bundleRepo repository.BundleRepository, warehouseRepo repository.WarehouseRepository, strategy model.AllocationStrategy) OrderService {
+	if !strategy.IsValid() {
+		strategy = model.AllocationStrategyPriority
+	}
+
 	return &orderService{
-		orderRepo:   orderRepo,
-		productRepo: productRepo,
+		orderRepo:     orderRepo,
+		userRepo:      userRepo,
+		productRepo:   productRepo,
+		bundleRepo:    bundleRepo,
+		warehouseRepo: warehouseRepo,
+		strategy:      strategy,
 	}
 }
 
 func (s *orderService) Create(userID uuid.UUID, req *model.OrderCreateRequest) (*model.Order, error) {
+	return s.create(userID, req, nil)
+}
+
+// CreateFromWishlist places the order and takes the ordered products off the
+// wishlist together; the caller checks the wishlist belongs to the user.
+func (s *orderService) CreateFromWishlist(userID, wishlistID uuid.UUID, req *model.OrderCreateRequest) (*model.Order, error) {
+	return s.create(userID, req, &wishlistID)
+}
+
+func (s *orderService) create(userID uuid.UUID, req *model.OrderCreateRequest, wishlistID *uuid.UUID) (*model.Order, error) {
 	if len(req.Items) == 0 {
 		return nil, fmt.Errorf("order must contain at least one item")
 	}
 
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.EmailVerifiedAt == nil {
+		return nil, model.ErrEmailNotVerified
+	}
+
 	order := &model.Order{
-		UserID: userID,
-		Status: model.OrderStatusPending,
-		Items:  []model.OrderItem{},
+		UserID:         userID,
+		Status:         model.OrderStatusPending,
+		ShippingRegion: strings.TrimSpace(req.ShippingRegion),
+		Items:          []model.OrderItem{},
 	}
 
 	var totalPrice float64
 
+	// Stock already promised to earlier items of this order
+	allocated := make(map[stockKey]int)
+
 	// Process each item
 	for _, itemReq := range req.Items {
 		// Get product details
@@ -50,36 +87,67 @@ func (s *orderService) Create(userID uuid.UUID, req *model.OrderCreateRequest) (
 			return nil, fmt.Errorf("product %s not found: %w", itemReq.ProductID, err)
 		}
 
-		// Check stock availability
+		// Only products currently on sale can be ordered
+		if product.Status != model.ProductStatusActive {
+			return nil, fmt.Errorf("product %s is not available", product.Name)
+		}
+
+		// Check stock availability; a bundle's stock is the number of
+		// complete sets its components make up
 		if product.Stock < itemReq.Quantity {
 			return nil, fmt.Errorf("insufficient stock for product %s. Available: %d, Requested: %d",
 				product.Name, product.Stock, itemReq.Quantity)
 		}
 
+		// Choose the warehouses that ship this item, or each of its
+		// components for a bundle
+		var allocations []model.OrderAllocation
+		if product.Type == model.ProductTypeBundle {
+			allocations, err = s.allocateBundle(product, itemReq.Quantity, order.ShippingRegion, allocated)
+		} else {
+			allocations, err = s.allocate(product, itemReq.Quantity, order.ShippingRegion, allocated)
+		}
+		if err != nil {
+			return nil, err
+		}
+
 		// Calculate item price
 		itemPrice := product.Price * float64(itemReq.Quantity)
 		totalPrice += itemPrice
 
 		// Create order item
 		orderItem := model.OrderItem{
-			ProductID: itemReq.ProductID,
-			Quantity:  itemReq.Quantity,
-			Price:     product.Price,
+			ProductID:   itemReq.ProductID,
+			ProductName: product.Name,
+			ProductSKU:  product.SKU,
+			Quantity:    itemReq.Quantity,
+			Price:       product.Price,
+			Allocations: allocations,
 		}
 
 		order.Items = append(order.Items, orderItem)
-
-		// Update product stock
-		newStock := product.Stock - itemReq.Quantity
-		if err := s.productRepo.UpdateStock(product.ID, newStock); err != nil {
-			return nil, fmt.Errorf("failed to update stock: %w", err)
-		}
 	}
 
 	order.TotalPrice = totalPrice
 
-	// Create order in database
-	if err := s.orderRepo.Create(order); err != nil {
+	// Take the stock as the order is created; the ledger re-checks
+	// availability under a row lock, so a concurrent order may still win and
+	// this one is not created. Bundle items take stock from their components.
+	var movements []*model.InventoryMovement
+	for _, item := range order.Items {
+		for _, allocation := range item.Allocations {
+			warehouseID := allocation.WarehouseID
+			movements = append(movements, &model.InventoryMovement{
+				ProductID:   allocation.ProductID,
+				WarehouseID: &warehouseID,
+				Quantity:    -allocation.Quantity,
+				Reason:      model.InventoryReasonSale,
+				ActorID:     &userID,
+			})
+		}
+	}
+
+	if err := s.orderRepo.Create(order, movements, wishlistID); err != nil {
 		return nil, fmt.Errorf("failed to create order: %w", err)
 	}
 
@@ -87,14 +155,16 @@ func (s *orderService) Create(userID uuid.UUID, req *model.OrderCreateRequest) (
 	return s.orderRepo.GetByID(order.ID)
 }
 
-func (s *orderService) GetByID(orderID, userID uuid.UUID, isAdmin bool) (*model.Order, error) {
+// GetByID returns one of the user's orders, or any order when readAll is
+// set.
+func (s *orderService) GetByID(orderID, userID uuid.UUID, readAll bool) (*model.Order, error) {
 	order, err := s.orderRepo.GetByID(orderID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if user owns the order or is admin
-	if !isAdmin && order.UserID != userID {
+	// Check if user owns the order or may read all orders
+	if !readAll && order.UserID != userID {
 		return nil, fmt.Errorf("access denied: order does not belong to user")
 	}
 
@@ -109,12 +179,25 @@ func (s *orderService) GetAllOrders() ([]model.Order, error) {
 	return s.orderRepo.GetAll()
 }
 
-func (s *orderService) UpdateStatus(orderID uuid.UUID, status model.OrderStatus) (*model.Order, error) {
+// UpdateStatus moves an order to a new status. version is the version the
+// client last read; 0 skips the check. Cancelling goes through the same path
+// as Cancel so that the stock is returned.
+func (s *orderService) UpdateStatus(orderID, actorID uuid.UUID, version int, status model.OrderStatus) (*model.Order, error) {
 	// Validate order exists
 	order, err := s.orderRepo.GetByID(orderID)
 	if err != nil {
 		return nil, err
 	}
+	if version != 0 && order.Version != version {
+		return nil, model.ErrVersionMismatch
+	}
+
+	if status == model.OrderStatusCancelled {
+		if err := s.cancel(order, actorID, order.Version); err != nil {
+			return nil, err
+		}
+		return s.orderRepo.GetByID(orderID)
+	}
 
 	// Validate status transition
 	if order.Status == model.OrderStatusCancelled {
@@ -125,40 +208,154 @@ func (s *orderService) UpdateStatus(orderID uuid.UUID, status model.OrderStatus)
 		return nil, fmt.Errorf("cannot change status of delivered order")
 	}
 
-	if err := s.orderRepo.UpdateStatus(orderID, status); err != nil {
+	if err := s.orderRepo.UpdateStatus(orderID, status, order.Version); err != nil {
 		return nil, fmt.Errorf("failed to update order status: %w", err)
 	}
 
 	return s.orderRepo.GetByID(orderID)
 }
 
-func (s *orderService) Cancel(orderID, userID uuid.UUID, isAdmin bool) error {
+// Cancel cancels one of the user's orders, or any order when cancelAny is
+// set.
+func (s *orderService) Cancel(orderID, userID uuid.UUID, cancelAny bool) error {
 	order, err := s.orderRepo.GetByID(orderID)
 	if err != nil {
 		return err
 	}
 
-	// Check if user owns the order or is admin
-	if !isAdmin && order.UserID != userID {
+	// Check if user owns the order or may cancel any order
+	if !cancelAny && order.UserID != userID {
 		return fmt.Errorf("access denied: order does not belong to user")
 	}
 
-	// Only pending or processing orders can be cancelled
+	return s.cancel(order, userID, 0)
+}
+
+// cancel cancels a pending or processing order and restores its stock to
+// the warehouses it was taken from. Orders placed before warehouses existed
+// have no allocations and go back to the default warehouse. The repository
+// re-checks the status as it cancels, so concurrent cancels restock once.
+func (s *orderService) cancel(order *model.Order, actorID uuid.UUID, version int) error {
 	if order.Status != model.OrderStatusPending && order.Status != model.OrderStatusProcessing {
 		return fmt.Errorf("order cannot be cancelled in current status: %s", order.Status)
 	}
 
-	// Restore product stock
+	var movements []*model.InventoryMovement
 	for _, item := range order.Items {
-		product, err := s.productRepo.GetByID(item.ProductID)
-		if err != nil {
-			continue // Skip if product not found
+		if len(item.Allocations) == 0 {
+			movements = append(movements, &model.InventoryMovement{
+				ProductID:   item.ProductID,
+				Quantity:    item.Quantity,
+				Reason:      model.InventoryReasonCancellation,
+				ReferenceID: &order.ID,
+				ActorID:     &actorID,
+			})
+			continue
+		}
+
+		for _, allocation := range item.Allocations {
+			warehouseID := allocation.WarehouseID
+			movements = append(movements, &model.InventoryMovement{
+				ProductID:   allocation.ProductID,
+				WarehouseID: &warehouseID,
+				Quantity:    allocation.Quantity,
+				Reason:      model.InventoryReasonCancellation,
+				ReferenceID: &order.ID,
+				ActorID:     &actorID,
+			})
+		}
+	}
+
+	return s.orderRepo.Cancel(order.ID, version, movements)
+}
+
+type stockKey struct {
+	warehouseID uuid.UUID
+	productID   uuid.UUID
+}
+
+// allocate splits an item's quantity across warehouses. A single warehouse
+// that can ship the whole quantity is preferred; otherwise warehouses are
+// drained in strategy order. allocated carries stock already promised to
+// earlier items and is updated with this item's allocations.
+func (s *orderService) allocate(product *model.Product, quantity int, region string, allocated map[stockKey]int) ([]model.OrderAllocation, error) {
+	levels, err := s.warehouseRepo.GetStockLevels(product.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.strategy == model.AllocationStrategyClosest && region != "" {
+		// Levels come back in priority order; keep it within each group
+		sort.SliceStable(levels, func(i, j int) bool {
+			return strings.EqualFold(levels[i].Region, region) && !strings.EqualFold(levels[j].Region, region)
+		})
+	}
+
+	available := func(level model.WarehouseStock) int {
+		return level.Quantity - allocated[stockKey{level.WarehouseID, product.ID}]
+	}
+
+	var allocations []model.OrderAllocation
+	for _, level := range levels {
+		if available(level) >= quantity {
+			allocations = []model.OrderAllocation{{ProductID: product.ID, WarehouseID: level.WarehouseID, Quantity: quantity}}
+			break
+		}
+	}
+
+	if allocations == nil {
+		remaining := quantity
+		for _, level := range levels {
+			take := available(level)
+			if take <= 0 {
+				continue
+			}
+			if take > remaining {
+				take = remaining
+			}
+			allocations = append(allocations, model.OrderAllocation{ProductID: product.ID, WarehouseID: level.WarehouseID, Quantity: take})
+			remaining -= take
+			if remaining == 0 {
+				break
+			}
 		}
 
-		newStock := product.Stock + item.Quantity
-		s.productRepo.UpdateStock(product.ID, newStock)
+		if remaining > 0 {
+			return nil, fmt.Errorf("insufficient stock for product %s. Available: %d, Requested: %d",
+				product.Name, quantity-remaining, quantity)
+		}
+	}
+
+	for _, allocation := range allocations {
+		allocated[stockKey{allocation.WarehouseID, product.ID}] += allocation.Quantity
+	}
+
+	return allocations, nil
+}
+
+// allocateBundle allocates each of a bundle's components, quantity sets'
+// worth, sharing allocated with the rest of the order.
+func (s *orderService) allocateBundle(bundle *model.Product, quantity int, region string, allocated map[stockKey]int) ([]model.OrderAllocation, error) {
+	components, err := s.bundleRepo.GetComponents(bundle.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(components) == 0 {
+		return nil, fmt.Errorf("product %s is not available", bundle.Name)
+	}
+
+	var allocations []model.OrderAllocation
+	for _, component := range components {
+		if component.Product.Status != model.ProductStatusActive {
+			return nil, fmt.Errorf("product %s is not available", bundle.Name)
+		}
+
+		parts, err := s.allocate(component.Product, component.Quantity*quantity, region, allocated)
+		if err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, parts...)
 	}
 
-	// Update order status to cancelled
-	return s.orderRepo.UpdateStatus(orderID, model.OrderStatusCancelled)
+	return allocations, nil
 }
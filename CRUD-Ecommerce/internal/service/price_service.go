@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
+	"github.com/google/uuid"
+)
+
+type PriceService interface {
+	Schedule(productID, actorID uuid.UUID, req *model.ProductPriceCreateRequest) (*model.ProductPrice, error)
+	GetHistory(productID uuid.UUID) ([]model.ProductPrice, error)
+	Cancel(productID, priceID uuid.UUID) error
+	StartRefresher(ctx context.Context, interval time.Duration)
+}
+
+type priceService struct {
+	repo        repository.PriceRepository
+	productRepo repository.ProductRepository
+}
+
+func NewPriceService(repo repository.PriceRepository, productRepo repository.ProductRepository) PriceService {
+	return &priceService{
+		repo:        repo,
+		productRepo: productRepo,
+	}
+}
+
+// Schedule adds a price that overrides the regular price from StartsAt until
+// EndsAt. Without an end it applies until the regular price next changes.
+func (s *priceService) Schedule(productID, actorID uuid.UUID, req *model.ProductPriceCreateRequest) (*model.ProductPrice, error) {
+	if _, err := s.productRepo.GetByID(productID); err != nil {
+		return nil, err
+	}
+
+	if req.Price <= 0 {
+		return nil, fmt.Errorf("price must be greater than zero")
+	}
+	if req.CompareAtPrice != nil && *req.CompareAtPrice <= req.Price {
+		return nil, fmt.Errorf("compare_at_price must be greater than price")
+	}
+
+	now := time.Now()
+	startsAt := now
+	if req.StartsAt != nil {
+		startsAt = *req.StartsAt
+	}
+
+	if req.EndsAt != nil {
+		if !req.EndsAt.After(startsAt) {
+			return nil, fmt.Errorf("ends_at must be after starts_at")
+		}
+		if !req.EndsAt.After(now) {
+			return nil, fmt.Errorf("ends_at must be in the future")
+		}
+	}
+
+	price := &model.ProductPrice{
+		ProductID:      productID,
+		Kind:           model.PriceKindScheduled,
+		Price:          req.Price,
+		CompareAtPrice: req.CompareAtPrice,
+		StartsAt:       startsAt,
+		EndsAt:         req.EndsAt,
+		CreatedBy:      &actorID,
+	}
+
+	if err := s.repo.Create(price); err != nil {
+		return nil, err
+	}
+
+	return price, nil
+}
+
+func (s *priceService) GetHistory(productID uuid.UUID) ([]model.ProductPrice, error) {
+	if _, err := s.productRepo.GetByID(productID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByProduct(productID)
+}
+
+func (s *priceService) Cancel(productID, priceID uuid.UUID) error {
+	return s.repo.Cancel(priceID, productID)
+}
+
+// StartRefresher keeps the stored effective prices that listings filter and
+// sort on in step with scheduled price windows, checking every interval in
+// the background until ctx is done. A zero interval disables it.
+func (s *priceService) StartRefresher(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			refreshed, err := s.repo.RefreshEffectivePrices()
+			if err != nil {
+				log.Printf("price refresh: %v", err)
+				continue
+			}
+			if refreshed > 0 {
+				log.Printf("price refresh: updated %d effective prices", refreshed)
+			}
+		}
+	}()
+}
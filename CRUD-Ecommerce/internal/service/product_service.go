@@ -1,7 +1,9 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
 	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
@@ -9,57 +11,252 @@ import (
 )
 
 type ProductService interface {
-	Create(req *model.ProductCreateRequest) (*model.Product, error)
-	GetByID(id uuid.UUID) (*model.Product, error)
-	GetAll(params model.ProductQueryParams) ([]model.Product, error)
+	Create(req *model.ProductCreateRequest, actorID uuid.UUID) (*model.Product, error)
+	GetByID(id uuid.UUID, anyStatus bool) (*model.Product, error)
+	GetBySlug(slug string) (*model.Product, string, error)
+	GetAll(params model.ProductQueryParams) (*model.ProductPage, error)
+	Search(params model.ProductQueryParams) (*model.ProductSearchResponse, error)
 	GetByCategory(categoryID uuid.UUID) ([]model.Product, error)
-	Update(id uuid.UUID, req *model.ProductUpdateRequest) (*model.Product, error)
+	Update(id uuid.UUID, version int, req *model.ProductUpdateRequest, actorID uuid.UUID) (*model.Product, error)
+	Patch(id uuid.UUID, version int, patch []byte, actorID uuid.UUID) (*model.Product, error)
+	SetComponents(id uuid.UUID, version int, req *model.BundleComponentsRequest) (*model.Product, error)
 	Delete(id uuid.UUID) error
 }
 
 type productService struct {
-	repo repository.ProductRepository
+	repo          repository.ProductRepository
+	categoryRepo  repository.CategoryRepository
+	attributeRepo repository.AttributeRepository
+	bundleRepo    repository.BundleRepository
 }
 
-func NewProductService(repo repository.ProductRepository) ProductService {
-	return &productService{repo: repo}
+func NewProductService(repo repository.ProductRepository, categoryRepo repository.CategoryRepository, attributeRepo repository.AttributeRepository, bundleRepo repository.BundleRepository) ProductService {
+	return &productService{
+		repo:          repo,
+		categoryRepo:  categoryRepo,
+		attributeRepo: attributeRepo,
+		bundleRepo:    bundleRepo,
+	}
 }
 
-func (s *productService) Create(req *model.ProductCreateRequest) (*model.Product, error) {
+func (s *productService) Create(req *model.ProductCreateRequest, actorID uuid.UUID) (*model.Product, error) {
+	if req.Status != "" && !req.Status.IsValid() {
+		return nil, fmt.Errorf("invalid product status: %s", req.Status)
+	}
+	if req.ReorderThreshold < 0 {
+		return nil, fmt.Errorf("reorder threshold must not be negative")
+	}
+	if req.Type == "" {
+		req.Type = model.ProductTypeSimple
+	}
+	if !req.Type.IsValid() {
+		return nil, fmt.Errorf("invalid product type: %s", req.Type)
+	}
+
+	var components []model.BundleComponent
+	if req.Type == model.ProductTypeBundle {
+		if req.Stock != 0 {
+			return nil, errBundleStock
+		}
+		var err error
+		if components, err = s.resolveComponents(uuid.Nil, req.Components); err != nil {
+			return nil, err
+		}
+	} else if len(req.Components) > 0 {
+		return nil, errNotBundle
+	}
+
+	definitions, err := s.attributeRepo.GetDefinitions(req.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+	attributes, _, err := resolveAttributes(definitions, req.Attributes, true)
+	if err != nil {
+		return nil, err
+	}
+
 	product := &model.Product{
-		Name:        req.Name,
-		Description: req.Description,
-		Price:       req.Price,
-		Stock:       req.Stock,
-		CategoryID:  req.CategoryID,
-		ImageURL:    req.ImageURL,
+		SKU:              strings.TrimSpace(req.SKU),
+		Name:             req.Name,
+		Type:             req.Type,
+		Description:      req.Description,
+		BasePrice:        req.Price,
+		CategoryID:       req.CategoryID,
+		ImageURL:         req.ImageURL,
+		Status:           req.Status,
+		ReorderThreshold: req.ReorderThreshold,
+	}
+
+	// Products start empty; the opening stock goes through the ledger
+	changes := repository.ProductChanges{Attributes: attributes}
+	if product.Type == model.ProductTypeBundle {
+		changes.Components = components
+	} else {
+		changes.Stock = &repository.StockTarget{
+			Level:    req.Stock,
+			Movement: model.InventoryMovement{Reason: model.InventoryReasonInitial, ActorID: &actorID},
+		}
 	}
 
-	if err := s.repo.Create(product); err != nil {
+	err = saveSlug(req.Slug, req.Name, "product", s.slugExists(uuid.Nil), func(slug string) error {
+		product.Slug = slug
+		return s.repo.Create(product, changes)
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to create product: %w", err)
 	}
 
+	// Re-read for the effective price, category and components
+	return s.get(product.ID)
+}
+
+// GetByID returns a product. Unless anyStatus is set, as it is for catalog
+// staff, drafts and archived products are reported as not found.
+func (s *productService) GetByID(id uuid.UUID, anyStatus bool) (*model.Product, error) {
+	product, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !anyStatus && product.Status != model.ProductStatusActive {
+		return nil, fmt.Errorf("product not found")
+	}
+
 	return product, nil
 }
 
-func (s *productService) GetByID(id uuid.UUID) (*model.Product, error) {
-	return s.repo.GetByID(id)
+// GetBySlug returns a storefront product by slug. When the slug has been
+// retired by a rename, no product is returned; instead the second result is
+// the product's current slug, for the caller to redirect to.
+func (s *productService) GetBySlug(slug string) (*model.Product, string, error) {
+	product, err := s.repo.GetBySlug(slug)
+	if err != nil {
+		current, redirectErr := s.repo.GetSlugRedirect(slug)
+		if redirectErr != nil {
+			return nil, "", err
+		}
+		if product, err = s.repo.GetBySlug(current); err != nil {
+			return nil, "", err
+		}
+		if product.Status != model.ProductStatusActive {
+			return nil, "", fmt.Errorf("product not found")
+		}
+		return nil, current, nil
+	}
+
+	if product.Status != model.ProductStatusActive {
+		return nil, "", fmt.Errorf("product not found")
+	}
+	if err := s.loadDetails(product); err != nil {
+		return nil, "", err
+	}
+
+	return product, "", nil
+}
+
+// pageSize applies the default page size and caps requested ones.
+func pageSize(size int) int {
+	if size <= 0 {
+		return model.DefaultPageSize
+	}
+	if size > model.MaxPageSize {
+		return model.MaxPageSize
+	}
+	return size
 }
 
-func (s *productService) GetAll(params model.ProductQueryParams) ([]model.Product, error) {
-	return s.repo.GetAll(params)
+func (s *productService) GetAll(params model.ProductQueryParams) (*model.ProductPage, error) {
+	params.PageSize = pageSize(params.PageSize)
+	params.Search = strings.TrimSpace(params.Search)
+
+	// Relevance only means something when there is a query to rank against
+	if params.Sort == "" || (params.Sort == model.ProductSortRelevance && params.Search == "") {
+		params.Sort = model.ProductSortNewest
+		if params.Search != "" {
+			params.Sort = model.ProductSortRelevance
+		}
+	}
+
+	if params.Cursor != nil && params.Cursor.Sort != string(params.Sort) {
+		return nil, model.ErrCursorMismatch
+	}
+
+	return s.repo.List(params)
+}
+
+func (s *productService) Search(params model.ProductQueryParams) (*model.ProductSearchResponse, error) {
+	params.PageSize = pageSize(params.PageSize)
+	params.Search = strings.TrimSpace(params.Search)
+
+	// Whether to fall back to fuzzy matching is decided over the whole match
+	// set, not the requested page, and facets are counted over the same set
+	fuzzy := false
+	if params.Search != "" {
+		matched, err := s.repo.HasFullTextMatch(params)
+		if err != nil {
+			return nil, err
+		}
+		fuzzy = !matched
+	}
+
+	results, err := s.repo.Search(params, fuzzy)
+	if err != nil {
+		return nil, err
+	}
+
+	facets, err := s.repo.GetFacets(params, fuzzy)
+	if err != nil {
+		return nil, err
+	}
+
+	if results == nil {
+		results = []model.ProductSearchResult{}
+	}
+
+	return &model.ProductSearchResponse{
+		Results: results,
+		Facets:  facets,
+	}, nil
 }
 
 func (s *productService) GetByCategory(categoryID uuid.UUID) ([]model.Product, error) {
 	return s.repo.GetByCategory(categoryID)
 }
 
-func (s *productService) Update(id uuid.UUID, req *model.ProductUpdateRequest) (*model.Product, error) {
+// Update applies a PUT to the product. version is the version the client
+// last read; 0 skips the check.
+func (s *productService) Update(id uuid.UUID, version int, req *model.ProductUpdateRequest, actorID uuid.UUID) (*model.Product, error) {
 	product, err := s.repo.GetByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("product not found: %w", err)
 	}
+	if version != 0 && product.Version != version {
+		return nil, model.ErrVersionMismatch
+	}
+
+	if req.Status != "" && !req.Status.IsValid() {
+		return nil, fmt.Errorf("invalid product status: %s", req.Status)
+	}
+	if req.ReorderThreshold != nil && *req.ReorderThreshold < 0 {
+		return nil, fmt.Errorf("reorder threshold must not be negative")
+	}
+	if req.Stock != nil && *req.Stock < 0 {
+		return nil, fmt.Errorf("stock must not be negative")
+	}
+	if req.Stock != nil && product.Type == model.ProductTypeBundle && *req.Stock != product.Stock {
+		return nil, errBundleStock
+	}
 
+	// A new name moves the product to a new slug unless one is given; the
+	// old slug keeps working as a redirect
+	var newSlug *string
+	if req.Slug != "" || (req.Name != "" && req.Name != product.Name) {
+		newSlug = &req.Slug
+	}
+
+	if req.SKU != "" {
+		product.SKU = strings.TrimSpace(req.SKU)
+	}
 	if req.Name != "" {
 		product.Name = req.Name
 	}
@@ -67,22 +264,322 @@ func (s *productService) Update(id uuid.UUID, req *model.ProductUpdateRequest) (
 		product.Description = req.Description
 	}
 	if req.Price > 0 {
-		product.Price = req.Price
-	}
-	if req.Stock >= 0 {
-		product.Stock = req.Stock
+		product.BasePrice = req.Price
 	}
 	if req.ImageURL != "" {
 		product.ImageURL = req.ImageURL
 	}
+	if req.Status != "" {
+		product.Status = req.Status
+	}
+	if req.ReorderThreshold != nil {
+		product.ReorderThreshold = *req.ReorderThreshold
+	}
+
+	var attributes []model.AttributeValue
+	var removed []uuid.UUID
+	if len(req.Attributes) > 0 {
+		definitions, err := s.attributeRepo.GetDefinitions(product.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		if attributes, removed, err = resolveAttributes(definitions, req.Attributes, false); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.save(product, newSlug, attributes, removed, req.Stock, actorID)
+}
+
+// Patch applies an RFC 7396 merge patch to a product. Absent fields are
+// left alone and null clears optional ones; the merged product is validated
+// as a whole before anything is saved. Moving the product to another
+// category drops attribute values the new category does not define, unless
+// the patch sets them. version works as for Update.
+func (s *productService) Patch(id uuid.UUID, version int, patch []byte, actorID uuid.UUID) (*model.Product, error) {
+	product, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && product.Version != version {
+		return nil, model.ErrVersionMismatch
+	}
+
+	current := model.ProductPatchDocument{
+		SKU:              product.SKU,
+		Name:             product.Name,
+		Slug:             product.Slug,
+		Description:      product.Description,
+		Price:            product.BasePrice,
+		Stock:            product.Stock,
+		CategoryID:       product.CategoryID,
+		ImageURL:         product.ImageURL,
+		Status:           product.Status,
+		ReorderThreshold: product.ReorderThreshold,
+		Attributes:       product.Attributes,
+	}
+
+	var doc model.ProductPatchDocument
+	changes, err := applyMergePatch(current, patch, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := rejectNulls(changes, "name", "price", "stock", "category_id", "status", "reorder_threshold"); err != nil {
+		return nil, err
+	}
+
+	doc.SKU = strings.TrimSpace(doc.SKU)
+	doc.Name = strings.TrimSpace(doc.Name)
 
-	if err := s.repo.Update(product); err != nil {
+	var problems []string
+	if doc.Name == "" {
+		problems = append(problems, "name is required")
+	}
+	if doc.Price <= 0 {
+		problems = append(problems, "price must be greater than 0")
+	}
+	if doc.Stock < 0 {
+		problems = append(problems, "stock must not be negative")
+	} else if product.Type == model.ProductTypeBundle && doc.Stock != product.Stock {
+		problems = append(problems, errBundleStock.Error())
+	}
+	if !doc.Status.IsValid() {
+		problems = append(problems, fmt.Sprintf("invalid product status: %s", doc.Status))
+	}
+	if doc.ReorderThreshold < 0 {
+		problems = append(problems, "reorder threshold must not be negative")
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	categoryChanged := doc.CategoryID != product.CategoryID
+	if categoryChanged {
+		if _, err := s.categoryRepo.GetByID(doc.CategoryID); err != nil {
+			return nil, err
+		}
+	}
+
+	// An explicit slug is used as given; null, or a rename without one,
+	// generates a new one from the name
+	var newSlug *string
+	slug, slugPatched := changes["slug"]
+	if slugPatched || doc.Name != product.Name {
+		requested := ""
+		if slug != nil {
+			requested = doc.Slug
+		}
+		newSlug = &requested
+	}
+
+	definitions, err := s.attributeRepo.GetDefinitions(doc.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := definitions
+	if categoryChanged {
+		if previous, err = s.attributeRepo.GetDefinitions(product.CategoryID); err != nil {
+			return nil, err
+		}
+
+		known := make(map[string]bool, len(definitions))
+		for _, definition := range definitions {
+			known[definition.Code] = true
+		}
+		patched, _ := changes["attributes"].(map[string]interface{})
+		for code := range doc.Attributes {
+			if _, ok := patched[code]; !ok && !known[code] {
+				delete(doc.Attributes, code)
+			}
+		}
+	}
+
+	attributes, _, err := resolveAttributes(definitions, doc.Attributes, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []uuid.UUID
+	for _, definition := range previous {
+		if _, ok := doc.Attributes[definition.Code]; !ok || categoryChanged {
+			removed = append(removed, definition.ID)
+		}
+	}
+
+	product.SKU = doc.SKU
+	product.Name = doc.Name
+	product.Description = doc.Description
+	product.BasePrice = doc.Price
+	product.CategoryID = doc.CategoryID
+	product.ImageURL = doc.ImageURL
+	product.Status = doc.Status
+	product.ReorderThreshold = doc.ReorderThreshold
+
+	return s.save(product, newSlug, attributes, removed, &doc.Stock, actorID)
+}
+
+// save writes the product with its attribute and stock changes in one
+// transaction. A non-nil newSlug moves the product to that slug, or to one
+// generated from its name when empty. A new stock level is recorded as a
+// manual adjustment; nil leaves stock alone. Bundles have no stock of their
+// own, so stock is ignored for them.
+func (s *productService) save(product *model.Product, newSlug *string, attributes []model.AttributeValue, removed []uuid.UUID, stock *int, actorID uuid.UUID) (*model.Product, error) {
+	changes := repository.ProductChanges{Attributes: attributes, RemovedAttributes: removed}
+
+	// Stock left at the level the client read is not being changed, even if
+	// sales have moved it since
+	if stock != nil && *stock != product.Stock && product.Type != model.ProductTypeBundle {
+		changes.Stock = &repository.StockTarget{
+			Level:    *stock,
+			Movement: model.InventoryMovement{Reason: model.InventoryReasonAdjustment, ActorID: &actorID},
+		}
+	}
+
+	var err error
+	if newSlug != nil {
+		err = saveSlug(*newSlug, product.Name, "product", s.slugExists(product.ID), func(slug string) error {
+			product.Slug = slug
+			return s.repo.Update(product, changes)
+		})
+	} else {
+		err = s.repo.Update(product, changes)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to update product: %w", err)
 	}
 
-	return product, nil
+	// Re-read: a price change can alter the effective price
+	return s.get(product.ID)
+}
+
+// slugExists reports whether a slug is used by a product other than id.
+func (s *productService) slugExists(id uuid.UUID) func(string) (bool, error) {
+	return func(slug string) (bool, error) {
+		return s.repo.SlugExists(slug, id)
+	}
+}
+
+// SetComponents replaces the components of a bundle. version works as for
+// Update.
+func (s *productService) SetComponents(id uuid.UUID, version int, req *model.BundleComponentsRequest) (*model.Product, error) {
+	product, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && product.Version != version {
+		return nil, model.ErrVersionMismatch
+	}
+	if product.Type != model.ProductTypeBundle {
+		return nil, errNotBundle
+	}
+
+	components, err := s.resolveComponents(product.ID, req.Components)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.bundleRepo.SetComponents(product, components); err != nil {
+		return nil, err
+	}
+
+	return s.get(product.ID)
 }
 
+// Delete archives the product rather than removing the row, so that orders
+// referencing it keep their history.
 func (s *productService) Delete(id uuid.UUID) error {
-	return s.repo.Delete(id)
+	return s.repo.UpdateStatus(id, model.ProductStatusArchived)
+}
+
+var (
+	errBundleStock = errors.New("the stock of a bundle is computed from its components")
+	errNotBundle   = errors.New("product is not a bundle")
+)
+
+// get reads a product with its breadcrumbs, and its components if it is a
+// bundle.
+func (s *productService) get(id uuid.UUID) (*model.Product, error) {
+	product, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadDetails(product); err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+func (s *productService) loadDetails(product *model.Product) error {
+	if product.CategoryID != uuid.Nil {
+		breadcrumbs, err := s.categoryRepo.GetPath(product.CategoryID)
+		if err != nil {
+			return err
+		}
+		product.Breadcrumbs = breadcrumbs
+	}
+
+	if product.Type != model.ProductTypeBundle {
+		return nil
+	}
+
+	components, err := s.bundleRepo.GetComponents(product.ID)
+	if err != nil {
+		return err
+	}
+	product.Components = components
+
+	return nil
+}
+
+// resolveComponents validates the components requested for bundleID, which
+// is uuid.Nil for a bundle not yet created. Components must be existing
+// simple products, each listed once with a positive quantity.
+func (s *productService) resolveComponents(bundleID uuid.UUID, inputs []model.BundleComponentInput) ([]model.BundleComponent, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("a bundle needs at least one component")
+	}
+
+	var problems []string
+	seen := make(map[uuid.UUID]bool, len(inputs))
+	components := make([]model.BundleComponent, 0, len(inputs))
+	for _, input := range inputs {
+		if input.Quantity <= 0 {
+			problems = append(problems, fmt.Sprintf("component %s: quantity must be greater than 0", input.ProductID))
+			continue
+		}
+		if seen[input.ProductID] {
+			problems = append(problems, fmt.Sprintf("component %s is listed more than once", input.ProductID))
+			continue
+		}
+		seen[input.ProductID] = true
+
+		if input.ProductID == bundleID {
+			problems = append(problems, "a bundle cannot contain itself")
+			continue
+		}
+
+		component, err := s.repo.GetByID(input.ProductID)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("component %s not found", input.ProductID))
+			continue
+		}
+		if component.Type == model.ProductTypeBundle {
+			problems = append(problems, fmt.Sprintf("component %s is itself a bundle", component.Name))
+			continue
+		}
+
+		components = append(components, model.BundleComponent{
+			ProductID: component.ID,
+			Quantity:  input.Quantity,
+		})
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	return components, nil
 }
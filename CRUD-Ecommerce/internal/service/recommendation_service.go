@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultRelatedLimit = 8
+	maxRelatedLimit     = 24
+)
+
+type RecommendationService interface {
+	GetRelated(productID uuid.UUID, limit int) ([]model.RelatedProduct, error)
+	Recompute() (*model.RecommendationRun, error)
+	StartScheduler(ctx context.Context, interval time.Duration)
+}
+
+type recommendationService struct {
+	repo        repository.RecommendationRepository
+	productRepo repository.ProductRepository
+}
+
+func NewRecommendationService(repo repository.RecommendationRepository, productRepo repository.ProductRepository) RecommendationService {
+	return &recommendationService{
+		repo:        repo,
+		productRepo: productRepo,
+	}
+}
+
+func (s *recommendationService) GetRelated(productID uuid.UUID, limit int) ([]model.RelatedProduct, error) {
+	product, err := s.productRepo.GetByID(productID)
+	if err != nil {
+		return nil, err
+	}
+	if product.Status != model.ProductStatusActive {
+		return nil, fmt.Errorf("product not found")
+	}
+
+	if limit <= 0 {
+		limit = defaultRelatedLimit
+	}
+	if limit > maxRelatedLimit {
+		limit = maxRelatedLimit
+	}
+
+	return s.repo.GetRelated(productID, limit)
+}
+
+// Recompute rebuilds the co-purchase scores. Only one run happens at a
+// time, across all instances; a call made while another run is in progress
+// fails.
+func (s *recommendationService) Recompute() (*model.RecommendationRun, error) {
+	run := &model.RecommendationRun{StartedAt: time.Now()}
+
+	pairs, err := s.repo.Recompute()
+	if err != nil {
+		return nil, err
+	}
+
+	run.Pairs = pairs
+	run.FinishedAt = time.Now()
+	return run, nil
+}
+
+// StartScheduler recomputes recommendations immediately and then every
+// interval in the background until ctx is done. A zero interval disables
+// it.
+func (s *recommendationService) StartScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if run, err := s.Recompute(); err != nil {
+				log.Printf("recommendations: %v", err)
+			} else {
+				log.Printf("recommendations: computed %d product pairs in %s", run.Pairs, run.FinishedAt.Sub(run.StartedAt))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
+	"github.com/google/uuid"
+)
+
+type ReviewService interface {
+	Create(productID, userID uuid.UUID, req *model.ReviewCreateRequest) (*model.Review, error)
+	GetProductReviews(productID uuid.UUID) ([]model.Review, error)
+	GetByStatus(status model.ReviewStatus) ([]model.Review, error)
+	Moderate(id uuid.UUID, status model.ReviewStatus) (*model.Review, error)
+}
+
+type reviewService struct {
+	repo        repository.ReviewRepository
+	productRepo repository.ProductRepository
+}
+
+func NewReviewService(repo repository.ReviewRepository, productRepo repository.ProductRepository) ReviewService {
+	return &reviewService{
+		repo:        repo,
+		productRepo: productRepo,
+	}
+}
+
+func (s *reviewService) Create(productID, userID uuid.UUID, req *model.ReviewCreateRequest) (*model.Review, error) {
+	if req.Rating < 1 || req.Rating > 5 {
+		return nil, fmt.Errorf("rating must be between 1 and 5")
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		return nil, fmt.Errorf("review text is required")
+	}
+
+	if _, err := s.productRepo.GetByID(productID); err != nil {
+		return nil, err
+	}
+
+	purchased, err := s.repo.HasDeliveredPurchase(productID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !purchased {
+		return nil, fmt.Errorf("only customers who received this product can review it")
+	}
+
+	exists, err := s.repo.Exists(productID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("you have already reviewed this product")
+	}
+
+	review := &model.Review{
+		ProductID: productID,
+		UserID:    userID,
+		Rating:    req.Rating,
+		Title:     strings.TrimSpace(req.Title),
+		Body:      strings.TrimSpace(req.Body),
+		Status:    model.ReviewStatusPending,
+	}
+
+	if err := s.repo.Create(review); err != nil {
+		return nil, fmt.Errorf("failed to create review: %w", err)
+	}
+
+	return review, nil
+}
+
+func (s *reviewService) GetProductReviews(productID uuid.UUID) ([]model.Review, error) {
+	return s.repo.GetByProduct(productID, model.ReviewStatusApproved)
+}
+
+func (s *reviewService) GetByStatus(status model.ReviewStatus) ([]model.Review, error) {
+	if status == "" {
+		status = model.ReviewStatusPending
+	}
+
+	return s.repo.GetByStatus(status)
+}
+
+func (s *reviewService) Moderate(id uuid.UUID, status model.ReviewStatus) (*model.Review, error) {
+	if status != model.ReviewStatusApproved && status != model.ReviewStatusHidden {
+		return nil, fmt.Errorf("invalid review status: %s", status)
+	}
+
+	if err := s.repo.UpdateStatus(id, status); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(id)
+}
@@ -1,8 +1,17 @@
 package service
 
 type Services struct {
-	User     UserService
-	Product  ProductService
-	Category CategoryService
-	Order    OrderService
+	User           UserService
+	Product        ProductService
+	Category       CategoryService
+	Order          OrderService
+	Review         ReviewService
+	Catalog        CatalogService
+	Inventory      InventoryService
+	Warehouse      WarehouseService
+	Notification   NotificationService
+	Price          PriceService
+	Attribute      AttributeService
+	Recommendation RecommendationService
+	Wishlist       WishlistService
 }
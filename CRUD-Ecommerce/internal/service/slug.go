@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+)
+
+const (
+	// maxSlugLength leaves room in the column for a numeric suffix.
+	maxSlugLength = 200
+
+	// maxSlugAttempts bounds how often a generated slug is picked again
+	// after losing it to a concurrent write.
+	maxSlugAttempts = 5
+)
+
+var (
+	slugPattern   = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	slugSeparator = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// slugify turns a name into a URL-safe slug: lowercase ASCII letters and
+// digits separated by single hyphens. Returns "" when nothing is left.
+func slugify(name string) string {
+	slug := strings.Trim(slugSeparator.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	return slug
+}
+
+// chooseSlug returns the slug to store for a record. An explicitly requested
+// slug must be well-formed and free; otherwise one is generated from the
+// name, numbering it (-2, -3, ...) until it no longer clashes. exists
+// reports whether a slug is used by a different record.
+func chooseSlug(requested, name, fallback string, exists func(string) (bool, error)) (string, error) {
+	if requested = strings.TrimSpace(requested); requested != "" {
+		if !slugPattern.MatchString(requested) || len(requested) > maxSlugLength {
+			return "", fmt.Errorf("invalid slug: use lowercase letters, digits and single hyphens")
+		}
+		taken, err := exists(requested)
+		if err != nil {
+			return "", err
+		}
+		if taken {
+			return "", fmt.Errorf("slug %q is already in use", requested)
+		}
+		return requested, nil
+	}
+
+	base := slugify(name)
+	if base == "" {
+		base = fallback
+	}
+
+	slug := base
+	for n := 2; ; n++ {
+		taken, err := exists(slug)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// saveSlug picks a slug with chooseSlug and calls save with it. A generated
+// slug can still be claimed by a concurrent write between the check and the
+// save; the repository then reports model.ErrSlugTaken and the slug is
+// picked again, which moves on to the next free suffix. A requested slug is
+// never replaced.
+func saveSlug(requested, name, fallback string, exists func(string) (bool, error), save func(slug string) error) error {
+	for attempt := 1; ; attempt++ {
+		slug, err := chooseSlug(requested, name, fallback, exists)
+		if err != nil {
+			return err
+		}
+
+		err = save(slug)
+		if !errors.Is(err, model.ErrSlugTaken) || strings.TrimSpace(requested) != "" || attempt == maxSlugAttempts {
+			return err
+		}
+	}
+}
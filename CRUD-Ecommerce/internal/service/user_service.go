@@ -1,11 +1,17 @@
 package service
 
 import (
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
+	"github.com/ekas-7/CRUD-Ecommerce/internal/mailer"
 	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
 	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/totp"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -13,24 +19,110 @@ import (
 
 type UserService interface {
 	Register(req *model.UserRegisterRequest) (*model.UserResponse, error)
-	Login(req *model.UserLoginRequest) (*model.LoginResponse, error)
+	VerifyEmail(token string) (*model.UserResponse, error)
+	ResendVerification(email string)
+	ForgotPassword(email string)
+	ResetPassword(req *model.ResetPasswordRequest) error
+	ChangePassword(userID uuid.UUID, req *model.ChangePasswordRequest) error
+	Login(req *model.UserLoginRequest, client model.ClientInfo) (*model.LoginResponse, *model.MFAChallenge, error)
+	CompleteMFALogin(req *model.MFALoginRequest, client model.ClientInfo) (*model.LoginResponse, error)
+	SetupMFA(userID uuid.UUID) (*model.MFASetupResponse, error)
+	EnableMFA(userID, sessionID uuid.UUID, code string) (*model.MFARecoveryCodesResponse, error)
+	DisableMFA(userID uuid.UUID, req *model.MFADisableRequest) error
+	RegenerateRecoveryCodes(userID uuid.UUID, code string) (*model.MFARecoveryCodesResponse, error)
+	GetLoginHistory(userID uuid.UUID, limit int) ([]model.LoginAttempt, error)
+	GetLockedAccounts() ([]model.LockedAccount, error)
+	UnlockAccount(userID uuid.UUID) error
+	GetRoles() ([]model.Role, error)
+	AssignRole(actorID, userID uuid.UUID, role string) (*model.UserResponse, error)
+	Refresh(refreshToken string) (*model.LoginResponse, error)
+	Logout(refreshToken string) error
+	IsSessionActive(sessionID uuid.UUID) (bool, error)
 	GetProfile(userID uuid.UUID) (*model.UserResponse, error)
 	UpdateProfile(userID uuid.UUID, req *model.UserUpdateRequest) (*model.UserResponse, error)
+	PatchProfile(userID uuid.UUID, patch []byte) (*model.UserResponse, error)
 	DeleteAccount(userID uuid.UUID) error
 	ValidateToken(tokenString string) (uuid.UUID, string, error)
 }
 
+const (
+	// emailVerificationTTL is how long the link in a verification email works.
+	emailVerificationTTL = 48 * time.Hour
+	// passwordResetTTL is how long the link in a password reset email works.
+	passwordResetTTL  = time.Hour
+	minPasswordLength = 6
+	// emailCooldown is how long an address waits between two emails of the
+	// same kind, so the endpoints cannot be used to flood an inbox.
+	emailCooldown = time.Minute
+
+	// mfaChallengeTTL is how long the second step of an MFA login may take.
+	mfaChallengeTTL = 5 * time.Minute
+	// maxMFAAttempts is how many wrong codes a challenge allows before the
+	// password has to be entered again, and how many in a row enrolling or
+	// managing MFA allows before the user has to start over.
+	maxMFAAttempts = 5
+	// mfaSkew is how many 30 second steps of clock drift codes may have.
+	mfaSkew           = 1
+	recoveryCodeCount = 10
+
+	// Fewer than freeLoginFailures failed logins in a row do not lock the
+	// account; from then on each one locks it for a second, then two, four
+	// and so on.
+	freeLoginFailures = 3
+
+	defaultLoginHistoryLimit = 20
+	maxLoginHistoryLimit     = 100
+)
+
+// LoginLimits configures how failed logins are throttled. A zero
+// MaxFailures or IPMaxFailures turns that limit off. An account's failures
+// only add up while each comes within FailureWindow of the one before.
+type LoginLimits struct {
+	MaxFailures     int
+	LockoutDuration time.Duration
+	IPMaxFailures   int
+	IPWindow        time.Duration
+	FailureWindow   time.Duration
+}
+
+var errInvalidMFACode = errors.New("invalid authentication code")
+
 type userService struct {
-	repo      repository.UserRepository
-	jwtSecret string
-	jwtExpiry time.Duration
+	repo          repository.UserRepository
+	sessionRepo   repository.SessionRepository
+	tokenRepo     repository.UserTokenRepository
+	mailer        mailer.Mailer
+	baseURL       string
+	jwtSecret     string
+	jwtExpiry     time.Duration
+	refreshExpiry time.Duration
+
+	mfaRepo         repository.MFARepository
+	mfaIssuer       string
+	requireAdminMFA bool
+
+	attemptRepo repository.LoginAttemptRepository
+	limits      LoginLimits
+
+	roleRepo repository.RoleRepository
 }
 
-func NewUserService(repo repository.UserRepository, jwtSecret string, jwtExpiry time.Duration) UserService {
+func NewUserService(repo repository.UserRepository, sessionRepo repository.SessionRepository, tokenRepo repository.UserTokenRepository, mfaRepo repository.MFARepository, attemptRepo repository.LoginAttemptRepository, roleRepo repository.RoleRepository, mail mailer.Mailer, baseURL string, jwtSecret string, jwtExpiry, refreshExpiry time.Duration, mfaIssuer string, requireAdminMFA bool, limits LoginLimits) UserService {
 	return &userService{
-		repo:      repo,
-		jwtSecret: jwtSecret,
-		jwtExpiry: jwtExpiry,
+		repo:            repo,
+		sessionRepo:     sessionRepo,
+		tokenRepo:       tokenRepo,
+		mailer:          mail,
+		baseURL:         strings.TrimRight(baseURL, "/"),
+		jwtSecret:       jwtSecret,
+		jwtExpiry:       jwtExpiry,
+		refreshExpiry:   refreshExpiry,
+		mfaRepo:         mfaRepo,
+		mfaIssuer:       mfaIssuer,
+		requireAdminMFA: requireAdminMFA,
+		attemptRepo:     attemptRepo,
+		limits:          limits,
+		roleRepo:        roleRepo,
 	}
 }
 
@@ -53,39 +145,709 @@ func (s *userService) Register(req *model.UserRegisterRequest) (*model.UserRespo
 		Password:  string(hashedPassword),
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
-		Role:      "user",
+		Role:      model.RoleUser,
 	}
 
 	if err := s.repo.Create(user); err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
+	// The account exists either way; a failed email can be resent
+	if err := s.sendVerificationEmail(user); err != nil {
+		log.Printf("user %s: verification email not sent: %v", user.ID, err)
+	}
+
 	response := user.ToResponse()
 	return &response, nil
 }
 
-func (s *userService) Login(req *model.UserLoginRequest) (*model.LoginResponse, error) {
-	// Get user by email
-	user, err := s.repo.GetByEmail(req.Email)
+// VerifyEmail confirms the address of the user the token was sent to.
+func (s *userService) VerifyEmail(token string) (*model.UserResponse, error) {
+	userID, err := s.tokenRepo.Consume(hashToken(token), model.UserTokenVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.MarkEmailVerified(userID); err != nil {
+		return nil, err
+	}
+
+	return s.GetProfile(userID)
+}
+
+// ResendVerification sends a fresh verification email, invalidating the
+// previous link, unless one was sent within emailCooldown. Unknown and
+// already verified addresses are ignored and failures are only logged, so
+// the endpoint does not reveal which emails have accounts.
+func (s *userService) ResendVerification(email string) {
+	user, err := s.repo.GetByEmail(email)
+	if err != nil || user.EmailVerifiedAt != nil {
+		return
+	}
+
+	err = s.sendVerificationEmail(user)
+	if err != nil && !errors.Is(err, model.ErrTokenRecentlyIssued) {
+		log.Printf("user %s: verification email not sent: %v", user.ID, err)
+	}
+}
+
+// ForgotPassword emails a password reset link, unless one was sent within
+// emailCooldown. As with ResendVerification, unknown addresses are ignored
+// and failures are only logged.
+func (s *userService) ForgotPassword(email string) {
+	user, err := s.repo.GetByEmail(email)
+	if err != nil {
+		return
+	}
+
+	err = s.sendPasswordResetEmail(user)
+	if err != nil && !errors.Is(err, model.ErrTokenRecentlyIssued) {
+		log.Printf("user %s: password reset email not sent: %v", user.ID, err)
+	}
+}
+
+func (s *userService) sendPasswordResetEmail(user *model.User) error {
+	token, err := generateSecretToken()
+	if err != nil {
+		return err
+	}
+
+	if err := s.tokenRepo.Create(user.ID, model.UserTokenResetPassword, hashToken(token), time.Now().Add(passwordResetTTL), emailCooldown); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"We received a request to reset your password. Choose a new one by opening this link:\n\n"+
+			"%s/reset-password?token=%s\n\n"+
+			"The link expires in %d minutes and works once. If you did not ask for this, you can ignore this email; your password has not changed.\n",
+			user.FirstName, s.baseURL, token, int(passwordResetTTL.Minutes())),
+	})
+}
+
+// ResetPassword sets a new password with a token from a reset email and
+// signs the user out of every session. Following the emailed link also
+// proves the address, so it is marked verified, and lifts any lock left by
+// failed logins. All of it happens in one transaction, so a failure leaves
+// the token unused.
+func (s *userService) ResetPassword(req *model.ResetPasswordRequest) error {
+	if err := validatePassword(req.NewPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return s.repo.ResetPassword(hashToken(req.Token), string(hashedPassword))
+}
+
+// ChangePassword replaces the password of a signed-in user who knows the
+// current one, and signs them out of every session, including this one.
+func (s *userService) ChangePassword(userID uuid.UUID, req *model.ChangePasswordRequest) error {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		return fmt.Errorf("current password is incorrect")
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		return err
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return fmt.Errorf("new password must be different from the current password")
+	}
+
+	return s.setPassword(userID, req.NewPassword)
+}
+
+// setPassword stores a new password and revokes all of the user's
+// sessions, so tokens obtained with the old password stop working.
+func (s *userService) setPassword(userID uuid.UUID, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return s.repo.UpdatePassword(userID, string(hashedPassword))
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	return nil
+}
+
+func (s *userService) sendVerificationEmail(user *model.User) error {
+	token, err := generateSecretToken()
 	if err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		return err
+	}
+
+	if err := s.tokenRepo.Create(user.ID, model.UserTokenVerifyEmail, hashToken(token), time.Now().Add(emailVerificationTTL), emailCooldown); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm your email address by opening this link:\n\n"+
+			"%s/verify-email?token=%s\n\n"+
+			"The link expires in %d hours. If you did not create an account, you can ignore this email.\n",
+			user.FirstName, s.baseURL, token, int(emailVerificationTTL.Hours())),
+	})
+}
+
+// Login checks the user's password. Users without MFA get a session right
+// away; users with MFA get a challenge to complete with CompleteMFALogin.
+// Clients and accounts with too many failed logins get a
+// LoginThrottledError without the password being checked.
+func (s *userService) Login(req *model.UserLoginRequest, client model.ClientInfo) (*model.LoginResponse, *model.MFAChallenge, error) {
+	// Get user by email; nil for unknown emails
+	user, _ := s.repo.GetByEmail(req.Email)
+
+	if err := s.checkIPThrottle(client.IPAddress); err != nil {
+		s.recordAttempt(user, req.Email, client, model.LoginResultThrottled)
+		return nil, nil, err
+	}
+
+	if user == nil {
+		s.recordAttempt(nil, req.Email, client, model.LoginResultUnknownAccount)
+		return nil, nil, fmt.Errorf("invalid credentials")
+	}
+
+	if err := checkAccountLock(user); err != nil {
+		s.recordAttempt(user, user.Email, client, model.LoginResultLocked)
+		return nil, nil, err
 	}
 
 	// Compare password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		if err := s.recordFailedLogin(user, client, model.LoginResultBadPassword); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("invalid credentials")
+	}
+
+	if user.MFAEnabledAt != nil {
+		token, err := generateSecretToken()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := s.tokenRepo.Create(user.ID, model.UserTokenMFAChallenge, hashToken(token), time.Now().Add(mfaChallengeTTL), 0); err != nil {
+			return nil, nil, err
+		}
+
+		return nil, &model.MFAChallenge{
+			MFARequired: true,
+			MFAToken:    token,
+			ExpiresIn:   int(mfaChallengeTTL.Seconds()),
+		}, nil
+	}
+
+	response, err := s.startSession(user, client, false)
+	return response, nil, err
+}
+
+// CompleteMFALogin finishes a login with the challenge token from Login
+// and a code from the user's authenticator app or a recovery code.
+func (s *userService) CompleteMFALogin(req *model.MFALoginRequest, client model.ClientInfo) (*model.LoginResponse, error) {
+	challenge := hashToken(req.MFAToken)
+
+	userID, err := s.tokenRepo.Find(challenge, model.UserTokenMFAChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired token")
+	}
+
+	if err := s.checkIPThrottle(client.IPAddress); err != nil {
+		s.recordAttempt(user, user.Email, client, model.LoginResultThrottled)
+		return nil, err
+	}
+	if err := checkAccountLock(user); err != nil {
+		s.recordAttempt(user, user.Email, client, model.LoginResultLocked)
+		return nil, err
+	}
+
+	ok, err := s.checkSecondFactor(user, req.Code, true)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := s.tokenRepo.RecordFailure(challenge, model.UserTokenMFAChallenge, maxMFAAttempts); err != nil {
+			return nil, err
+		}
+		if err := s.recordFailedLogin(user, client, model.LoginResultBadMFACode); err != nil {
+			return nil, err
+		}
+		return nil, errInvalidMFACode
+	}
+
+	if _, err := s.tokenRepo.Consume(challenge, model.UserTokenMFAChallenge); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, client, true)
+}
+
+// startSession signs the user in on a new session once all login steps
+// have passed; its first refresh token goes back with the access token.
+func (s *userService) startSession(user *model.User, client model.ClientInfo, mfa bool) (*model.LoginResponse, error) {
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := s.repo.ClearFailedLogins(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	s.recordAttempt(user, user.Email, client, model.LoginResultSuccess)
+
+	refreshToken, err := generateSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &model.Session{
+		UserID:    user.ID,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+		MFA:       mfa,
+	}
+	if err := s.sessionRepo.Create(session, hashToken(refreshToken), time.Now().Add(s.refreshExpiry)); err != nil {
+		return nil, err
+	}
+
+	return s.loginResponse(user, session, refreshToken)
+}
+
+// checkIPThrottle refuses logins from an address with too many failed
+// logins within the window, until the oldest of them leaves it.
+func (s *userService) checkIPThrottle(ipAddress string) error {
+	if s.limits.IPMaxFailures <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	failures, oldest, err := s.attemptRepo.GetFailuresByIP(ipAddress, now.Add(-s.limits.IPWindow))
+	if err != nil {
+		return err
+	}
+
+	if failures >= s.limits.IPMaxFailures {
+		return &model.LoginThrottledError{RetryAfter: oldest.Add(s.limits.IPWindow).Sub(now)}
+	}
+
+	return nil
+}
+
+// checkAccountLock refuses logins to an account locked by failed logins.
+func checkAccountLock(user *model.User) error {
+	if user.LockedUntil == nil {
+		return nil
+	}
+
+	if wait := time.Until(*user.LockedUntil); wait > 0 {
+		return &model.LoginThrottledError{RetryAfter: wait}
+	}
+
+	return nil
+}
+
+// recordFailedLogin records a wrong password or code and locks the account
+// for as long as lockoutFor says.
+func (s *userService) recordFailedLogin(user *model.User, client model.ClientInfo, result model.LoginResult) error {
+	s.recordAttempt(user, user.Email, client, result)
+
+	failures, err := s.repo.RecordFailedLogin(user.ID, s.limits.FailureWindow)
+	if err != nil {
+		return err
+	}
+
+	if lock := s.lockoutFor(failures); lock > 0 {
+		return s.repo.LockUntil(user.ID, time.Now().Add(lock))
+	}
+
+	return nil
+}
+
+// lockoutFor is how long an account is locked after the given number of
+// failed logins in a row: not at all for the first few, then for a delay
+// that doubles with each failure, and for LockoutDuration once MaxFailures
+// is reached.
+func (s *userService) lockoutFor(failures int) time.Duration {
+	if s.limits.MaxFailures <= 0 {
+		return 0
+	}
+	if failures >= s.limits.MaxFailures {
+		return s.limits.LockoutDuration
+	}
+	if failures < freeLoginFailures {
+		return 0
+	}
+
+	delay := time.Second << uint(failures-freeLoginFailures)
+	if delay > s.limits.LockoutDuration {
+		delay = s.limits.LockoutDuration
+	}
+	return delay
+}
+
+// recordAttempt adds a login attempt to the history. The history is not
+// essential to signing in, so failing to write it is only logged.
+func (s *userService) recordAttempt(user *model.User, email string, client model.ClientInfo, result model.LoginResult) {
+	attempt := &model.LoginAttempt{
+		Email:     email,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Result:    result,
+	}
+	if user != nil {
+		attempt.UserID = &user.ID
+	}
+
+	if err := s.attemptRepo.Create(attempt); err != nil {
+		log.Printf("login attempt for %s not recorded: %v", email, err)
+	}
+}
+
+// GetLoginHistory returns the user's most recent login attempts.
+func (s *userService) GetLoginHistory(userID uuid.UUID, limit int) ([]model.LoginAttempt, error) {
+	if limit <= 0 {
+		limit = defaultLoginHistoryLimit
+	}
+	if limit > maxLoginHistoryLimit {
+		limit = maxLoginHistoryLimit
+	}
+
+	return s.attemptRepo.GetByUserID(userID, limit)
+}
+
+// GetLockedAccounts lists the accounts locked out by failed logins.
+func (s *userService) GetLockedAccounts() ([]model.LockedAccount, error) {
+	users, err := s.repo.GetLocked()
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]model.LockedAccount, 0, len(users))
+	for _, user := range users {
+		accounts = append(accounts, model.LockedAccount{
+			User:         user.ToResponse(),
+			FailedLogins: user.FailedLogins,
+			LockedUntil:  *user.LockedUntil,
+		})
+	}
+
+	return accounts, nil
+}
+
+// UnlockAccount lifts a lock left by failed logins and resets the count.
+func (s *userService) UnlockAccount(userID uuid.UUID) error {
+	return s.repo.ClearFailedLogins(userID)
+}
+
+// GetRoles lists the roles users can be given, with their permissions.
+func (s *userService) GetRoles() ([]model.Role, error) {
+	return s.roleRepo.GetAll()
+}
+
+// AssignRole gives a user a different role. The user's sessions are
+// revoked so that their tokens stop carrying the old permissions at once.
+// Staff cannot change their own role, so the last admin cannot demote
+// themselves by accident.
+func (s *userService) AssignRole(actorID, userID uuid.UUID, role string) (*model.UserResponse, error) {
+	if actorID == userID {
+		return nil, fmt.Errorf("cannot change your own role")
+	}
+
+	role = strings.TrimSpace(role)
+	if _, err := s.roleRepo.GetByName(role); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if user.Role == role {
+		response := user.ToResponse()
+		return &response, nil
+	}
+
+	if err := s.repo.UpdateRole(userID, role); err != nil {
+		return nil, err
+	}
+
+	if err := s.sessionRepo.RevokeAllForUser(userID); err != nil {
+		return nil, err
+	}
+
+	return s.GetProfile(userID)
+}
+
+// SetupMFA starts MFA enrolment with a new secret. MFA is not enabled
+// until EnableMFA confirms the secret with a code.
+func (s *userService) SetupMFA(userID uuid.UUID) (*model.MFASetupResponse, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if user.MFAEnabledAt != nil {
+		return nil, fmt.Errorf("MFA is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.SetSecret(userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &model.MFASetupResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(s.mfaIssuer, user.Email, secret),
+	}, nil
+}
+
+// EnableMFA turns on MFA once the user proves their app has the secret,
+// and returns the first recovery codes. The session the request came from
+// counts as signed in with MFA from then on.
+func (s *userService) EnableMFA(userID, sessionID uuid.UUID, code string) (*model.MFARecoveryCodesResponse, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if user.MFAEnabledAt != nil {
+		return nil, fmt.Errorf("MFA is already enabled")
+	}
+	if user.MFASecret == "" {
+		return nil, fmt.Errorf("start MFA setup first")
+	}
+
+	ok, err := s.checkSecondFactor(user, code, false)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, s.rejectMFACode(user)
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.Enable(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	if err := s.sessionRepo.MarkMFA(sessionID); err != nil {
+		return nil, err
+	}
+
+	return &model.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableMFA turns off MFA. It needs both the password and a code, so a
+// stolen session alone cannot remove the second factor.
+func (s *userService) DisableMFA(userID uuid.UUID, req *model.MFADisableRequest) error {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+	if user.MFAEnabledAt == nil {
+		return fmt.Errorf("MFA is not enabled")
+	}
+	if s.requireAdminMFA {
+		permissions, err := s.roleRepo.GetPermissions(user.Role)
+		if err != nil {
+			return err
+		}
+		if len(permissions) > 0 {
+			return fmt.Errorf("MFA is required for staff accounts")
+		}
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return fmt.Errorf("password is incorrect")
+	}
+
+	ok, err := s.checkSecondFactor(user, req.Code, true)
+	if err != nil {
+		return err
 	}
+	if !ok {
+		return s.rejectMFACode(user)
+	}
+
+	return s.mfaRepo.Disable(userID)
+}
 
-	// Generate JWT token
-	token, err := s.generateToken(user.ID, user.Role)
+// RegenerateRecoveryCodes replaces all of the user's recovery codes. It
+// takes a code from the authenticator app rather than a recovery code.
+func (s *userService) RegenerateRecoveryCodes(userID uuid.UUID, code string) (*model.MFARecoveryCodesResponse, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if user.MFAEnabledAt == nil {
+		return nil, fmt.Errorf("MFA is not enabled")
+	}
+
+	ok, err := s.checkSecondFactor(user, code, false)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, s.rejectMFACode(user)
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return &model.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// checkSecondFactor reports whether code is a current TOTP code for the
+// user's secret or, when allowRecovery is set, one of their unused
+// recovery codes. Either is used up by a successful check.
+func (s *userService) checkSecondFactor(user *model.User, code string, allowRecovery bool) (bool, error) {
+	code = normalizeMFACode(code)
+	if user.MFASecret == "" || code == "" {
+		return false, nil
+	}
+
+	if step, ok := totp.Validate(user.MFASecret, code, time.Now(), mfaSkew); ok {
+		return s.mfaRepo.UseStep(user.ID, step)
+	}
+
+	if allowRecovery && user.MFAEnabledAt != nil {
+		return s.mfaRepo.UseRecoveryCode(user.ID, hashToken(code))
+	}
+
+	return false, nil
+}
+
+// rejectMFACode counts a wrong code given while enrolling or managing MFA.
+// After maxMFAAttempts in a row the user starts over: enrolment needs a new
+// secret, and with MFA enabled every session has been signed out.
+func (s *userService) rejectMFACode(user *model.User) error {
+	exhausted, err := s.mfaRepo.RecordFailure(user.ID, maxMFAAttempts)
+	if err != nil {
+		return err
+	}
+	if !exhausted {
+		return errInvalidMFACode
+	}
+	if user.MFAEnabledAt == nil {
+		return fmt.Errorf("too many invalid codes; start MFA setup again")
+	}
+	return fmt.Errorf("too many invalid codes; sign in again")
+}
+
+// recoveryCodeAlphabet leaves out characters that are easily confused. It
+// has 32 characters, so mapping random bytes onto it is unbiased.
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz023456789"
+
+// generateRecoveryCodes returns new recovery codes, formatted for display
+// as "xxxxx-xxxxx", and the hashes they are stored as.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	b := make([]byte, 10)
+	for i := range codes {
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+		}
+		for j := range b {
+			b[j] = recoveryCodeAlphabet[int(b[j])%len(recoveryCodeAlphabet)]
+		}
+
+		code := string(b)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashToken(code)
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeMFACode accepts codes typed with spaces, dashes or capitals.
+func normalizeMFACode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new
+// refresh token. Each refresh token works once; reusing one revokes the
+// session it belongs to.
+func (s *userService) Refresh(refreshToken string) (*model.LoginResponse, error) {
+	next, err := generateSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.sessionRepo.Rotate(hashToken(refreshToken), hashToken(next), time.Now().Add(s.refreshExpiry))
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetByID(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+
+	return s.loginResponse(user, session, next)
+}
+
+// Logout revokes the session the refresh token belongs to, along with any
+// access tokens issued for it.
+func (s *userService) Logout(refreshToken string) error {
+	return s.sessionRepo.RevokeByToken(hashToken(refreshToken))
+}
+
+func (s *userService) IsSessionActive(sessionID uuid.UUID) (bool, error) {
+	return s.sessionRepo.IsActive(sessionID)
+}
+
+// loginResponse issues an access token carrying the permissions of the
+// user's role. They are read afresh on every refresh, so role changes
+// reach existing sessions within one access token lifetime.
+func (s *userService) loginResponse(user *model.User, session *model.Session, refreshToken string) (*model.LoginResponse, error) {
+	permissions, err := s.roleRepo.GetPermissions(user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.generateToken(user.ID, user.Role, permissions, session.ID, session.MFA)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
-	userResponse := user.ToResponse()
 	return &model.LoginResponse{
-		Token: token,
-		User:  userResponse,
+		Token:            token,
+		ExpiresIn:        int(s.jwtExpiry.Seconds()),
+		RefreshToken:     refreshToken,
+		User:             user.ToResponse(),
+		Permissions:      permissions,
+		MFASetupRequired: s.requireAdminMFA && len(permissions) > 0 && user.MFAEnabledAt == nil,
 	}, nil
 }
 
@@ -120,14 +882,52 @@ func (s *userService) UpdateProfile(userID uuid.UUID, req *model.UserUpdateReque
 	return &response, nil
 }
 
+// PatchProfile applies an RFC 7396 merge patch to the user's profile.
+func (s *userService) PatchProfile(userID uuid.UUID, patch []byte) (*model.UserResponse, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	current := model.UserPatchDocument{
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+	}
+
+	var doc model.UserPatchDocument
+	changes, err := applyMergePatch(current, patch, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := rejectNulls(changes, "first_name", "last_name"); err != nil {
+		return nil, err
+	}
+
+	user.FirstName = strings.TrimSpace(doc.FirstName)
+	user.LastName = strings.TrimSpace(doc.LastName)
+	if user.FirstName == "" || user.LastName == "" {
+		return nil, fmt.Errorf("first name and last name are required")
+	}
+
+	if err := s.repo.Update(user); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	response := user.ToResponse()
+	return &response, nil
+}
+
 func (s *userService) DeleteAccount(userID uuid.UUID) error {
 	return s.repo.Delete(userID)
 }
 
-func (s *userService) generateToken(userID uuid.UUID, role string) (string, error) {
+func (s *userService) generateToken(userID uuid.UUID, role string, permissions []model.Permission, sessionID uuid.UUID, mfa bool) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID.String(),
 		"role":    role,
+		"perms":   permissions,
+		"sid":     sessionID.String(),
+		"mfa":     mfa,
 		"exp":     time.Now().Add(s.jwtExpiry).Unix(),
 	}
 
@@ -160,7 +960,7 @@ func (s *userService) ValidateToken(tokenString string) (uuid.UUID, string, erro
 
 		role, ok := claims["role"].(string)
 		if !ok {
-			role = "user"
+			role = model.RoleUser
 		}
 
 		return userID, role, nil
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "correct horse"
+
+// fakeUserRepo keeps one account's failed login state the way the users
+// table does. Methods the login path does not use are left to the embedded
+// nil interface.
+type fakeUserRepo struct {
+	repository.UserRepository
+
+	user          model.User
+	lastFailureAt *time.Time
+	window        time.Duration // as passed to the last RecordFailedLogin
+}
+
+func (r *fakeUserRepo) GetByEmail(email string) (*model.User, error) {
+	if email != r.user.Email {
+		return nil, fmt.Errorf("user not found")
+	}
+	user := r.user
+	return &user, nil
+}
+
+func (r *fakeUserRepo) RecordFailedLogin(id uuid.UUID, window time.Duration) (int, error) {
+	now := time.Now()
+	r.window = window
+	if r.lastFailureAt == nil || r.lastFailureAt.Before(now.Add(-window)) {
+		r.user.FailedLogins = 1
+	} else {
+		r.user.FailedLogins++
+	}
+	r.lastFailureAt = &now
+	return r.user.FailedLogins, nil
+}
+
+func (r *fakeUserRepo) LockUntil(id uuid.UUID, until time.Time) error {
+	if r.user.LockedUntil == nil || until.After(*r.user.LockedUntil) {
+		r.user.LockedUntil = &until
+	}
+	return nil
+}
+
+// fakeAttemptRepo keeps the login history in memory.
+type fakeAttemptRepo struct {
+	repository.LoginAttemptRepository
+
+	attempts []model.LoginAttempt
+}
+
+func (r *fakeAttemptRepo) Create(attempt *model.LoginAttempt) error {
+	attempt.CreatedAt = time.Now()
+	r.attempts = append(r.attempts, *attempt)
+	return nil
+}
+
+func (r *fakeAttemptRepo) GetFailuresByIP(ipAddress string, since time.Time) (int, time.Time, error) {
+	count := 0
+	oldest := time.Now()
+	for _, attempt := range r.attempts {
+		switch attempt.Result {
+		case model.LoginResultBadPassword, model.LoginResultBadMFACode, model.LoginResultUnknownAccount:
+		default:
+			continue
+		}
+		if attempt.IPAddress != ipAddress || !attempt.CreatedAt.After(since) {
+			continue
+		}
+		count++
+		if attempt.CreatedAt.Before(oldest) {
+			oldest = attempt.CreatedAt
+		}
+	}
+	return count, oldest, nil
+}
+
+var testLimits = LoginLimits{
+	MaxFailures:     10,
+	LockoutDuration: 15 * time.Minute,
+	IPMaxFailures:   50,
+	IPWindow:        15 * time.Minute,
+	FailureWindow:   time.Hour,
+}
+
+func newLoginTestService(t *testing.T, limits LoginLimits) (*userService, *fakeUserRepo, *fakeAttemptRepo) {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+
+	users := &fakeUserRepo{user: model.User{ID: uuid.New(), Email: "user@example.com", Password: string(hash)}}
+	attempts := &fakeAttemptRepo{}
+	service := &userService{repo: users, attemptRepo: attempts, limits: limits}
+	return service, users, attempts
+}
+
+func loginAs(service *userService, email, password string) error {
+	_, _, err := service.Login(
+		&model.UserLoginRequest{Email: email, Password: password},
+		model.ClientInfo{IPAddress: "192.0.2.1", UserAgent: "test"},
+	)
+	return err
+}
+
+func TestLockoutFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   LoginLimits
+		failures int
+		want     time.Duration
+	}{
+		{"first failure is free", testLimits, 1, 0},
+		{"last free failure", testLimits, freeLoginFailures - 1, 0},
+		{"first delayed failure", testLimits, freeLoginFailures, time.Second},
+		{"delay doubles", testLimits, freeLoginFailures + 1, 2 * time.Second},
+		{"delay keeps doubling", testLimits, freeLoginFailures + 3, 8 * time.Second},
+		{"one short of the limit", testLimits, 9, 64 * time.Second},
+		{"at the limit", testLimits, 10, 15 * time.Minute},
+		{"past the limit", testLimits, 25, 15 * time.Minute},
+		{"delay capped by the lockout", LoginLimits{MaxFailures: 10, LockoutDuration: 3 * time.Second}, 6, 3 * time.Second},
+		{"limit turned off", LoginLimits{LockoutDuration: 15 * time.Minute}, 25, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &userService{limits: tt.limits}
+			if got := service.lockoutFor(tt.failures); got != tt.want {
+				t.Errorf("lockoutFor(%d) = %s, want %s", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginLocksAccountAfterFailures(t *testing.T) {
+	tests := []struct {
+		name          string
+		limits        LoginLimits
+		failures      int           // failed logins before this one
+		lastFailedAgo time.Duration // how long ago the last of them was
+		wantFailures  int
+		wantLock      time.Duration
+	}{
+		{"first failure", testLimits, 0, 0, 1, 0},
+		{"free failure", testLimits, freeLoginFailures - 2, time.Minute, freeLoginFailures - 1, 0},
+		{"first delayed failure", testLimits, freeLoginFailures - 1, time.Minute, freeLoginFailures, time.Second},
+		{"progressive delay", testLimits, 5, time.Minute, 6, 8 * time.Second},
+		{"reaching the limit", testLimits, 9, time.Minute, 10, 15 * time.Minute},
+		{"failures just inside the window", testLimits, 9, 59 * time.Minute, 10, 15 * time.Minute},
+		{"failures expired", testLimits, 9, 2 * time.Hour, 1, 0},
+		{"limit turned off", LoginLimits{LockoutDuration: 15 * time.Minute, FailureWindow: time.Hour}, 20, time.Minute, 21, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, _ := newLoginTestService(t, tt.limits)
+			users.user.FailedLogins = tt.failures
+			if tt.failures > 0 {
+				last := time.Now().Add(-tt.lastFailedAgo)
+				users.lastFailureAt = &last
+			}
+
+			start := time.Now()
+			err := loginAs(service, users.user.Email, "wrong password")
+			if err == nil || err.Error() != "invalid credentials" {
+				t.Fatalf("Login error = %v, want invalid credentials", err)
+			}
+
+			if users.window != tt.limits.FailureWindow {
+				t.Errorf("failure window = %s, want %s", users.window, tt.limits.FailureWindow)
+			}
+			if users.user.FailedLogins != tt.wantFailures {
+				t.Errorf("failed logins = %d, want %d", users.user.FailedLogins, tt.wantFailures)
+			}
+
+			lockedUntil := users.user.LockedUntil
+			if tt.wantLock == 0 {
+				if lockedUntil != nil {
+					t.Errorf("locked until %s, want no lock", lockedUntil)
+				}
+				return
+			}
+			if lockedUntil == nil {
+				t.Fatalf("not locked, want a %s lock", tt.wantLock)
+			}
+			if lock := lockedUntil.Sub(start); lock < tt.wantLock || lock > tt.wantLock+time.Second {
+				t.Errorf("locked for %s, want %s", lock, tt.wantLock)
+			}
+		})
+	}
+}
+
+func TestLoginRefusedWhileLocked(t *testing.T) {
+	tests := []struct {
+		name          string
+		lockedFor     time.Duration // from now; negative for a lock that has expired
+		password      string
+		wantThrottled bool
+	}{
+		{"locked, right password", time.Minute, testPassword, true},
+		{"locked, wrong password", time.Minute, "wrong password", true},
+		{"lock expired", -time.Second, "wrong password", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, attempts := newLoginTestService(t, testLimits)
+			users.user.FailedLogins = testLimits.MaxFailures
+			lockedUntil := time.Now().Add(tt.lockedFor)
+			users.user.LockedUntil = &lockedUntil
+
+			err := loginAs(service, users.user.Email, tt.password)
+
+			var throttled *model.LoginThrottledError
+			if errors.As(err, &throttled) != tt.wantThrottled {
+				t.Fatalf("Login error = %v, want throttled: %t", err, tt.wantThrottled)
+			}
+			if !tt.wantThrottled {
+				return
+			}
+
+			if throttled.RetryAfter <= 0 || throttled.RetryAfter > tt.lockedFor {
+				t.Errorf("RetryAfter = %s, want up to %s", throttled.RetryAfter, tt.lockedFor)
+			}
+			if users.user.FailedLogins != testLimits.MaxFailures {
+				t.Errorf("failed logins = %d, want %d: a refused login must not count", users.user.FailedLogins, testLimits.MaxFailures)
+			}
+			if last := attempts.attempts[len(attempts.attempts)-1]; last.Result != model.LoginResultLocked {
+				t.Errorf("recorded result = %s, want %s", last.Result, model.LoginResultLocked)
+			}
+		})
+	}
+}
+
+func TestLoginThrottlesAddress(t *testing.T) {
+	const ip = "192.0.2.1"
+
+	tests := []struct {
+		name          string
+		limits        LoginLimits
+		failures      int           // earlier failures from the address
+		age           time.Duration // how long ago they were
+		otherResult   model.LoginResult
+		wantThrottled bool
+	}{
+		{"below the limit", testLimits, 49, time.Minute, "", false},
+		{"at the limit", testLimits, 50, time.Minute, "", true},
+		{"failures left the window", testLimits, 50, 16 * time.Minute, "", false},
+		{"locked and throttled attempts do not count", testLimits, 0, time.Minute, model.LoginResultLocked, false},
+		{"limit turned off", LoginLimits{IPWindow: 15 * time.Minute}, 500, time.Minute, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, users, attempts := newLoginTestService(t, tt.limits)
+			at := time.Now().Add(-tt.age)
+			for i := 0; i < tt.failures; i++ {
+				attempts.attempts = append(attempts.attempts, model.LoginAttempt{
+					IPAddress: ip,
+					Result:    model.LoginResultUnknownAccount,
+					CreatedAt: at,
+				})
+			}
+			if tt.otherResult != "" {
+				for i := 0; i < 100; i++ {
+					attempts.attempts = append(attempts.attempts, model.LoginAttempt{IPAddress: ip, Result: tt.otherResult, CreatedAt: at})
+				}
+			}
+
+			err := loginAs(service, users.user.Email, "wrong password")
+
+			var throttled *model.LoginThrottledError
+			if errors.As(err, &throttled) != tt.wantThrottled {
+				t.Fatalf("Login error = %v, want throttled: %t", err, tt.wantThrottled)
+			}
+			if !tt.wantThrottled {
+				if users.user.FailedLogins != 1 {
+					t.Errorf("failed logins = %d, want 1", users.user.FailedLogins)
+				}
+				return
+			}
+
+			want := tt.limits.IPWindow - tt.age
+			if diff := throttled.RetryAfter - want; diff > 0 || diff < -time.Second {
+				t.Errorf("RetryAfter = %s, want %s", throttled.RetryAfter, want)
+			}
+			if users.user.FailedLogins != 0 {
+				t.Errorf("failed logins = %d, want 0: a throttled login must not count against an account", users.user.FailedLogins)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
+	"github.com/google/uuid"
+)
+
+type WarehouseService interface {
+	Create(req *model.WarehouseCreateRequest) (*model.Warehouse, error)
+	GetAll() ([]model.Warehouse, error)
+	Update(id uuid.UUID, req *model.WarehouseUpdateRequest) (*model.Warehouse, error)
+}
+
+type warehouseService struct {
+	repo repository.WarehouseRepository
+}
+
+func NewWarehouseService(repo repository.WarehouseRepository) WarehouseService {
+	return &warehouseService{repo: repo}
+}
+
+func (s *warehouseService) Create(req *model.WarehouseCreateRequest) (*model.Warehouse, error) {
+	warehouse := &model.Warehouse{
+		Code:     strings.ToUpper(strings.TrimSpace(req.Code)),
+		Name:     req.Name,
+		Region:   strings.TrimSpace(req.Region),
+		Priority: req.Priority,
+	}
+
+	if warehouse.Code == "" {
+		return nil, fmt.Errorf("warehouse code is required")
+	}
+
+	if err := s.repo.Create(warehouse); err != nil {
+		return nil, err
+	}
+
+	return warehouse, nil
+}
+
+func (s *warehouseService) GetAll() ([]model.Warehouse, error) {
+	return s.repo.GetAll()
+}
+
+func (s *warehouseService) Update(id uuid.UUID, req *model.WarehouseUpdateRequest) (*model.Warehouse, error) {
+	warehouse, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		warehouse.Name = req.Name
+	}
+	if req.Region != "" {
+		warehouse.Region = strings.TrimSpace(req.Region)
+	}
+	if req.Priority != nil {
+		warehouse.Priority = *req.Priority
+	}
+
+	if err := s.repo.Update(warehouse); err != nil {
+		return nil, err
+	}
+
+	return warehouse, nil
+}
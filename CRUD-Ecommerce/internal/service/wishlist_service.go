@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ekas-7/CRUD-Ecommerce/internal/model"
+	"github.com/ekas-7/CRUD-Ecommerce/internal/repository"
+	"github.com/google/uuid"
+)
+
+type WishlistService interface {
+	Create(userID uuid.UUID, req *model.WishlistCreateRequest) (*model.Wishlist, error)
+	GetByID(id, userID uuid.UUID) (*model.Wishlist, error)
+	GetUserWishlists(userID uuid.UUID) ([]model.Wishlist, error)
+	GetShared(token string) (*model.Wishlist, error)
+	Update(id, userID uuid.UUID, req *model.WishlistUpdateRequest) (*model.Wishlist, error)
+	Delete(id, userID uuid.UUID) error
+	AddItem(id, userID uuid.UUID, req *model.WishlistItemRequest) (*model.Wishlist, error)
+	RemoveItem(id, userID, productID uuid.UUID) error
+	Share(id, userID uuid.UUID) (*model.Wishlist, error)
+	Unshare(id, userID uuid.UUID) (*model.Wishlist, error)
+	MoveToOrder(id, userID uuid.UUID, req *model.WishlistOrderRequest) (*model.Order, error)
+	StartPriceWatch(ctx context.Context, interval time.Duration)
+}
+
+type wishlistService struct {
+	repo         repository.WishlistRepository
+	productRepo  repository.ProductRepository
+	orderService OrderService
+}
+
+func NewWishlistService(repo repository.WishlistRepository, productRepo repository.ProductRepository, orderService OrderService) WishlistService {
+	return &wishlistService{
+		repo:         repo,
+		productRepo:  productRepo,
+		orderService: orderService,
+	}
+}
+
+func (s *wishlistService) Create(userID uuid.UUID, req *model.WishlistCreateRequest) (*model.Wishlist, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	wishlist := &model.Wishlist{
+		UserID: userID,
+		Name:   name,
+	}
+
+	if err := s.repo.Create(wishlist); err != nil {
+		return nil, err
+	}
+
+	return wishlist, nil
+}
+
+// GetByID returns a wishlist owned by the user. Other users' lists are
+// reported as not found.
+func (s *wishlistService) GetByID(id, userID uuid.UUID) (*model.Wishlist, error) {
+	wishlist, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if wishlist.UserID != userID {
+		return nil, fmt.Errorf("wishlist not found")
+	}
+
+	return wishlist, nil
+}
+
+func (s *wishlistService) GetUserWishlists(userID uuid.UUID) ([]model.Wishlist, error) {
+	return s.repo.GetByUser(userID)
+}
+
+// GetShared returns a shared wishlist by its token. Only products on sale
+// are shown, and the token itself is not echoed back.
+func (s *wishlistService) GetShared(token string) (*model.Wishlist, error) {
+	wishlist, err := s.repo.GetByShareToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []model.WishlistItem{}
+	for _, item := range wishlist.Items {
+		if item.Product.Status == model.ProductStatusActive {
+			items = append(items, item)
+		}
+	}
+	wishlist.Items = items
+	wishlist.ShareToken = nil
+
+	return wishlist, nil
+}
+
+func (s *wishlistService) Update(id, userID uuid.UUID, req *model.WishlistUpdateRequest) (*model.Wishlist, error) {
+	wishlist, err := s.GetByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	wishlist.Name = name
+
+	if err := s.repo.Update(wishlist); err != nil {
+		return nil, err
+	}
+
+	return wishlist, nil
+}
+
+func (s *wishlistService) Delete(id, userID uuid.UUID) error {
+	if _, err := s.GetByID(id, userID); err != nil {
+		return err
+	}
+
+	return s.repo.Delete(id)
+}
+
+func (s *wishlistService) AddItem(id, userID uuid.UUID, req *model.WishlistItemRequest) (*model.Wishlist, error) {
+	if _, err := s.GetByID(id, userID); err != nil {
+		return nil, err
+	}
+
+	product, err := s.productRepo.GetByID(req.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if product.Status != model.ProductStatusActive {
+		return nil, fmt.Errorf("product not found")
+	}
+
+	if err := s.repo.AddItem(id, product.ID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(id)
+}
+
+func (s *wishlistService) RemoveItem(id, userID, productID uuid.UUID) error {
+	if _, err := s.GetByID(id, userID); err != nil {
+		return err
+	}
+
+	removed, err := s.repo.RemoveItems(id, []uuid.UUID{productID})
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return fmt.Errorf("product is not on the wishlist")
+	}
+
+	return nil
+}
+
+// Share gives the wishlist a public token, keeping an existing one so links
+// already handed out stay valid.
+func (s *wishlistService) Share(id, userID uuid.UUID) (*model.Wishlist, error) {
+	wishlist, err := s.GetByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if wishlist.ShareToken != nil {
+		return wishlist, nil
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		return nil, err
+	}
+	wishlist.ShareToken = &token
+
+	if err := s.repo.Update(wishlist); err != nil {
+		return nil, err
+	}
+
+	return wishlist, nil
+}
+
+// Unshare revokes the public token; old links stop working.
+func (s *wishlistService) Unshare(id, userID uuid.UUID) (*model.Wishlist, error) {
+	wishlist, err := s.GetByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	wishlist.ShareToken = nil
+
+	if err := s.repo.Update(wishlist); err != nil {
+		return nil, err
+	}
+
+	return wishlist, nil
+}
+
+// MoveToOrder orders the selected items and takes them off the wishlist in
+// the same transaction. Stock and availability are checked by the order
+// service, so nothing is removed unless the order is placed.
+func (s *wishlistService) MoveToOrder(id, userID uuid.UUID, req *model.WishlistOrderRequest) (*model.Order, error) {
+	wishlist, err := s.GetByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	onList := make(map[uuid.UUID]bool, len(wishlist.Items))
+	for _, item := range wishlist.Items {
+		onList[item.ProductID] = true
+	}
+
+	items := req.Items
+	if len(items) == 0 {
+		productIDs := req.ProductIDs
+		if len(productIDs) == 0 {
+			for _, item := range wishlist.Items {
+				productIDs = append(productIDs, item.ProductID)
+			}
+		}
+		listed := make(map[uuid.UUID]bool, len(productIDs))
+		for _, productID := range productIDs {
+			if listed[productID] {
+				continue
+			}
+			listed[productID] = true
+			items = append(items, model.OrderItemRequest{ProductID: productID, Quantity: 1})
+		}
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("wishlist is empty")
+	}
+
+	orderReq := &model.OrderCreateRequest{ShippingRegion: req.ShippingRegion}
+	seen := make(map[uuid.UUID]bool, len(items))
+	for _, item := range items {
+		if !onList[item.ProductID] {
+			return nil, fmt.Errorf("product %s is not on the wishlist", item.ProductID)
+		}
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("quantity for product %s must be positive", item.ProductID)
+		}
+		if seen[item.ProductID] {
+			return nil, fmt.Errorf("product %s is listed more than once", item.ProductID)
+		}
+		seen[item.ProductID] = true
+		orderReq.Items = append(orderReq.Items, item)
+	}
+
+	return s.orderService.CreateFromWishlist(userID, id, orderReq)
+}
+
+// StartPriceWatch checks wishlisted products for price drops every interval
+// in the background until ctx is done. A zero interval disables it.
+func (s *wishlistService) StartPriceWatch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			sent, err := s.repo.NotifyPriceDrops()
+			if err != nil {
+				log.Printf("wishlist price watch: %v", err)
+				continue
+			}
+			if sent > 0 {
+				log.Printf("wishlist price watch: sent %d price drop notifications", sent)
+			}
+		}
+	}()
+}
+
+func generateShareToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate share token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,53 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 key from RFC 6238 appendix B.
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// The RFC lists 8-digit codes; these are their last six digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+}
+
+func TestCode(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		code, err := Code(rfc6238Secret, Step(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", v.unix, err)
+		}
+		if code != v.code {
+			t.Errorf("Code at %d = %s, want %s", v.unix, code, v.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		at := time.Unix(v.unix, 0)
+
+		step, ok := Validate(rfc6238Secret, v.code, at, 0)
+		if !ok || step != Step(at) {
+			t.Errorf("Validate at %d = (%d, %v), want (%d, true)", v.unix, step, ok, Step(at))
+		}
+
+		// One step of drift is accepted with skew 1, but not without
+		later := at.Add(Period * time.Second)
+		if _, ok := Validate(rfc6238Secret, v.code, later, 0); ok {
+			t.Errorf("Validate at %d accepted the previous step's code without skew", v.unix)
+		}
+		if _, ok := Validate(rfc6238Secret, v.code, later, 1); !ok {
+			t.Errorf("Validate at %d rejected the previous step's code with skew 1", v.unix)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+-- Migration: Product lifecycle states and order item snapshots
+-- Created: 2026-10-19
+
+-- Products: optional SKU and lifecycle status (draft, active, archived)
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(100) UNIQUE;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+CREATE INDEX IF NOT EXISTS idx_products_status ON products(status);
+
+-- Order items keep the product name and SKU as they were at purchase time
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_name VARCHAR(255);
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_sku VARCHAR(100);
+
+UPDATE order_items oi SET product_name = p.name, product_sku = p.sku
+FROM products p
+WHERE oi.product_id = p.id AND oi.product_name IS NULL;
+
+-- Products are archived, never deleted, while orders reference them
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.referential_constraints
+        WHERE constraint_name = 'order_items_product_id_fkey' AND delete_rule = 'CASCADE'
+    ) THEN
+        ALTER TABLE order_items DROP CONSTRAINT order_items_product_id_fkey;
+        ALTER TABLE order_items ADD CONSTRAINT order_items_product_id_fkey
+            FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE RESTRICT;
+    END IF;
+END
+$$;
@@ -0,0 +1,24 @@
+-- Migration: Inventory movement ledger
+-- Created: 2026-10-19
+
+-- Every stock change is a signed movement; products.stock is the running total
+CREATE TABLE IF NOT EXISTS inventory_movements (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL,
+    reason VARCHAR(20) NOT NULL,
+    reference_id UUID,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    note TEXT NOT NULL DEFAULT '',
+    stock_after INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_product ON inventory_movements(product_id, created_at);
+
+-- Opening balance for stock that predates the ledger
+INSERT INTO inventory_movements (product_id, quantity, reason, note, stock_after)
+SELECT p.id, p.stock, 'initial', 'opening balance', p.stock
+FROM products p
+WHERE p.stock <> 0
+  AND NOT EXISTS (SELECT 1 FROM inventory_movements m WHERE m.product_id = p.id);
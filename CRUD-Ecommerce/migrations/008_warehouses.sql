@@ -0,0 +1,53 @@
+-- Migration: Multi-warehouse inventory
+-- Created: 2026-10-19
+
+CREATE TABLE IF NOT EXISTS warehouses (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    region VARCHAR(100) NOT NULL DEFAULT '',
+    priority INTEGER NOT NULL DEFAULT 0,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- At most one default warehouse
+CREATE UNIQUE INDEX IF NOT EXISTS idx_warehouses_default ON warehouses(is_default) WHERE is_default;
+
+INSERT INTO warehouses (code, name, is_default)
+SELECT 'MAIN', 'Main warehouse', TRUE
+WHERE NOT EXISTS (SELECT 1 FROM warehouses WHERE is_default);
+
+-- products.stock stays as the sum across warehouses (available to sell)
+CREATE TABLE IF NOT EXISTS warehouse_stock (
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id) ON DELETE RESTRICT,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (warehouse_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_warehouse_stock_product ON warehouse_stock(product_id);
+
+-- Existing stock moves into the default warehouse
+INSERT INTO warehouse_stock (warehouse_id, product_id, quantity)
+SELECT w.id, p.id, p.stock
+FROM products p, warehouses w
+WHERE w.is_default AND p.stock > 0
+  AND NOT EXISTS (SELECT 1 FROM warehouse_stock ws WHERE ws.product_id = p.id);
+
+ALTER TABLE inventory_movements ADD COLUMN IF NOT EXISTS warehouse_id UUID REFERENCES warehouses(id) ON DELETE RESTRICT;
+
+UPDATE inventory_movements SET warehouse_id = (SELECT id FROM warehouses WHERE is_default)
+WHERE warehouse_id IS NULL;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_region VARCHAR(100) NOT NULL DEFAULT '';
+
+-- Which warehouse ships each part of an order item
+CREATE TABLE IF NOT EXISTS order_allocations (
+    order_item_id UUID NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id) ON DELETE RESTRICT,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (order_item_id, warehouse_id)
+);
@@ -0,0 +1,38 @@
+-- Migration: Low-stock alerts and back-in-stock notifications
+-- Created: 2026-10-19
+
+-- 0 disables low-stock alerts for the product
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reorder_threshold INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS stock_alerts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    stock INTEGER NOT NULL,
+    threshold INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    acknowledged_at TIMESTAMP
+);
+
+-- At most one open alert per product
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_alerts_open ON stock_alerts(product_id) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS stock_subscriptions (
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    notified_at TIMESTAMP,
+    PRIMARY KEY (product_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    product_id UUID REFERENCES products(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, created_at);
@@ -0,0 +1,67 @@
+-- Migration: Price history and scheduled prices
+-- Created: 2026-10-19
+
+-- products.price stays the regular price; scheduled rows override it while
+-- their window is open. Windows are TIMESTAMPTZ so they compare correctly
+-- with NOW() whatever the session time zone.
+CREATE TABLE IF NOT EXISTS product_prices (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    price DECIMAL(10, 2) NOT NULL CHECK (price > 0),
+    compare_at_price DECIMAL(10, 2),
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_at IS NULL OR ends_at >= starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_prices_product ON product_prices(product_id, kind, starts_at);
+
+-- The latest-starting scheduled price whose window is open
+CREATE OR REPLACE FUNCTION product_active_scheduled_price(p_product_id UUID)
+RETURNS product_prices AS $$
+    SELECT * FROM product_prices
+    WHERE product_id = p_product_id AND kind = 'scheduled'
+      AND starts_at <= NOW() AND (ends_at IS NULL OR ends_at > NOW())
+    ORDER BY starts_at DESC
+    LIMIT 1
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION product_effective_price(p_product_id UUID, p_base_price DECIMAL)
+RETURNS DECIMAL AS $$
+    SELECT COALESCE((product_active_scheduled_price(p_product_id)).price, p_base_price)
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION product_compare_at_price(p_product_id UUID, p_base_price DECIMAL)
+RETURNS DECIMAL AS $$
+    SELECT COALESCE(sp.compare_at_price, CASE WHEN sp.price < p_base_price THEN p_base_price END)
+    FROM product_active_scheduled_price(p_product_id) sp
+$$ LANGUAGE sql STABLE;
+
+-- Every regular price change is recorded. A new regular price also ends any
+-- open-ended scheduled price that has already started.
+CREATE OR REPLACE FUNCTION products_price_history() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR OLD.price IS DISTINCT FROM NEW.price THEN
+        UPDATE product_prices SET ends_at = NOW()
+        WHERE product_id = NEW.id AND ends_at IS NULL
+          AND (kind = 'base' OR starts_at <= NOW());
+        INSERT INTO product_prices (product_id, kind, price, starts_at)
+        VALUES (NEW.id, 'base', NEW.price, NOW());
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS products_price_history_trigger ON products;
+CREATE TRIGGER products_price_history_trigger
+    AFTER INSERT OR UPDATE OF price ON products
+    FOR EACH ROW EXECUTE FUNCTION products_price_history();
+
+-- Opening history for existing products
+INSERT INTO product_prices (product_id, kind, price, starts_at)
+SELECT p.id, 'base', p.price, p.created_at
+FROM products p
+WHERE NOT EXISTS (SELECT 1 FROM product_prices pp WHERE pp.product_id = p.id);
@@ -0,0 +1,46 @@
+-- Migration: Typed product attributes
+-- Created: 2026-10-19
+
+-- Attributes are defined per category; codes are shared across categories
+-- so that one filter such as attr[ram_gb] spans all of them
+CREATE TABLE IF NOT EXISTS attribute_definitions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    code VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    unit VARCHAR(20) NOT NULL DEFAULT '',
+    options TEXT[] NOT NULL DEFAULT '{}',
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (category_id, code)
+);
+
+CREATE INDEX IF NOT EXISTS idx_attribute_definitions_code ON attribute_definitions(code);
+
+-- value_text is always set; value_number and value_bool hold typed copies
+-- for range and boolean filters
+CREATE TABLE IF NOT EXISTS product_attribute_values (
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    attribute_id UUID NOT NULL REFERENCES attribute_definitions(id) ON DELETE CASCADE,
+    value_text TEXT NOT NULL,
+    value_number NUMERIC,
+    value_bool BOOLEAN,
+    PRIMARY KEY (product_id, attribute_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_attribute_values_number ON product_attribute_values(attribute_id, value_number);
+CREATE INDEX IF NOT EXISTS idx_product_attribute_values_text ON product_attribute_values(attribute_id, lower(value_text));
+
+-- A product's attributes as a JSON object keyed by code
+CREATE OR REPLACE FUNCTION product_attributes(p_product_id UUID)
+RETURNS JSONB AS $$
+    SELECT COALESCE(jsonb_object_agg(d.code, CASE
+        WHEN d.type IN ('integer', 'decimal') THEN to_jsonb(v.value_number)
+        WHEN d.type = 'boolean' THEN to_jsonb(v.value_bool)
+        ELSE to_jsonb(v.value_text)
+    END), '{}')
+    FROM product_attribute_values v
+    JOIN attribute_definitions d ON d.id = v.attribute_id
+    WHERE v.product_id = p_product_id
+$$ LANGUAGE sql STABLE;
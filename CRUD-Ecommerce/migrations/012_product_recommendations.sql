@@ -0,0 +1,12 @@
+-- Migration: Related product recommendations
+-- Created: 2026-10-19
+
+-- Rebuilt by the recommendations job; score is the number of delivered
+-- orders containing both products
+CREATE TABLE IF NOT EXISTS product_recommendations (
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    related_product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    score INTEGER NOT NULL,
+    computed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (product_id, related_product_id)
+);
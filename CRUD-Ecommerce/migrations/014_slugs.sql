@@ -0,0 +1,56 @@
+-- Migration: Product and category slugs
+-- Created: 2026-10-19
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS slug VARCHAR(255);
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS slug VARCHAR(255);
+
+-- Existing rows get a slug from their name; names that clash get a short
+-- id suffix
+UPDATE products t SET slug = s.slug
+FROM (
+    SELECT b.id,
+           CASE WHEN row_number() OVER (PARTITION BY b.base ORDER BY b.created_at, b.id) = 1
+                 AND NOT EXISTS (SELECT 1 FROM products o WHERE o.slug = b.base)
+                THEN b.base
+                ELSE b.base || '-' || left(b.id::text, 8)
+           END AS slug
+    FROM (
+        SELECT id, created_at,
+               COALESCE(NULLIF(trim(both '-' FROM regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g')), ''), 'product') AS base
+        FROM products
+        WHERE slug IS NULL
+    ) b
+) s
+WHERE t.id = s.id;
+
+UPDATE categories t SET slug = s.slug
+FROM (
+    SELECT b.id,
+           CASE WHEN row_number() OVER (PARTITION BY b.base ORDER BY b.created_at, b.id) = 1
+                 AND NOT EXISTS (SELECT 1 FROM categories o WHERE o.slug = b.base)
+                THEN b.base
+                ELSE b.base || '-' || left(b.id::text, 8)
+           END AS slug
+    FROM (
+        SELECT id, created_at,
+               COALESCE(NULLIF(trim(both '-' FROM regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g')), ''), 'category') AS base
+        FROM categories
+        WHERE slug IS NULL
+    ) b
+) s
+WHERE t.id = s.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_slug ON products(slug);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories(slug);
+
+-- Slugs retired by a rename, answered with a redirect to the current one.
+-- target_id refers to products or categories depending on entity.
+CREATE TABLE IF NOT EXISTS slug_redirects (
+    entity VARCHAR(20) NOT NULL,
+    slug VARCHAR(255) NOT NULL,
+    target_id UUID NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (entity, slug)
+);
+
+CREATE INDEX IF NOT EXISTS idx_slug_redirects_target ON slug_redirects(target_id);
@@ -0,0 +1,8 @@
+-- Migration: Record versions for optimistic concurrency
+-- Created: 2026-10-19
+
+-- Incremented on every write; exposed as the ETag and checked against
+-- If-Match on updates
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,40 @@
+-- Migration: Product bundles
+-- Created: 2026-10-19
+
+-- 'simple' products carry their own stock; 'bundle' products are sold as a
+-- set of component products and have no stock of their own
+ALTER TABLE products ADD COLUMN IF NOT EXISTS type VARCHAR(20) NOT NULL DEFAULT 'simple';
+
+CREATE TABLE IF NOT EXISTS bundle_components (
+    bundle_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    component_id UUID NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (bundle_id, component_id),
+    CHECK (bundle_id <> component_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bundle_components_component ON bundle_components(component_id);
+
+-- Stock available to sell: a bundle can be sold as many times as its
+-- scarcest active component allows
+CREATE OR REPLACE FUNCTION product_available_stock(p_product_id UUID, p_type VARCHAR, p_stock INTEGER)
+RETURNS INTEGER AS $$
+    SELECT CASE WHEN p_type <> 'bundle' THEN p_stock ELSE COALESCE((
+        SELECT MIN(CASE WHEN c.status = 'active' THEN GREATEST(c.stock, 0) / bc.quantity ELSE 0 END)
+        FROM bundle_components bc
+        JOIN products c ON c.id = bc.component_id
+        WHERE bc.bundle_id = p_product_id
+    ), 0) END
+$$ LANGUAGE sql STABLE;
+
+-- Allocations record the product actually shipped, which for a bundle item
+-- is one of its components
+ALTER TABLE order_allocations ADD COLUMN IF NOT EXISTS product_id UUID REFERENCES products(id) ON DELETE RESTRICT;
+
+UPDATE order_allocations oa SET product_id = oi.product_id
+FROM order_items oi
+WHERE oi.id = oa.order_item_id AND oa.product_id IS NULL;
+
+ALTER TABLE order_allocations ALTER COLUMN product_id SET NOT NULL;
+ALTER TABLE order_allocations DROP CONSTRAINT IF EXISTS order_allocations_pkey;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_allocations_item ON order_allocations(order_item_id, product_id, warehouse_id);
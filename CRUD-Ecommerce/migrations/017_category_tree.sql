@@ -0,0 +1,8 @@
+-- Migration: Category tree
+-- Created: 2026-10-19
+
+-- Top-level categories have no parent. Categories with subcategories cannot
+-- be deleted; the application also prevents cycles and limits nesting depth.
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES categories(id) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent ON categories(parent_id);
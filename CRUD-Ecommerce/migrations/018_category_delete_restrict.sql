@@ -0,0 +1,9 @@
+-- Migration: Safe category deletion
+-- Created: 2026-10-19
+
+-- Deleting a category used to set its products' category_id to NULL. The
+-- application now moves products to another category first, and the
+-- database refuses to orphan them.
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_category_id_fkey;
+ALTER TABLE products ADD CONSTRAINT products_category_id_fkey
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE RESTRICT;
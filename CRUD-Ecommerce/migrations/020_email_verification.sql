@@ -0,0 +1,19 @@
+-- Migration: Email verification
+-- Created: 2026-10-19
+
+-- Accounts that existed before verification was introduced count as
+-- verified; new accounts start unverified
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE users ALTER COLUMN email_verified_at DROP DEFAULT;
+
+-- Single-use tokens emailed to users, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS user_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(30) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user ON user_tokens(user_id, purpose);
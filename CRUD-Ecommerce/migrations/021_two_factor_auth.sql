@@ -0,0 +1,25 @@
+-- Migration: Two-factor authentication
+-- Created: 2026-10-19
+
+-- TOTP secret, stored during enrolment and enabled once the user confirms
+-- it with a code. mfa_last_step is the last time step a code was accepted
+-- for, so a code cannot be replayed.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_enabled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_last_step BIGINT;
+
+-- Single-use recovery codes, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    code_hash VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user ON mfa_recovery_codes(user_id);
+
+-- Wrong codes entered against an MFA login challenge
+ALTER TABLE user_tokens ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+
+-- Whether the session was started with a second factor
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS mfa BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,22 @@
+-- Migration: Login throttling and history
+-- Created: 2026-10-19
+
+-- Consecutive failed logins, reset by a successful one; each failure past
+-- the first few locks the account for longer
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_logins INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+
+-- Every login attempt, for the user's login history and for limiting
+-- failures per client address. Attempts for unknown emails have no user.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL,
+    user_agent TEXT,
+    result VARCHAR(30) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user ON login_attempts(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(ip_address, created_at);
@@ -0,0 +1,66 @@
+-- Migration: Roles and permissions
+-- Created: 2026-10-19
+
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(20) PRIMARY KEY,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(20) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission VARCHAR(50) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+-- Built-in roles; customers are 'user', which has no permissions
+INSERT INTO roles (name, description) VALUES
+    ('user', 'Customer'),
+    ('admin', 'Full access'),
+    ('support', 'Customer support: orders, reviews and account lockouts'),
+    ('warehouse', 'Warehouse staff: stock and order fulfilment')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('catalog:write', 'Manage products, categories, attributes, prices and imports'),
+    ('inventory:manage', 'Adjust stock, handle low-stock alerts and manage warehouses'),
+    ('orders:read_all', 'View any customer''s orders'),
+    ('orders:update_status', 'Update the status of and cancel any order'),
+    ('reviews:moderate', 'Moderate product reviews'),
+    ('users:manage', 'View and unlock locked accounts'),
+    ('roles:manage', 'Assign roles to users')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission)
+SELECT 'admin', name FROM permissions
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('support', 'orders:read_all'),
+    ('support', 'orders:update_status'),
+    ('support', 'reviews:moderate'),
+    ('support', 'users:manage'),
+    ('warehouse', 'orders:read_all'),
+    ('warehouse', 'orders:update_status'),
+    ('warehouse', 'inventory:manage')
+ON CONFLICT DO NOTHING;
+
+-- Every user has one of the roles above
+UPDATE users SET role = 'user' WHERE role IS NULL OR role NOT IN (SELECT name FROM roles);
+ALTER TABLE users ALTER COLUMN role SET NOT NULL;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.table_constraints
+        WHERE constraint_name = 'users_role_fkey'
+    ) THEN
+        ALTER TABLE users ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles(name);
+    END IF;
+END
+$$;
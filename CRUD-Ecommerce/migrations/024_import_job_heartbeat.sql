@@ -0,0 +1,6 @@
+-- Migration: Import job heartbeat
+-- Created: 2026-10-19
+
+-- Refreshed on every progress write, so jobs lost with a stopped server can
+-- be recognised and reported as interrupted
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
@@ -0,0 +1,50 @@
+-- Migration: Indexed effective price
+-- Created: 2026-10-19
+
+-- The effective price is stored on the product row so that price filters,
+-- sorts and cursors can use an index. Regular price changes and scheduled
+-- price edits update it at once; scheduled windows opening or closing are
+-- picked up by the API's periodic price refresh.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS effective_price DECIMAL(10, 2);
+UPDATE products SET effective_price = product_effective_price(id, price) WHERE effective_price IS NULL;
+ALTER TABLE products ALTER COLUMN effective_price SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_products_effective_price ON products(effective_price, id);
+
+CREATE OR REPLACE FUNCTION products_effective_price() RETURNS trigger AS $$
+BEGIN
+    NEW.effective_price := product_effective_price(NEW.id, NEW.price);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS products_effective_price_trigger ON products;
+CREATE TRIGGER products_effective_price_trigger
+    BEFORE INSERT OR UPDATE OF price ON products
+    FOR EACH ROW EXECUTE FUNCTION products_effective_price();
+
+CREATE OR REPLACE FUNCTION product_prices_effective_price() RETURNS trigger AS $$
+DECLARE
+    changed product_prices;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed := OLD;
+    ELSE
+        changed := NEW;
+    END IF;
+
+    IF changed.kind = 'scheduled' THEN
+        UPDATE products SET effective_price = product_effective_price(id, price)
+        WHERE id = changed.product_id
+          AND effective_price IS DISTINCT FROM product_effective_price(id, price);
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS product_prices_effective_price_trigger ON product_prices;
+CREATE TRIGGER product_prices_effective_price_trigger
+    AFTER INSERT OR UPDATE OR DELETE ON product_prices
+    FOR EACH ROW EXECUTE FUNCTION product_prices_effective_price();
+
+-- Price windows are TIMESTAMPTZ; so is the rest of the schedule
+ALTER TABLE product_prices ALTER COLUMN created_at TYPE TIMESTAMPTZ;
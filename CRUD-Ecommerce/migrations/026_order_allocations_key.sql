@@ -0,0 +1,18 @@
+-- Migration: Order allocations primary key
+-- Created: 2026-10-19
+
+-- Migration 016 dropped the primary key when allocations gained product_id.
+-- Restore one on the columns that identify an allocation by taking over the
+-- unique index. The constraint keeps the index's name, so re-running 016
+-- finds the index in place and leaves it alone.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'order_allocations'::regclass AND contype = 'p'
+    ) THEN
+        ALTER TABLE order_allocations
+            ADD CONSTRAINT idx_order_allocations_item PRIMARY KEY USING INDEX idx_order_allocations_item;
+    END IF;
+END
+$$;
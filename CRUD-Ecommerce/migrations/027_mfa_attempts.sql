@@ -0,0 +1,6 @@
+-- Migration: MFA attempt limit
+-- Created: 2026-10-19
+
+-- Consecutive wrong codes given while enrolling or managing MFA, capped like
+-- the attempts on a login challenge
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_attempts INTEGER NOT NULL DEFAULT 0;
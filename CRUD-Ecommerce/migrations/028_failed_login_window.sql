@@ -0,0 +1,6 @@
+-- Migration: Failed login window
+-- Created: 2026-10-19
+
+-- When the last failed login was, so that an account's count starts over
+-- once failures stop for a while
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_failed_login_at TIMESTAMP;